@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"go-ids/internal/auth"
+)
+
+// authUsage auth 子命令的用法
+const authUsage = `用法: ids auth <命令>
+
+命令:
+  hash-password    从标准输入读取密码，输出写入 server.auth.users 的 bcrypt 哈希
+  new-key          生成新的 API Key，输出 Key 本身与写入 server.auth.api_keys 的 SHA-256 摘要
+`
+
+// runAuthCommand 执行 auth 子命令，返回进程退出码
+func runAuthCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, authUsage)
+		return 2
+	}
+	switch args[0] {
+	case "hash-password":
+		fmt.Fprint(os.Stderr, "密码: ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			fmt.Fprintln(os.Stderr, "\n密码不能为空")
+			return 1
+		}
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成哈希失败: %v\n", err)
+			return 1
+		}
+		fmt.Println(hash)
+	case "new-key":
+		key, sum, err := auth.NewAPIKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成 API Key 失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("key:        %s\nkey_sha256: %s\n", key, sum)
+		fmt.Fprintln(os.Stderr, "Key 只显示这一次，配置文件中只需保存 key_sha256")
+	default:
+		fmt.Fprint(os.Stderr, authUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+)
+
+// dbUsage db 子命令的用法
+const dbUsage = `用法: ids db <命令> [参数]
+
+命令:
+  migrate    更新表结构并执行尚未执行的数据迁移，迁移不会删除任何告警
+  vacuum     将 SQLite 数据库切换为增量 auto_vacuum 并回收空闲页，会重写整个数据库文件，
+             期间其他进程无法写入，请在传感器停止时执行
+`
+
+// runDBCommand 执行 db 子命令，返回进程退出码
+func runDBCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "migrate" && args[0] != "vacuum") {
+		fmt.Fprint(os.Stderr, dbUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("db "+args[0], flag.ContinueOnError)
+	configPath := fs.String("config", "config/config.yaml", "配置文件路径，使用其中的 database 配置")
+	status := fs.Bool("status", false, "只列出状态，不做修改")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := loader.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	store, err := db.Open(cfg.Database.Source())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	if args[0] == "vacuum" {
+		return runVacuum(store, *status)
+	}
+	if *status {
+		statuses, err := store.Migrations()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取迁移状态失败: %v\n", err)
+			return 1
+		}
+		for _, s := range statuses {
+			applied := "未执行"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-40s %s\n", s.Version, s.Name, applied)
+		}
+		return 0
+	}
+
+	applied, err := store.Migrate()
+	for _, s := range applied {
+		fmt.Printf("已执行迁移 %d: %s\n", s.Version, s.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "迁移失败: %v\n", err)
+		return 1
+	}
+	if len(applied) == 0 {
+		fmt.Println("数据库已是最新版本")
+	}
+	return 0
+}
+
+// runVacuum 执行 db vacuum，status 为 true 时只报告当前的 auto_vacuum 模式
+func runVacuum(store *db.Store, status bool) int {
+	if store.Driver() != db.DriverSQLite {
+		fmt.Println("PostgreSQL 由 autovacuum 回收空间，无需执行")
+		return 0
+	}
+	enabled, err := store.IncrementalVacuumEnabled()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取 auto_vacuum 模式失败: %v\n", err)
+		return 1
+	}
+	if status {
+		fmt.Printf("增量 auto_vacuum: %v\n", enabled)
+		return 0
+	}
+
+	if !enabled {
+		stats, err := store.GetDBStats()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取数据库大小失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("正在将 %s (%d 字节) 切换为增量 auto_vacuum，需要重写整个数据库文件...\n", stats.Path, stats.FileSize)
+		start := time.Now()
+		if err := store.EnableIncrementalVacuum(); err != nil {
+			fmt.Fprintf(os.Stderr, "VACUUM 失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("已切换为增量 auto_vacuum，耗时 %s\n", time.Since(start).Round(time.Millisecond))
+		return 0
+	}
+
+	reclaimed, err := store.IncrementalVacuum()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "增量 VACUUM 失败: %v\n", err)
+		return 1
+	}
+	fmt.Printf("已是增量 auto_vacuum，回收 %d 字节\n", reclaimed)
+	return 0
+}
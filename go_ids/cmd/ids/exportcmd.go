@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/export"
+	"go-ids/internal/loader"
+)
+
+// exportUsage export 子命令的用法
+const exportUsage = `用法: ids export <alerts|flows> [参数]
+
+命令:
+  alerts    导出满足条件的告警，STIX 格式中每条告警为攻击源地址的 indicator
+  flows     导出满足条件的流记录，STIX 格式中每条流为 observed-data
+
+使用 ids export <命令> -h 查看参数
+`
+
+// optionalTime 可选的 RFC 3339 时间参数
+type optionalTime struct{ t *time.Time }
+
+func (o *optionalTime) String() string {
+	if o.t == nil {
+		return ""
+	}
+	return o.t.Format(time.RFC3339)
+}
+
+func (o *optionalTime) Set(s string) error {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("时间格式应为 RFC 3339，例如 2024-05-01T00:00:00+08:00")
+	}
+	o.t = &t
+	return nil
+}
+
+// splitList 拆分逗号分隔的参数
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runExportCommand 执行 export 子命令，返回进程退出码
+// 数据分批读取并写出，导出大量数据时内存占用不随结果集增长
+func runExportCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "alerts" && args[0] != "flows") {
+		fmt.Fprint(os.Stderr, exportUsage)
+		return 2
+	}
+	kind := args[0]
+
+	fs := flag.NewFlagSet("export "+kind, flag.ContinueOnError)
+	configPath := fs.String("config", "config/config.yaml", "配置文件路径，使用其中的 database 配置")
+	formatName := fs.String("format", "csv", "导出格式: csv、ndjson 或 stix")
+	output := fs.String("o", "-", "输出文件，- 表示标准输出")
+	src := fs.String("src", "", "源地址，IP 或 CIDR")
+	dst := fs.String("dst", "", "目的地址，IP 或 CIDR")
+	labels := fs.String("label", "", "类型，多个用逗号分隔")
+	var since, until optionalTime
+	fs.Var(&since, "since", "开始时间 (含)，RFC 3339")
+	fs.Var(&until, "until", "结束时间 (不含)，RFC 3339")
+	var minConfidence *float64
+	var malicious *bool
+	var alertID *uint
+	if kind == "alerts" {
+		minConfidence = fs.Float64("min-confidence", 0, "最低置信度 (0-1)")
+	} else {
+		malicious = fs.Bool("malicious", false, "只导出超过检测阈值的流")
+		alertID = fs.Uint("alert", 0, "只导出关联到该告警的流")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if since.t != nil && until.t != nil && !since.t.Before(*until.t) {
+		fmt.Fprintln(os.Stderr, "since 必须早于 until")
+		return 2
+	}
+
+	cfg, err := loader.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	store, err := db.Open(cfg.Database.Source())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "创建输出文件失败: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+
+	var n int
+	if kind == "alerts" {
+		filter := db.AlertFilter{Source: *src, Dest: *dst, Labels: splitList(*labels), Since: since.t, Until: until.t}
+		if *minConfidence > 0 {
+			c := float32(*minConfidence)
+			filter.MinConfidence = &c
+		}
+		n, err = export.Alerts(w, format, store, filter)
+	} else {
+		filter := db.FlowFilter{Source: *src, Dest: *dst, Labels: splitList(*labels), Since: since.t, Until: until.t}
+		if *malicious {
+			filter.Malicious = malicious
+		}
+		if *alertID > 0 {
+			filter.AlertID = alertID
+		}
+		n, err = export.Flows(w, format, store, filter)
+	}
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "导出失败 (已写出 %d 条): %v\n", n, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "已导出 %d 条记录\n", n)
+	return 0
+}
@@ -1,22 +1,34 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"go-ids/internal/auth"
 	"go-ids/internal/capture"
 	"go-ids/internal/db"
 	"go-ids/internal/decoder"
+	"go-ids/internal/eve"
 	"go-ids/internal/feature"
 	"go-ids/internal/flow"
+	"go-ids/internal/flowrecord"
+	"go-ids/internal/incident"
 	"go-ids/internal/inference"
 	"go-ids/internal/loader"
 	"go-ids/internal/logger"
+	"go-ids/internal/metrics"
+	"go-ids/internal/notify"
 	"go-ids/internal/response"
+	"go-ids/internal/retention"
 	"go-ids/internal/server"
 
 	"github.com/google/gopacket"
@@ -25,8 +37,21 @@ import (
 )
 
 func main() {
+	// 维护子命令不启动传感器
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "auth":
+			os.Exit(runAuthCommand(os.Args[2:]))
+		case "db":
+			os.Exit(runDBCommand(os.Args[2:]))
+		case "export":
+			os.Exit(runExportCommand(os.Args[2:]))
+		}
+	}
+
 	// 1. 解析命令行参数
 	configPath := flag.String("config", "config/config.yaml", "配置文件路径")
+	demo := flag.Bool("demo", false, "在空数据库中写入演示用的告警数据")
 	flag.Parse()
 
 	// 2. 加载配置
@@ -41,17 +66,60 @@ func main() {
 	logrus.Info("Go Deep-Learning IDS 正在启动...")
 
 	// 4. 初始化数据库
-	if err := db.InitDB("config/ids.db"); err != nil {
+	driver, dsn := cfg.Database.Source()
+	store, err := db.InitDB(driver, dsn)
+	if err != nil {
 		logrus.Fatalf("初始化数据库失败: %v", err)
 	}
-	logrus.Info("SQLite 数据库初始化成功")
+	defer store.Close()
+	server.SetRepository(store)
+	logrus.Infof("%s 数据库初始化成功", driver)
+	if enabled, err := store.IncrementalVacuumEnabled(); err != nil {
+		logrus.Warnf("读取 auto_vacuum 模式失败: %v", err)
+	} else if !enabled {
+		logrus.Warn("数据库未启用增量 auto_vacuum，清理后的空间不会归还给文件系统，请在停止传感器后执行 ids db vacuum")
+	}
+	if *demo {
+		n, err := store.SeedDemoAlerts()
+		if err != nil {
+			logrus.Fatalf("写入演示数据失败: %v", err)
+		}
+		logrus.Infof("已写入 %d 条演示告警", n)
+	}
 
 	// 5. 启动 Web Server (Gin)
-	go func() {
-		logrus.Info("启动 Web Server on :8080")
-		if err := server.StartServer("8080"); err != nil {
-			logrus.Errorf("Web Server 启动失败: %v", err)
+	if cfg.Server.Auth.Enabled {
+		if len(cfg.Server.Auth.Users) == 0 && len(cfg.Server.Auth.APIKeys) == 0 {
+			user, password, err := auth.BootstrapUser()
+			if err != nil {
+				logrus.Fatalf("生成临时管理员失败: %v", err)
+			}
+			cfg.Server.Auth.Users = []loader.AuthUser{user}
+			// 密码只打印到标准错误，不写入日志文件，以免随日志轮转与采集外泄
+			logrus.Warnf("未配置任何用户或 API Key，已生成临时管理员 %s，密码已打印到标准错误 (重启后失效，请用 ids auth hash-password 在 server.auth.users 中配置用户)", user.Username)
+			fmt.Fprintf(os.Stderr, "临时管理员 %s 的密码: %s\n", user.Username, password)
 		}
+		authn, err := auth.New(cfg.Server.Auth)
+		if err != nil {
+			logrus.Fatalf("初始化 API 认证失败: %v", err)
+		}
+		server.SetAuthenticator(authn)
+		logrus.Infof("API 认证已开启: %d 个用户, %d 个 API Key", len(cfg.Server.Auth.Users), len(cfg.Server.Auth.APIKeys))
+	} else {
+		logrus.Warn("API 认证未开启，任何能访问本机 Web 服务的人都具有管理员权限 (server.auth.enabled)")
+	}
+	// 先加载证书并绑定端口，失败时直接退出，避免传感器在没有 API 的情况下继续运行
+	if err := server.Listen(cfg.Server); err != nil {
+		logrus.Fatalf("Web Server 启动失败: %v", err)
+	}
+	scheme := "http"
+	if cfg.Server.TLS.Enabled {
+		scheme = "https"
+	}
+	logrus.Infof("启动 Web Server on %s://%s", scheme, cfg.Server.Address())
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve()
 	}()
 
 	// 6. 初始化推理引擎
@@ -68,6 +136,8 @@ func main() {
 		logrus.Fatalf("初始化标准化器失败: %v", err)
 	}
 	extractor := feature.NewExtractor()
+	// 导出误报数据集时使用训练时的特征列名
+	server.SetFeatureNames(scaler.GetFeatureNames())
 
 	// 7. 初始化流管理器
 	// 使用配置中的超时时间
@@ -76,81 +146,252 @@ func main() {
 	server.SetFlowCounter(flowMgr)
 
 	// 7. 初始化响应器
+	// 内联模式下封禁由数据包裁决执行，不需要防火墙后端
+	inline := cfg.Capture.Backend == loader.CaptureBackendNFQueue
+	// 即使关闭了自动封禁，也需要防火墙后端来清理重启前遗留的封禁
+	var blocker response.Blocker
+	if !inline {
+		blocker, err = response.NewBlocker(cfg.Response.Firewall, nil)
+		if err != nil {
+			logrus.Fatalf("初始化防火墙后端失败: %v", err)
+		}
+		if err := blocker.Init(); err != nil {
+			logrus.Fatalf("初始化防火墙后端 %s 失败: %v", blocker.Name(), err)
+		}
+		logrus.Infof("防火墙后端: %s, 自动封禁: %v", blocker.Name(), cfg.Response.EnableBlock)
+	}
 	responder := response.NewResponder(
 		cfg.Response.EnableBlock,
 		cfg.Response.BlockDuration,
 		cfg.Response.Whitelist,
+		blocker,
+		store,
 	)
+	responder.SetInline(inline)
+	responder.SetAlertPolicy(cfg.Response.Alerts)
+	if cfg.Response.Incidents.Window > 0 {
+		responder.SetCorrelator(incident.NewCorrelator(cfg.Response.Incidents, store))
+	}
+	var notifier *notify.Notifier
+	if len(cfg.Response.Notifiers) > 0 {
+		notifier = notify.New(cfg.Response.Notifiers, store)
+		responder.SetNotifier(notifier)
+		logrus.Infof("已配置 %d 个告警通知目标", len(cfg.Response.Notifiers))
+	}
+	var syslogForwarder *notify.SyslogForwarder
+	if sl := cfg.Response.Syslog; sl.Enabled {
+		syslogForwarder, err = notify.NewSyslogForwarder(sl)
+		if err != nil {
+			logrus.Fatalf("初始化 syslog 转发失败: %v", err)
+		}
+		responder.SetSyslog(syslogForwarder)
+		logrus.Infof("告警通过 syslog (%s/%s) 转发到 %s", sl.Network, sl.Format, sl.Address)
+	}
+	if cfg.Response.EnableTCPReset {
+		resetter, err := response.NewTCPResetter()
+		if err != nil {
+			logrus.Fatalf("初始化 TCP RST 注入失败: %v", err)
+		}
+		defer resetter.Close()
+		responder.SetTCPResetter(resetter)
+		logrus.Info("已启用 TCP RST 注入")
+	}
+	// 加载运行时添加的白名单，并按数据库中的封禁记录恢复防火墙状态
+	if err := responder.LoadWhitelist(); err != nil {
+		logrus.Errorf("加载白名单失败: %v", err)
+	}
+	if err := responder.Reconcile(); err != nil {
+		logrus.Errorf("同步封禁状态失败: %v", err)
+	}
+	// 注入到 Web Server 以支持手动封禁与白名单管理
+	server.SetBlockManager(responder)
 
-	// 8. 初始化捕获和解码
-	pktSource, err := capture.NewPcapSource(
-		cfg.Capture.Interface,
-		int32(cfg.Capture.Snaplen),
-		cfg.Capture.Promiscuous,
-	)
-	if err != nil {
-		logrus.Errorf("无法打开捕获设备 %s: %v (已切换至仅Web模式)", cfg.Capture.Interface, err)
-	} else {
+	// 8. 初始化捕获
+	// workerSources[i] 是第 i 个处理协程负责的抓包源，每个协程内部合并为一个数据包流
+	workerSources := openCaptureSources(cfg)
+	var pktSources []*capture.MultiSource
+	for _, sources := range workerSources {
+		if len(sources) == 0 {
+			continue
+		}
+		pktSource := capture.NewMultiSource(sources, cfg.Performance.PacketQueueSize)
 		defer pktSource.Close()
+		pktSources = append(pktSources, pktSource)
+	}
+	if len(pktSources) == 0 {
+		logrus.Errorf("没有可用的捕获设备 (已切换至仅Web模式)")
 	}
 
-	pktDecoder := decoder.NewDecoder()
+	// Suricata EVE 兼容事件日志
+	var eveLog *eve.Logger
+	if cfg.Eve.Enabled {
+		eveLog, err = eve.New(cfg.Eve)
+		if err != nil {
+			logrus.Fatalf("打开 EVE 日志失败: %v", err)
+		}
+		defer eveLog.Close()
+		logrus.Infof("EVE 事件日志: %s", cfg.Eve.FilePath)
+	}
+
+	// 检测过的流写入 flow_records，由检测协程在每轮检测后批量提交
+	recorder := flowrecord.NewRecorder(cfg.Flow.Records, store)
+
+	// detect 对流做特征提取与推理，返回预测结果 (失败时为 nil)，命中恶意标签时生成告警并返回 true
+	// final 表示流已结束，良性流只在结束时按存储策略记录，恶意流在检出时记录
+	detect := func(f *flow.Flow, final bool) (*inference.Prediction, bool) {
+		// 1. 提取原始特征
+		rawFeatures := extractor.Extract(f)
+		// 2. 特征标准化
+		scaledFeatures, err := scaler.Transform(rawFeatures)
+		if err != nil {
+			logrus.Errorf("特征标准化失败: %v", err)
+			metrics.Pipeline.InferenceErrors.Add(1)
+			return nil, false
+		}
+		// 3. 推理预测
+		pred, err := engine.Predict(scaledFeatures)
+		if err != nil {
+			logrus.Errorf("推理失败: %v", err)
+			metrics.Pipeline.InferenceErrors.Add(1)
+			if final {
+				recorder.Record(f, rawFeatures, nil, false, 0)
+			}
+			return nil, false
+		}
+		metrics.Pipeline.Inferences.Add(1)
+
+		// 4. 响应处理
+		currentThreshold := loader.GetConfig().Detection.Threshold
+		if pred.Label == "Benign" || float64(pred.Probability) < currentThreshold {
+			if final {
+				recorder.Record(f, rawFeatures, &pred, false, 0)
+			}
+			return &pred, false
+		}
+		event := response.Event{
+			SourceIP:   f.Key.SrcIP,
+			DestIP:     f.Key.DstIP,
+			Label:      pred.Label,
+			Confidence: pred.Probability,
+			Timestamp:  time.Now(),
+			Payload:    string(f.RawPayload), // 提取并转换 Payload
+			Interface:  f.Interface,
+			Features:   rawFeatures,
+		}
+		alertID := responder.Handle(event)
+		recorder.Record(f, rawFeatures, &pred, true, alertID)
+		if cfg.Response.EnableTCPReset {
+			// 尽量使用流的最新状态，使 RST 的序列号落在对端的接收窗口内
+			if live := flowMgr.Snapshot(f.Key); live != nil {
+				f = live
+			}
+			responder.ResetFlow(f)
+		}
+		// 只为已记入告警数据库的事件输出 EVE 告警，白名单与被限速的事件不输出，两者保持一致
+		// 动作按封禁的实际结果记录: 封禁失败、未启用封禁或流已结束时为 allowed
+		if alertID != 0 {
+			blocked := !final && responder.IsBlocked(f.Key.SrcIP)
+			eveLog.Alert(f, pred, blocked)
+		}
+		return &pred, true
+	}
 
 	// 9. 启动后台清理与检测协程
+	// 后台协程都会访问数据库，退出时先等待它们结束再关闭数据库
 	stopChan := make(chan struct{})
-	go func() {
+	var workers sync.WaitGroup
+	background := func(run func(stop <-chan struct{})) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(stopChan)
+		}()
+	}
+	activeTimeout := time.Duration(cfg.Flow.ActiveTimeout) * time.Second
+	background(func(stop <-chan struct{}) {
 		ticker := time.NewTicker(time.Duration(cfg.Flow.CleanupInterval) * time.Second)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ticker.C:
+				// 长连接在活跃超时检查点提前检测，判定为恶意后内联模式丢弃该流后续数据包
+				checkpoints := flowMgr.Checkpoint(activeTimeout)
+				if len(checkpoints) > 0 {
+					logrus.Debugf("在活跃超时检查点分析 %d 个流", len(checkpoints))
+					for _, snap := range checkpoints {
+						if _, malicious := detect(snap, false); malicious {
+							flowMgr.MarkMalicious(snap.Key)
+						}
+					}
+				}
+
 				// 清理过期流并执行检测
 				expiredFlows := flowMgr.Cleanup()
 				if len(expiredFlows) > 0 {
 					logrus.Debugf("清理并分析 %d 个过期流", len(expiredFlows))
 					for _, f := range expiredFlows {
-						// 1. 提取原始特征
-						rawFeatures := extractor.Extract(f)
-						// 2. 特征标准化
-						scaledFeatures, err := scaler.Transform(rawFeatures)
-						if err != nil {
-							logrus.Errorf("特征标准化失败: %v", err)
-							continue
-						}
-						// 3. 推理预测
-						pred, err := engine.Predict(scaledFeatures)
-						if err != nil {
-							logrus.Errorf("推理失败: %v", err)
+						snap := f.Snapshot()
+						// 已在检查点告警过的流不再重复告警
+						if f.IsMalicious() {
+							eveLog.Flow(snap, nil, true)
 							continue
 						}
-
-						// 4. 响应处理
-						currentThreshold := loader.GetConfig().Detection.Threshold
-						if pred.Label != "Benign" && float64(pred.Probability) >= currentThreshold {
-							event := response.Event{
-								SourceIP:   f.Key.SrcIP,
-								DestIP:     f.Key.DstIP,
-								Label:      pred.Label,
-								Confidence: pred.Probability,
-								Timestamp:  time.Now(),
-								Payload:    string(f.RawPayload), // 提取并转换 Payload
-							}
-							responder.Handle(event)
-						}
+						pred, malicious := detect(snap, true)
+						eveLog.Flow(snap, pred, malicious)
 					}
 				}
-			case <-stopChan:
+
+				if err := recorder.Flush(); err != nil {
+					logrus.Errorf("保存流记录失败: %v", err)
+				}
+			case <-stop:
+				// 写入缓冲中尚未提交的流记录
+				if err := recorder.Flush(); err != nil {
+					logrus.Errorf("保存流记录失败: %v", err)
+				}
 				return
 			}
 		}
-	}()
+	})
+
+	// 到期自动解除封禁
+	background(responder.Run)
+	if notifier != nil {
+		background(notifier.Run)
+	}
+	if syslogForwarder != nil {
+		background(syslogForwarder.Run)
+	}
+	// 按保留策略清理过期数据
+	background(retention.New(cfg.Retention, store).Run)
+
+	// 启动抓包与流水线统计采集
+	statsInterval := time.Duration(cfg.Performance.StatsInterval) * time.Second
+	if statsInterval <= 0 {
+		statsInterval = 10 * time.Second
+	}
+	collector := metrics.NewCollector(metrics.Pipeline, captureStatsFunc(workerSources), statsInterval)
+	server.SetStatsCollector(collector)
+	background(collector.Run)
+	if eveLog.Enabled(loader.EveTypeStats) {
+		eveInterval := time.Duration(cfg.Eve.StatsInterval) * time.Second
+		if eveInterval <= 0 {
+			eveInterval = 30 * time.Second
+		}
+		background(func(stop <-chan struct{}) { eveLog.Run(collector, eveInterval, stop) })
+	}
 
 	// 10. 处理退出信号
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	logrus.Infof("开始在接口 %s 上监听流量...", cfg.Capture.Interface)
+	if inline {
+		logrus.Infof("内联模式: 开始处理 NFQUEUE %d 的流量 (fail_open=%v)...",
+			cfg.Capture.NFQueue.QueueNum, cfg.Capture.NFQueue.FailOpen)
+	} else {
+		logrus.Infof("开始在接口 %s 上监听流量...", strings.Join(cfg.Capture.Interface.Names(), ", "))
+	}
 
 	// 11. 解析家庭网络CIDR
 	var homeNets []*net.IPNet
@@ -161,12 +402,6 @@ func main() {
 		}
 	}
 
-	// 12. 主数据包处理循环
-	var packets <-chan gopacket.Packet
-	if pktSource != nil {
-		packets = pktSource.Packets()
-	}
-
 	isHomeNet := func(ipStr string) bool {
 		ip := net.ParseIP(ipStr)
 		if ip == nil {
@@ -180,61 +415,262 @@ func main() {
 		return false
 	}
 
-	for {
-		select {
-		case <-sigChan:
-			logrus.Info("接收到停止信号，正在退出...")
-			close(stopChan)
-			return
-		case packet := <-packets:
-			if packet == nil {
+	// 12. 启动数据包处理协程
+	for _, pktSource := range pktSources {
+		go processPackets(pktSource, flowMgr, responder, isHomeNet)
+	}
+
+	// SIGHUP 重新加载 TLS 证书
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if !cfg.Server.TLS.Enabled {
 				continue
 			}
+			if err := server.ReloadTLS(); err != nil {
+				logrus.Errorf("重新加载 TLS 证书失败，继续使用旧证书: %v", err)
+			} else {
+				logrus.Info("TLS 证书已重新加载")
+			}
+		}
+	}()
+
+	select {
+	case <-sigChan:
+		logrus.Info("接收到停止信号，正在退出...")
+	case err := <-serverErr:
+		logrus.Errorf("Web Server 异常退出，正在停止传感器: %v", err)
+	}
+	// 先停止 Web 服务，等待进行中的请求 (如导出) 完成
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.Warnf("Web Server 关闭超时: %v", err)
+	}
+	cancel()
+	// 停止后台协程并等待检测协程写完最后一批流记录，之后才关闭数据库
+	close(stopChan)
+	workers.Wait()
+	logrus.Info("已停止")
+}
 
-			// 解码包
-			decoded, err := pktDecoder.Decode(packet)
-			if err != nil || decoded == nil {
+// openCaptureSources 按配置的后端打开所有接口，返回每个处理协程负责的抓包源
+// nfqueue 后端只有一个队列、pcap 后端与未启用 fanout 的 afpacket 后端也只有一个处理协程；
+// afpacket fanout 时每个接口打开 decoder_workers 个套接字，第 i 个协程负责各接口的第 i 个套接字，
+// 内核按对称哈希分流，同一条流始终落在同一个协程中
+func openCaptureSources(cfg *loader.Config) [][]capture.PacketSource {
+	if cfg.Capture.Backend == loader.CaptureBackendNFQueue {
+		src, err := capture.NewNFQueueSource(capture.NFQueueOptions{
+			QueueNum:    cfg.Capture.NFQueue.QueueNum,
+			MaxQueueLen: cfg.Capture.NFQueue.MaxQueueLen,
+			Snaplen:     cfg.Capture.Snaplen,
+			QueueSize:   cfg.Performance.PacketQueueSize,
+			FailOpen:    cfg.Capture.NFQueue.FailOpen,
+		})
+		if err != nil {
+			logrus.Errorf("无法打开 NFQUEUE: %v", err)
+			return nil
+		}
+		return [][]capture.PacketSource{{src}}
+	}
+
+	workers := 1
+	if cfg.Capture.Backend == loader.CaptureBackendAFPacket && cfg.Capture.AFPacket.Fanout {
+		workers = cfg.Performance.DecoderWorkers
+	}
+	workerSources := make([][]capture.PacketSource, workers)
+
+	for i, iface := range cfg.Capture.Interfaces() {
+		var sources []capture.PacketSource
+		if cfg.Capture.Backend == loader.CaptureBackendAFPacket {
+			var err error
+			sources, err = capture.NewAFPacketSources(capture.AFPacketOptions{
+				Interface:    iface.Name,
+				Snaplen:      cfg.Capture.Snaplen,
+				Promiscuous:  cfg.Capture.Promiscuous,
+				BPFFilter:    iface.BPFFilter,
+				BlockSize:    cfg.Capture.AFPacket.BlockSize,
+				NumBlocks:    cfg.Capture.AFPacket.NumBlocks,
+				BlockTimeout: time.Duration(cfg.Capture.AFPacket.BlockTimeout) * time.Millisecond,
+				Fanout:       cfg.Capture.AFPacket.Fanout,
+				// fanout 组只能包含绑定到同一网卡的套接字，每个接口使用独立的组 ID
+				FanoutGroup: cfg.Capture.AFPacket.FanoutGroup + uint16(i),
+			}, workers)
+			if err != nil {
+				logrus.Errorf("无法打开捕获设备 %s: %v", iface.Name, err)
+				continue
+			}
+		} else {
+			src, err := capture.NewPcapSource(
+				iface.Name,
+				int32(cfg.Capture.Snaplen),
+				cfg.Capture.Promiscuous,
+			)
+			if err != nil {
+				logrus.Errorf("无法打开捕获设备 %s: %v", iface.Name, err)
 				continue
 			}
+			if iface.BPFFilter != "" {
+				if err := src.SetBPFFilter(iface.BPFFilter); err != nil {
+					logrus.Fatalf("接口 %s: %v", iface.Name, err)
+				}
+			}
+			sources = []capture.PacketSource{src}
+		}
 
-			// 流量统计 logic
-			length := packet.Metadata().CaptureLength
-			srcHome := isHomeNet(decoded.SrcIP)
-			dstHome := isHomeNet(decoded.DstIP)
+		logrus.Infof("接口 %s 链路层类型: %s, BPF 过滤器: %q, 套接字数: %d",
+			iface.Name, sources[0].LinkType(), iface.BPFFilter, len(sources))
+		for j, src := range sources {
+			workerSources[j] = append(workerSources[j], src)
+		}
+	}
 
-			// Upload: Src is Home (Outgoing)
-			// Download: Dst is Home (Incoming)
-			// If both Home -> Internal (Count as both or pick one? Let's count as both for total throughput viz)
-			// If neither -> Transit (Count as In?)
-			// Simple logic:
-			inVal, outVal := 0, 0
+	return workerSources
+}
 
-			if srcHome {
-				outVal = length // We sent it
-			}
-			if dstHome {
-				inVal = length // We received it
+// captureStatsFunc 返回读取所有抓包源统计的函数，同名接口 (fanout 套接字) 的统计合并
+func captureStatsFunc(workerSources [][]capture.PacketSource) metrics.CaptureStatsFunc {
+	var sources []capture.StatsSource
+	for _, ws := range workerSources {
+		for _, src := range ws {
+			if ss, ok := src.(capture.StatsSource); ok {
+				sources = append(sources, ss)
 			}
+		}
+	}
 
-			// Transit traffic fallback (e.g. bridging)
-			if !srcHome && !dstHome {
-				inVal = length // Assume everything foreign is incoming if we see it? Or just ignore direction.
+	return func() []metrics.CaptureStats {
+		var result []metrics.CaptureStats
+		index := make(map[string]int)
+		for _, src := range sources {
+			s, err := src.GetStats()
+			if err != nil {
+				logrus.Debugf("获取接口 %s 抓包统计失败: %v", src.Name(), err)
+				continue
+			}
+			i, ok := index[src.Name()]
+			if !ok {
+				i = len(result)
+				index[src.Name()] = i
+				result = append(result, metrics.CaptureStats{Interface: src.Name()})
 			}
+			result[i].Received += s.PacketsReceived
+			result[i].Dropped += s.PacketsDropped
+			result[i].IfDropped += s.PacketsIfDropped
+		}
+		return result
+	}
+}
+
+// processPackets 是数据包处理协程: 解码、流量统计并更新流状态
+// 内联模式下每个数据包都在处理后得到且只得到一次裁决
+func processPackets(pktSource *capture.MultiSource, flowMgr *flow.Manager, responder *response.Responder, isHomeNet func(string) bool) {
+	// 解码器不是并发安全的，每个协程按接口各自持有
+	// 根据抓包句柄的链路类型选择解码起始层 (以太网、SLL、裸 IP、环回等)
+	decoders := make(map[string]*decoder.Decoder)
+	for _, src := range pktSource.Sources() {
+		pktDecoder, err := decoder.NewDecoderForLinkType(src.LinkType())
+		if err != nil {
+			logrus.Fatalf("接口 %s 初始化解码器失败: %v", src.Name(), err)
+		}
+		decoders[src.Name()] = pktDecoder
+	}
 
-			server.AddTraffic(inVal, outVal)
+	for packet := range pktSource.Packets() {
+		if packet == nil {
+			continue
+		}
 
-			// 创建流键
-			key := flow.FlowKey{
-				SrcIP:   decoded.SrcIP,
-				DstIP:   decoded.DstIP,
-				SrcPort: decoded.SrcPort,
-				DstPort: decoded.DstPort,
-				Proto:   layers.IPProtocol(decoded.Protocol),
+		verdict := inspectPacket(packet, decoders, flowMgr, responder, isHomeNet)
+		submitted, err := capture.SetVerdict(packet, verdict)
+		if err != nil {
+			logrus.Debugf("提交裁决失败: %v", err)
+		}
+		if submitted {
+			if verdict == capture.VerdictDrop {
+				metrics.Pipeline.VerdictsDropped.Add(1)
+			} else {
+				metrics.Pipeline.VerdictsAccepted.Add(1)
 			}
+		}
+	}
+}
+
+// inspectPacket 处理单个数据包并返回裁决 (被动抓包模式下裁决被忽略)
+// 默认放行，无法解析的数据包也放行；源 IP 已被封禁或所属流已被判定为恶意时丢弃
+func inspectPacket(packet gopacket.Packet, decoders map[string]*decoder.Decoder, flowMgr *flow.Manager, responder *response.Responder, isHomeNet func(string) bool) capture.Verdict {
+	// 按入口接口选择解码器
+	ingress, _ := capture.IngressOf(packet)
+	pktDecoder, ok := decoders[ingress.Interface]
+	if !ok {
+		metrics.Pipeline.DecodeFailure(metrics.FailureUnknownInterface)
+		return capture.VerdictAccept
+	}
+	metrics.Pipeline.PacketsProcessed.Add(1)
 
-			// 获取或创建流，并更新状态
-			f, isForward := flowMgr.GetOrCreate(key, packet)
-			f.Update(packet, isForward)
+	// 解码包，失败按原因计数
+	decoded, err := pktDecoder.Decode(packet)
+	if err != nil {
+		reason := decoder.FailureMalformed
+		var decodeErr *decoder.DecodeError
+		if errors.As(err, &decodeErr) {
+			reason = decodeErr.Reason
 		}
+		metrics.Pipeline.DecodeFailure(reason)
+		return capture.VerdictAccept
+	}
+	if decoded == nil {
+		metrics.Pipeline.DecodeFailure(metrics.FailureNonIP)
+		return capture.VerdictAccept
+	}
+
+	// 流量统计 logic
+	length := packet.Metadata().CaptureLength
+	srcHome := isHomeNet(decoded.SrcIP)
+	dstHome := isHomeNet(decoded.DstIP)
+
+	// Upload: Src is Home (Outgoing)
+	// Download: Dst is Home (Incoming)
+	// If both Home -> Internal (Count as both or pick one? Let's count as both for total throughput viz)
+	// If neither -> Transit (Count as In?)
+	// Simple logic:
+	inVal, outVal := 0, 0
+
+	if srcHome {
+		outVal = length // We sent it
+	}
+	if dstHome {
+		inVal = length // We received it
+	}
+
+	// Transit traffic fallback (e.g. bridging)
+	if !srcHome && !dstHome {
+		inVal = length // Assume everything foreign is incoming if we see it? Or just ignore direction.
+	}
+
+	server.AddTraffic(inVal, outVal)
+
+	// 已封禁的源不再参与流统计，避免重复告警
+	if responder.IsBlocked(decoded.SrcIP) {
+		return capture.VerdictDrop
+	}
+
+	// 创建流键
+	key := flow.FlowKey{
+		SrcIP:   decoded.SrcIP,
+		DstIP:   decoded.DstIP,
+		SrcPort: decoded.SrcPort,
+		DstPort: decoded.DstPort,
+		Proto:   layers.IPProtocol(decoded.Protocol),
+	}
+
+	// 获取或创建流，并更新状态
+	f, isForward := flowMgr.GetOrCreate(key, packet)
+	f.SetInterface(ingress.Interface)
+	f.Update(packet, isForward)
+
+	if f.IsMalicious() {
+		return capture.VerdictDrop
 	}
+	return capture.VerdictAccept
 }
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-ids/internal/loader"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultTokenTTL 未配置 token_ttl 时令牌的有效期
+const DefaultTokenTTL = 12 * time.Hour
+
+// APIKeyPrefix 生成的 API Key 的前缀，便于在 Authorization 头中与令牌区分
+const APIKeyPrefix = "ids_"
+
+// 认证方式
+const (
+	MethodNone       = "none" // 未开启认证
+	MethodPassword   = "password"
+	MethodAPIKey     = "api_key"
+	MethodClientCert = "client_cert" // mTLS 客户端证书
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenExpired       = errors.New("token expired")
+)
+
+// roleLevels 角色的权限等级，高等级包含低等级的全部权限
+var roleLevels = map[string]int{
+	loader.RoleViewer:  1,
+	loader.RoleAnalyst: 2,
+	loader.RoleAdmin:   3,
+}
+
+// Identity 已认证的调用方
+type Identity struct {
+	Name   string `json:"name"` // 用户名或 API Key 名称，未开启认证时为空
+	Role   string `json:"role"`
+	Method string `json:"method"`
+}
+
+// Allows 判断身份是否具有 role 及以上的权限
+func (i Identity) Allows(role string) bool {
+	level, ok := roleLevels[role]
+	return ok && roleLevels[i.Role] >= level
+}
+
+// Anonymous 未开启认证时所有请求使用的身份
+var Anonymous = Identity{Role: loader.RoleAdmin, Method: MethodNone}
+
+// Authenticator 校验用户名密码、API Key 与令牌
+type Authenticator struct {
+	users  map[string]loader.AuthUser
+	keys   map[[sha256.Size]byte]Identity
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// dummyHash 用户不存在时也做一次 bcrypt 比较，使响应时间不暴露用户是否存在
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("go-ids"), bcrypt.DefaultCost)
+
+// New 根据配置创建认证器，未配置 token_secret 时随机生成签名密钥
+func New(cfg loader.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		users:  make(map[string]loader.AuthUser, len(cfg.Users)),
+		keys:   make(map[[sha256.Size]byte]Identity, len(cfg.APIKeys)),
+		secret: []byte(cfg.TokenSecret),
+		ttl:    time.Duration(cfg.TokenTTL) * time.Second,
+		now:    time.Now,
+	}
+	if a.ttl == 0 {
+		a.ttl = DefaultTokenTTL
+	}
+	if len(a.secret) == 0 {
+		a.secret = make([]byte, 32)
+		if _, err := rand.Read(a.secret); err != nil {
+			return nil, fmt.Errorf("failed to generate token secret: %w", err)
+		}
+	}
+	for _, u := range cfg.Users {
+		a.users[u.Username] = u
+	}
+	for _, k := range cfg.APIKeys {
+		b, err := hex.DecodeString(k.KeySHA256)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid key_sha256 for API key %s", k.Name)
+		}
+		var sum [sha256.Size]byte
+		copy(sum[:], b)
+		a.keys[sum] = Identity{Name: k.Name, Role: k.Role, Method: MethodAPIKey}
+	}
+	return a, nil
+}
+
+// TTL 返回令牌有效期
+func (a *Authenticator) TTL() time.Duration {
+	return a.ttl
+}
+
+// Login 校验用户名与密码
+func (a *Authenticator) Login(username, password string) (Identity, error) {
+	u, ok := a.users[username]
+	hash := []byte(u.PasswordHash)
+	if !ok {
+		hash = dummyHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return Identity{}, ErrInvalidCredentials
+	}
+	return Identity{Name: u.Username, Role: u.Role, Method: MethodPassword}, nil
+}
+
+// APIKey 查找 API Key 对应的身份
+// 配置中只有摘要，按摘要查表即可，不需要逐个做常数时间比较
+func (a *Authenticator) APIKey(key string) (Identity, bool) {
+	id, ok := a.keys[sha256.Sum256([]byte(key))]
+	return id, ok
+}
+
+// tokenHeader 固定的 JWT 头，校验时要求完全一致，拒绝 alg=none 等其他算法
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// claims 令牌中的声明
+type claims struct {
+	Subject  string `json:"sub"`
+	Role     string `json:"role"`
+	IssuedAt int64  `json:"iat"`
+	Expires  int64  `json:"exp"`
+}
+
+// IssueToken 为登录用户签发 HS256 JWT，返回令牌与过期时间
+func (a *Authenticator) IssueToken(id Identity) (string, time.Time, error) {
+	now := a.now()
+	expires := now.Add(a.ttl)
+	payload, err := json.Marshal(claims{Subject: id.Name, Role: id.Role, IssuedAt: now.Unix(), Expires: expires.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signed := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + a.sign(signed), expires, nil
+}
+
+// VerifyToken 校验令牌签名与有效期
+// 角色以当前配置为准，配置中删除的用户签发过的令牌随即失效
+func (a *Authenticator) VerifyToken(token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return Identity{}, ErrInvalidToken
+	}
+	if subtle.ConstantTimeCompare([]byte(parts[2]), []byte(a.sign(parts[0]+"."+parts[1]))) != 1 {
+		return Identity{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Identity{}, ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Identity{}, ErrInvalidToken
+	}
+	if a.now().Unix() >= c.Expires {
+		return Identity{}, ErrTokenExpired
+	}
+	u, ok := a.users[c.Subject]
+	if !ok {
+		return Identity{}, ErrInvalidToken
+	}
+	return Identity{Name: u.Username, Role: u.Role, Method: MethodPassword}, nil
+}
+
+func (a *Authenticator) sign(s string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// HashPassword 生成配置文件中使用的 bcrypt 密码哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// BootstrapUser 生成随机密码的临时 admin 用户，用于开启认证但没有配置任何用户或 API Key 的情况
+func BootstrapUser() (user loader.AuthUser, password string, err error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return user, "", err
+	}
+	password = base64.RawURLEncoding.EncodeToString(b)
+	hash, err := HashPassword(password)
+	if err != nil {
+		return user, "", err
+	}
+	return loader.AuthUser{Username: "admin", PasswordHash: hash, Role: loader.RoleAdmin}, password, nil
+}
+
+// NewAPIKey 生成随机 API Key 及其写入配置的 SHA-256 摘要
+func NewAPIKey() (key, sum string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey 返回 API Key 的十六进制 SHA-256 摘要
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-ids/internal/loader"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, string) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, sum, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(loader.AuthConfig{
+		Enabled:  true,
+		TokenTTL: 60,
+		Users:    []loader.AuthUser{{Username: "alice", PasswordHash: string(hash), Role: loader.RoleAnalyst}},
+		APIKeys:  []loader.APIKeyConfig{{Name: "siem", KeySHA256: sum, Role: loader.RoleViewer}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, key
+}
+
+func TestRoles(t *testing.T) {
+	analyst := Identity{Role: loader.RoleAnalyst}
+	if !analyst.Allows(loader.RoleViewer) || !analyst.Allows(loader.RoleAnalyst) || analyst.Allows(loader.RoleAdmin) {
+		t.Error("analyst permissions are wrong")
+	}
+	if (Identity{Role: "root"}).Allows(loader.RoleViewer) || analyst.Allows("") {
+		t.Error("unknown roles must not be allowed")
+	}
+	if !Anonymous.Allows(loader.RoleAdmin) {
+		t.Error("anonymous identity must be admin when authentication is disabled")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	a, _ := newTestAuthenticator(t)
+	id, err := a.Login("alice", "s3cret")
+	if err != nil || id.Name != "alice" || id.Role != loader.RoleAnalyst || id.Method != MethodPassword {
+		t.Fatalf("Login() = %+v, %v", id, err)
+	}
+	if _, err := a.Login("alice", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("wrong password: %v", err)
+	}
+	if _, err := a.Login("bob", "s3cret"); err != ErrInvalidCredentials {
+		t.Errorf("unknown user: %v", err)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	a, key := newTestAuthenticator(t)
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		t.Errorf("key %q lacks the prefix", key)
+	}
+	id, ok := a.APIKey(key)
+	if !ok || id.Name != "siem" || id.Role != loader.RoleViewer || id.Method != MethodAPIKey {
+		t.Fatalf("APIKey() = %+v, %v", id, ok)
+	}
+	if _, ok := a.APIKey(key + "x"); ok {
+		t.Error("modified key accepted")
+	}
+}
+
+func TestToken(t *testing.T) {
+	a, _ := newTestAuthenticator(t)
+	now := time.Unix(1700000000, 0)
+	a.now = func() time.Time { return now }
+
+	id, _ := a.Login("alice", "s3cret")
+	token, expires, err := a.IssueToken(id)
+	if err != nil || !expires.Equal(now.Add(time.Minute)) {
+		t.Fatalf("IssueToken() = %s, %v, %v", token, expires, err)
+	}
+	if got, err := a.VerifyToken(token); err != nil || got != id {
+		t.Fatalf("VerifyToken() = %+v, %v", got, err)
+	}
+
+	// 篡改载荷或签名
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + strings.Replace(parts[1], parts[1][:4], "AAAA", 1) + "." + parts[2]
+	if _, err := a.VerifyToken(forged); err != ErrInvalidToken {
+		t.Errorf("forged payload: %v", err)
+	}
+	if _, err := a.VerifyToken(parts[0] + "." + parts[1] + "."); err != ErrInvalidToken {
+		t.Errorf("missing signature: %v", err)
+	}
+	// alg=none 的令牌
+	if _, err := a.VerifyToken("eyJhbGciOiJub25lIn0." + parts[1] + "."); err != ErrInvalidToken {
+		t.Errorf("alg none: %v", err)
+	}
+
+	// 其他密钥签发的令牌
+	other, _ := newTestAuthenticator(t)
+	other.now = a.now
+	foreign, _, _ := other.IssueToken(id)
+	if _, err := a.VerifyToken(foreign); err != ErrInvalidToken {
+		t.Errorf("token signed with another secret: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := a.VerifyToken(token); err != ErrTokenExpired {
+		t.Errorf("expired token: %v", err)
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("pw")
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(hash), []byte("pw")) != nil {
+		t.Errorf("HashPassword() = %s, %v", hash, err)
+	}
+	if HashAPIKey("test") != "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+		t.Error("unexpected SHA-256 digest")
+	}
+}
+
+func TestBootstrapUser(t *testing.T) {
+	user, password, err := BootstrapUser()
+	if err != nil || user.Role != loader.RoleAdmin || len(password) < 16 {
+		t.Fatalf("BootstrapUser() = %+v, %q, %v", user, password, err)
+	}
+	a, err := New(loader.AuthConfig{Enabled: true, Users: []loader.AuthUser{user}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := a.Login(user.Username, password); err != nil || id.Role != loader.RoleAdmin {
+		t.Errorf("Login() = %+v, %v", id, err)
+	}
+	if _, other, _ := BootstrapUser(); other == password {
+		t.Error("bootstrap passwords repeat")
+	}
+}
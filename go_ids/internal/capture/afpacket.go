@@ -0,0 +1,16 @@
+package capture
+
+import "time"
+
+// AFPacketOptions AF_PACKET (TPACKET_V3) 抓包参数
+type AFPacketOptions struct {
+	Interface    string
+	Snaplen      int
+	Promiscuous  bool
+	BPFFilter    string
+	BlockSize    int           // 环形缓冲区每块字节数，需为页大小的整数倍，0 表示使用默认值
+	NumBlocks    int           // 环形缓冲区块数，0 表示使用默认值
+	BlockTimeout time.Duration // 未写满的块在超时后也交给用户态，0 表示使用默认值
+	Fanout       bool          // 启用 PACKET_FANOUT 哈希分流
+	FanoutGroup  uint16        // fanout 组 ID，同组套接字 (可跨进程) 共享同一接口的流量
+}
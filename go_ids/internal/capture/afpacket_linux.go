@@ -0,0 +1,213 @@
+//go:build linux
+
+package capture
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"go-ids/internal/decoder"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/afpacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// AFPacketSource 是基于内存映射 AF_PACKET (TPACKET_V3) 的抓包源
+type AFPacketSource struct {
+	name      string
+	linkType  layers.LinkType
+	tpacket   *afpacket.TPacket
+	source    *gopacket.PacketSource
+	promiscFD int
+}
+
+// NewAFPacketSources 在一个接口上打开 AF_PACKET 抓包源
+// 启用 fanout 时打开 workers 个套接字并加入同一 PACKET_FANOUT_HASH 组，
+// 内核按五元组哈希把同一条流固定分给同一个套接字，每个套接字可交给独立的处理协程
+func NewAFPacketSources(opts AFPacketOptions, workers int) ([]PacketSource, error) {
+	linkType, err := interfaceLinkType(opts.Interface)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter []bpf.RawInstruction
+	if opts.BPFFilter != "" {
+		filter, err = compileBPF(linkType, opts.Snaplen, opts.BPFFilter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.Fanout || workers < 1 {
+		workers = 1
+	}
+
+	var sources []PacketSource
+	closeAll := func() {
+		for _, src := range sources {
+			src.Close()
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		src, err := newAFPacketSource(opts, linkType, filter)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sources = append(sources, src)
+
+		if opts.Fanout {
+			if err := src.tpacket.SetFanout(afpacket.FanoutHash|afpacket.FanoutHashWithDefrag, opts.FanoutGroup); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("接口 %s 加入 fanout 组 %d 失败: %v", opts.Interface, opts.FanoutGroup, err)
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+func newAFPacketSource(opts AFPacketOptions, linkType layers.LinkType, filter []bpf.RawInstruction) (*AFPacketSource, error) {
+	tpOpts := []interface{}{
+		afpacket.OptInterface(opts.Interface),
+		afpacket.TPacketVersion3,
+	}
+	if opts.BlockSize > 0 {
+		tpOpts = append(tpOpts, afpacket.OptBlockSize(opts.BlockSize))
+	}
+	if opts.NumBlocks > 0 {
+		tpOpts = append(tpOpts, afpacket.OptNumBlocks(opts.NumBlocks))
+	}
+	if opts.BlockTimeout > 0 {
+		tpOpts = append(tpOpts, afpacket.OptBlockTimeout(opts.BlockTimeout))
+	}
+
+	tpacket, err := afpacket.NewTPacket(tpOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("无法在接口 %s 上打开 AF_PACKET: %v", opts.Interface, err)
+	}
+
+	src := &AFPacketSource{
+		name:      opts.Interface,
+		linkType:  linkType,
+		tpacket:   tpacket,
+		promiscFD: -1,
+	}
+
+	if filter != nil {
+		if err := tpacket.SetBPF(filter); err != nil {
+			src.Close()
+			return nil, fmt.Errorf("设置 BPF 过滤器 %q 失败: %v", opts.BPFFilter, err)
+		}
+	}
+
+	if opts.Promiscuous {
+		if err := src.enablePromisc(); err != nil {
+			src.Close()
+			return nil, err
+		}
+	}
+
+	src.source = gopacket.NewPacketSource(tpacket, decoder.LinkDecoder(linkType))
+	return src, nil
+}
+
+// enablePromisc 通过 PACKET_MR_PROMISC 成员关系开启混杂模式
+// 成员关系随套接字关闭自动撤销，不会在进程退出后残留在网卡上
+func (a *AFPacketSource) enablePromisc() error {
+	iface, err := net.InterfaceByName(a.name)
+	if err != nil {
+		return fmt.Errorf("查找接口 %s 失败: %v", a.name, err)
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, 0)
+	if err != nil {
+		return fmt.Errorf("创建混杂模式套接字失败: %v", err)
+	}
+	mreq := &unix.PacketMreq{Ifindex: int32(iface.Index), Type: unix.PACKET_MR_PROMISC}
+	if err := unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq); err != nil {
+		unix.Close(fd)
+		return fmt.Errorf("接口 %s 开启混杂模式失败: %v", a.name, err)
+	}
+	a.promiscFD = fd
+	return nil
+}
+
+// Packets 返回数据包通道
+func (a *AFPacketSource) Packets() <-chan gopacket.Packet {
+	return a.source.Packets()
+}
+
+// Name 返回接口名
+func (a *AFPacketSource) Name() string {
+	return a.name
+}
+
+// LinkType 返回接口的链路层类型
+func (a *AFPacketSource) LinkType() layers.LinkType {
+	return a.linkType
+}
+
+// Close 关闭抓包源
+func (a *AFPacketSource) Close() {
+	if a.tpacket != nil {
+		a.tpacket.Close()
+	}
+	if a.promiscFD >= 0 {
+		unix.Close(a.promiscFD)
+		a.promiscFD = -1
+	}
+}
+
+// GetStats 获取内核环形缓冲区的收包与丢包统计
+func (a *AFPacketSource) GetStats() (Stats, error) {
+	_, v3, err := a.tpacket.SocketStats()
+	if err != nil {
+		return Stats{}, err
+	}
+	return Stats{
+		PacketsReceived: int64(v3.Packets()),
+		PacketsDropped:  int64(v3.Drops()),
+	}, nil
+}
+
+// interfaceLinkType 根据 /sys/class/net/<if>/type 中的 ARPHRD 类型确定链路层类型
+// SOCK_RAW 套接字在以太网和环回接口上带以太网首部，在 tun/wireguard 等接口上为裸 IP
+func interfaceLinkType(name string) (layers.LinkType, error) {
+	data, err := os.ReadFile("/sys/class/net/" + name + "/type")
+	if err != nil {
+		return 0, fmt.Errorf("无法确定接口 %s 的类型: %v", name, err)
+	}
+	arphrd, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("无法解析接口 %s 的类型: %v", name, err)
+	}
+
+	switch arphrd {
+	case unix.ARPHRD_ETHER, unix.ARPHRD_LOOPBACK:
+		return layers.LinkTypeEthernet, nil
+	case unix.ARPHRD_NONE, unix.ARPHRD_PPP, unix.ARPHRD_TUNNEL, unix.ARPHRD_TUNNEL6:
+		return layers.LinkTypeRaw, nil
+	}
+	return 0, fmt.Errorf("afpacket 后端不支持接口 %s 的链路类型 (ARPHRD %d)", name, arphrd)
+}
+
+// compileBPF 借助 libpcap 把过滤表达式编译为经典 BPF 指令
+func compileBPF(linkType layers.LinkType, snaplen int, expr string) ([]bpf.RawInstruction, error) {
+	insns, err := pcap.CompileBPFFilter(linkType, snaplen, expr)
+	if err != nil {
+		return nil, fmt.Errorf("编译 BPF 过滤器 %q 失败: %v", expr, err)
+	}
+	raw := make([]bpf.RawInstruction, len(insns))
+	for i, insn := range insns {
+		raw[i] = bpf.RawInstruction{Op: insn.Code, Jt: insn.Jt, Jf: insn.Jf, K: insn.K}
+	}
+	return raw, nil
+}
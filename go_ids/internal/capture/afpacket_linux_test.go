@@ -0,0 +1,29 @@
+//go:build linux
+
+package capture
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+func TestInterfaceLinkType(t *testing.T) {
+	if _, err := os.Stat("/sys/class/net/lo"); err != nil {
+		t.Skip("跳过测试：系统中没有 lo 接口")
+	}
+
+	// 环回接口在 AF_PACKET SOCK_RAW 下带以太网首部
+	linkType, err := interfaceLinkType("lo")
+	if err != nil {
+		t.Fatalf("interfaceLinkType(lo) failed: %v", err)
+	}
+	if linkType != layers.LinkTypeEthernet {
+		t.Errorf("Expected Ethernet for lo, got %s", linkType)
+	}
+
+	if _, err := interfaceLinkType("ids-no-such-if0"); err == nil {
+		t.Error("Expected error for missing interface")
+	}
+}
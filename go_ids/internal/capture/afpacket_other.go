@@ -0,0 +1,13 @@
+//go:build !linux
+
+package capture
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// NewAFPacketSources 在非 Linux 平台上不可用
+func NewAFPacketSources(opts AFPacketOptions, workers int) ([]PacketSource, error) {
+	return nil, fmt.Errorf("afpacket 抓包后端仅支持 Linux，当前系统为 %s", runtime.GOOS)
+}
@@ -2,16 +2,27 @@ package capture
 
 import (
 	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 )
 
 // PacketSource 定义了数据包获取的通用接口
 type PacketSource interface {
 	// Packets 返回一个用于接收数据包的通道
 	Packets() <-chan gopacket.Packet
+	// Name 返回抓包源名称 (网卡名或文件名)
+	Name() string
+	// LinkType 返回抓包源的链路层类型，用于选择解码起始层
+	LinkType() layers.LinkType
 	// Close 关闭抓包源
 	Close()
 }
 
+// StatsSource 能够提供收包/丢包统计的抓包源
+type StatsSource interface {
+	Name() string
+	GetStats() (Stats, error)
+}
+
 // Stats 提供抓包统计信息
 type Stats struct {
 	PacketsReceived  int64
@@ -0,0 +1,72 @@
+package capture
+
+import (
+	"sync/atomic"
+
+	"github.com/google/gopacket"
+)
+
+// Verdict 内联 (IPS) 模式下对数据包的裁决
+type Verdict int
+
+const (
+	VerdictAccept Verdict = iota // 放行
+	VerdictDrop                  // 丢弃
+)
+
+func (v Verdict) String() string {
+	if v == VerdictDrop {
+		return "drop"
+	}
+	return "accept"
+}
+
+// verdictSetter 由内联抓包源实现，把裁决提交给内核
+type verdictSetter interface {
+	setVerdict(id uint32, v Verdict) error
+}
+
+// pendingVerdict 附加在内联抓包源数据包的 CaptureInfo.AncillaryData 中
+// done 在所有副本间共享，保证每个包只裁决一次
+type pendingVerdict struct {
+	id     uint32
+	setter verdictSetter
+	done   *atomic.Bool
+}
+
+func newPendingVerdict(id uint32, setter verdictSetter) pendingVerdict {
+	return pendingVerdict{id: id, setter: setter, done: new(atomic.Bool)}
+}
+
+// submit 提交裁决，已裁决过的包返回 false
+func (p pendingVerdict) submit(v Verdict) (bool, error) {
+	if !p.done.CompareAndSwap(false, true) {
+		return false, nil
+	}
+	return true, p.setter.setVerdict(p.id, v)
+}
+
+// IsInline 报告数据包是否来自内联抓包源 (需要裁决)
+func IsInline(pkt gopacket.Packet) bool {
+	_, ok := pendingVerdictOf(pkt)
+	return ok
+}
+
+// SetVerdict 对内联抓包源的数据包提交裁决，被动抓包的数据包直接忽略
+// 同一个包只有第一次裁决生效，返回本次是否提交了裁决
+func SetVerdict(pkt gopacket.Packet, v Verdict) (bool, error) {
+	pv, ok := pendingVerdictOf(pkt)
+	if !ok {
+		return false, nil
+	}
+	return pv.submit(v)
+}
+
+func pendingVerdictOf(pkt gopacket.Packet) (pendingVerdict, bool) {
+	for _, v := range pkt.Metadata().AncillaryData {
+		if pv, ok := v.(pendingVerdict); ok {
+			return pv, true
+		}
+	}
+	return pendingVerdict{}, false
+}
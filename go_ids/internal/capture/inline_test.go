@@ -0,0 +1,48 @@
+package capture
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+type fakeVerdictSetter struct {
+	verdicts map[uint32][]Verdict
+}
+
+func (f *fakeVerdictSetter) setVerdict(id uint32, v Verdict) error {
+	f.verdicts[id] = append(f.verdicts[id], v)
+	return nil
+}
+
+func TestSetVerdictOnce(t *testing.T) {
+	setter := &fakeVerdictSetter{verdicts: make(map[uint32][]Verdict)}
+
+	pkt := gopacket.NewPacket([]byte{0x45}, layers.LinkTypeRaw, gopacket.Default)
+	md := pkt.Metadata()
+	md.AncillaryData = append(md.AncillaryData, newPendingVerdict(7, setter), Ingress{Interface: "nfqueue:0"})
+
+	if !IsInline(pkt) {
+		t.Fatal("expected packet to be inline")
+	}
+	if ok, err := SetVerdict(pkt, VerdictDrop); !ok || err != nil {
+		t.Fatalf("first verdict: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := SetVerdict(pkt, VerdictAccept); ok {
+		t.Error("second verdict should be ignored")
+	}
+	if got := setter.verdicts[7]; len(got) != 1 || got[0] != VerdictDrop {
+		t.Errorf("verdicts = %v, want [drop]", got)
+	}
+}
+
+func TestSetVerdictPassive(t *testing.T) {
+	pkt := gopacket.NewPacket([]byte{0x45}, layers.LinkTypeRaw, gopacket.Default)
+	if IsInline(pkt) {
+		t.Error("passive packet reported as inline")
+	}
+	if ok, err := SetVerdict(pkt, VerdictDrop); ok || err != nil {
+		t.Errorf("passive verdict: ok=%v err=%v", ok, err)
+	}
+}
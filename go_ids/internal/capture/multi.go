@@ -0,0 +1,91 @@
+package capture
+
+import (
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Ingress 记录数据包的入口接口，附加在 CaptureInfo.AncillaryData 中
+type Ingress struct {
+	Interface string
+	LinkType  layers.LinkType
+}
+
+// IngressOf 返回数据包的入口接口信息
+func IngressOf(pkt gopacket.Packet) (Ingress, bool) {
+	for _, v := range pkt.Metadata().AncillaryData {
+		if ingress, ok := v.(Ingress); ok {
+			return ingress, true
+		}
+	}
+	return Ingress{}, false
+}
+
+// MultiSource 将多个抓包源合并为一个数据包流，并为每个包标记入口接口
+type MultiSource struct {
+	sources   []PacketSource
+	packets   chan gopacket.Packet
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewMultiSource 创建合并抓包源，queueSize 为合并后通道的缓冲大小
+func NewMultiSource(sources []PacketSource, queueSize int) *MultiSource {
+	m := &MultiSource{
+		sources: sources,
+		packets: make(chan gopacket.Packet, queueSize),
+		done:    make(chan struct{}),
+	}
+
+	for _, src := range sources {
+		m.wg.Add(1)
+		go m.forward(src)
+	}
+
+	// 所有抓包源结束后关闭合并通道
+	go func() {
+		m.wg.Wait()
+		close(m.packets)
+	}()
+
+	return m
+}
+
+func (m *MultiSource) forward(src PacketSource) {
+	defer m.wg.Done()
+
+	ingress := Ingress{Interface: src.Name(), LinkType: src.LinkType()}
+	for pkt := range src.Packets() {
+		md := pkt.Metadata()
+		md.AncillaryData = append(md.AncillaryData, ingress)
+
+		select {
+		case m.packets <- pkt:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Packets 返回合并后的数据包通道，所有抓包源结束后通道关闭
+func (m *MultiSource) Packets() <-chan gopacket.Packet {
+	return m.packets
+}
+
+// Sources 返回被合并的抓包源
+func (m *MultiSource) Sources() []PacketSource {
+	return m.sources
+}
+
+// Close 关闭所有抓包源
+func (m *MultiSource) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		for _, src := range m.sources {
+			src.Close()
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package capture
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// fakeSource 是用于测试的内存抓包源
+type fakeSource struct {
+	name     string
+	linkType layers.LinkType
+	packets  chan gopacket.Packet
+	closed   bool
+}
+
+func newFakeSource(name string, linkType layers.LinkType, n int) *fakeSource {
+	s := &fakeSource{name: name, linkType: linkType, packets: make(chan gopacket.Packet, n)}
+	for i := 0; i < n; i++ {
+		s.packets <- gopacket.NewPacket([]byte{0x45}, layers.LayerTypeIPv4, gopacket.Default)
+	}
+	close(s.packets)
+	return s
+}
+
+func (s *fakeSource) Packets() <-chan gopacket.Packet { return s.packets }
+func (s *fakeSource) Name() string                    { return s.name }
+func (s *fakeSource) LinkType() layers.LinkType       { return s.linkType }
+func (s *fakeSource) Close()                          { s.closed = true }
+
+func TestMultiSourceMergesAndTags(t *testing.T) {
+	eth := newFakeSource("eth1", layers.LinkTypeEthernet, 3)
+	tun := newFakeSource("tun0", layers.LinkTypeRaw, 2)
+	m := NewMultiSource([]PacketSource{eth, tun}, 10)
+	defer m.Close()
+
+	counts := make(map[string]int)
+	timeout := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case pkt, ok := <-m.Packets():
+			if !ok {
+				done = true
+				break
+			}
+			ingress, ok := IngressOf(pkt)
+			if !ok {
+				t.Fatal("Packet missing ingress tag")
+			}
+			if ingress.Interface == "tun0" && ingress.LinkType != layers.LinkTypeRaw {
+				t.Errorf("Expected raw link type for tun0, got %s", ingress.LinkType)
+			}
+			counts[ingress.Interface]++
+		case <-timeout:
+			t.Fatal("Timed out waiting for merged stream to close")
+		}
+	}
+
+	if counts["eth1"] != 3 || counts["tun0"] != 2 {
+		t.Errorf("Unexpected per-interface counts: %v", counts)
+	}
+
+	m.Close()
+	if !eth.closed || !tun.closed {
+		t.Error("Close should close all underlying sources")
+	}
+}
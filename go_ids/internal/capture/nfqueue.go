@@ -0,0 +1,12 @@
+package capture
+
+// NFQueueOptions NFQUEUE 内联抓包参数
+type NFQueueOptions struct {
+	QueueNum    uint16 // iptables/nftables 规则中的 queue 编号
+	MaxQueueLen uint32 // 内核队列最大长度，0 表示使用内核默认值
+	Snaplen     int    // 复制到用户态的最大字节数
+	QueueSize   int    // 用户态数据包通道的缓冲大小
+	// FailOpen 为 true 时，内核队列已满或用户态处理跟不上的数据包直接放行；
+	// 否则丢弃 (fail-closed)
+	FailOpen bool
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package capture
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// NewNFQueueSource 在非 Linux 平台上不可用
+func NewNFQueueSource(opts NFQueueOptions) (PacketSource, error) {
+	return nil, fmt.Errorf("nfqueue 抓包后端仅支持 Linux，当前系统为 %s", runtime.GOOS)
+}
@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"log"
 
+	"go-ids/internal/decoder"
+
 	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 )
 
 // PcapSource 是基于 pcap 库的实现
 type PcapSource struct {
+	name   string
 	handle *pcap.Handle
 	source *gopacket.PacketSource
 }
@@ -22,9 +26,10 @@ func NewPcapSource(device string, snaplen int32, promisc bool) (*PcapSource, err
 		return nil, fmt.Errorf("无法打开设备 %s: %v", device, err)
 	}
 
-	source := gopacket.NewPacketSource(handle, handle.LinkType())
-	
+	source := gopacket.NewPacketSource(handle, decoder.LinkDecoder(handle.LinkType()))
+
 	return &PcapSource{
+		name:   device,
 		handle: handle,
 		source: source,
 	}, nil
@@ -37,19 +42,38 @@ func NewFileSource(filename string) (*PcapSource, error) {
 		return nil, fmt.Errorf("无法打开 pcap 文件 %s: %v", filename, err)
 	}
 
-	source := gopacket.NewPacketSource(handle, handle.LinkType())
+	source := gopacket.NewPacketSource(handle, decoder.LinkDecoder(handle.LinkType()))
 
 	return &PcapSource{
+		name:   filename,
 		handle: handle,
 		source: source,
 	}, nil
 }
 
+// SetBPFFilter 为抓包句柄设置 BPF 过滤表达式
+func (p *PcapSource) SetBPFFilter(expr string) error {
+	if err := p.handle.SetBPFFilter(expr); err != nil {
+		return fmt.Errorf("设置 BPF 过滤器 %q 失败: %v", expr, err)
+	}
+	return nil
+}
+
+// Name 返回设备名或文件名
+func (p *PcapSource) Name() string {
+	return p.name
+}
+
 // Packets 返回数据包通道
 func (p *PcapSource) Packets() <-chan gopacket.Packet {
 	return p.source.Packets()
 }
 
+// LinkType 返回抓包句柄的链路层类型
+func (p *PcapSource) LinkType() layers.LinkType {
+	return p.handle.LinkType()
+}
+
 // Close 关闭抓包源
 func (p *PcapSource) Close() {
 	if p.handle != nil {
@@ -6,219 +6,341 @@ import (
 	"path/filepath"
 	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
 
-// InitDB initializes the SQLite database connection
-func InitDB(dbPath string) error {
-	var err error
+// Store is the GORM implementation of Repository
+type Store struct {
+	db     *gorm.DB
+	driver string
+	path   string // SQLite 数据库文件的绝对路径，PostgreSQL 时为空
+}
 
-	// Ensure directory exists (basic check, though sqlite usually creates file)
-	absPath, err := filepath.Abs(dbPath)
+// InitDB opens the database and applies the pending migrations
+func InitDB(driver, dsn string) (*Store, error) {
+	s, err := Open(driver, dsn)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path for db: %w", err)
+		return nil, err
+	}
+	if _, err := s.Migrate(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Open connects to the database without migrating it
+// For SQLite the DSN is the database file path, for PostgreSQL a libpq connection string or URL
+func Open(driver, dsn string) (*Store, error) {
+	s := &Store{driver: driver}
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverSQLite, "":
+		absPath, err := filepath.Abs(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path for db: %w", err)
+		}
+		s.driver = DriverSQLite
+		s.path = absPath
+		// New database files start in incremental auto-vacuum mode, existing ones are switched by EnableIncrementalVacuum
+		sqlDB, err := sql.Open(sqlite.DriverName, absPath+"?_auto_vacuum=incremental")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		dialector = sqlite.New(sqlite.Config{DSN: absPath, Conn: &utcConnPool{sqlDB}})
+	case DriverPostgres:
+		dialector = postgres.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
 	}
 
 	config := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Warn), // Reduce log noise
 	}
 
-	DB, err = gorm.Open(sqlite.Open(absPath), config)
+	var err error
+	s.db, err = gorm.Open(dialector, config)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// Connection Pool Settings
-	sqlDB, err := DB.DB()
+	sqlDB, err := s.db.DB()
 	if err != nil {
-		return fmt.Errorf("failed to get sql.DB: %w", err)
+		return nil, fmt.Errorf("failed to get sql.DB: %w", err)
 	}
 
 	sqlDB.SetMaxIdleConns(10)
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
+	return s, nil
+}
 
-	// Auto Migrate
-	err = DB.AutoMigrate(&Alert{})
+// Close closes the connection pool
+func (s *Store) Close() error {
+	sqlDB, err := s.db.DB()
 	if err != nil {
-		return fmt.Errorf("failed to migrate database schema: %w", err)
+		return err
 	}
+	return sqlDB.Close()
+}
 
-	// 注入测试数据或清理错误数据
-	var badCount int64
-	DB.Model(&Alert{}).Where("confidence > 1.0").Count(&badCount)
-	if badCount > 0 {
-		// 如果发现此前的脏数据，直接清空整个表并重新注入
-		DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Alert{})
-		seedMockAlerts()
-	} else {
-		var totalCount int64
-		DB.Model(&Alert{}).Count(&totalCount)
-		if totalCount == 0 {
-			seedMockAlerts()
-		}
-	}
+// Driver returns the database driver in use
+func (s *Store) Driver() string {
+	return s.driver
+}
 
-	return nil
-}
-
-// seedMockAlerts 专门为用户展示提供极具实战细节的演习用攻击日志
-func seedMockAlerts() {
-	now := time.Now()
-	alerts := []Alert{
-		{CreatedAt: now.Add(-12 * time.Hour), SourceIP: "104.26.6.57", DestIP: "192.168.1.100", Type: "DDoS", Confidence: 0.99, Payload: "GET / HTTP/1.1\r\nHost: target.com\r\nUser-Agent: Hulk/1.0\r\n\r\n"},
-		{CreatedAt: now.Add(-11 * time.Hour), SourceIP: "45.33.18.12", DestIP: "192.168.1.50", Type: "Web Attack", Confidence: 0.94, Payload: "POST /login.php HTTP/1.1\r\nContent-Type: application/x-www-form-urlencoded\r\n\r\nusername=admin' OR '1'='1&password=123"},
-		{CreatedAt: now.Add(-9 * time.Hour), SourceIP: "185.199.108.133", DestIP: "192.168.1.10", Type: "PortScan", Confidence: 0.88, Payload: "SYN Stealth Scan (Nmap) Packet Signature Detected. Window Size = 1024."},
-		{CreatedAt: now.Add(-8 * time.Hour), SourceIP: "117.72.62.10", DestIP: "192.168.1.100", Type: "Brute Force", Confidence: 0.92, Payload: "SSH-2.0-OpenSSH_8.2p1 Ubuntu-4ubuntu0.1\npassword matching failed"},
-		{CreatedAt: now.Add(-7 * time.Hour), SourceIP: "52.220.222.172", DestIP: "192.168.1.15", Type: "Bot", Confidence: 0.85, Payload: "GET /c2_command.php?id=9928 HTTP/1.1\r\nUser-Agent: Mozilla/5.0 (Windows NT 10.0; Win64; x64)"},
-		{CreatedAt: now.Add(-6 * time.Hour), SourceIP: "151.101.193.91", DestIP: "192.168.1.50", Type: "Web Attack", Confidence: 0.96, Payload: "GET /../../../../etc/passwd HTTP/1.1\r\nHost: example.com\r\n"},
-		{CreatedAt: now.Add(-5 * time.Hour), SourceIP: "142.250.71.131", DestIP: "192.168.1.20", Type: "PortScan", Confidence: 0.78, Payload: "UDP Scan detected on ports: 53, 161, 123"},
-		{CreatedAt: now.Add(-4 * time.Hour), SourceIP: "3.168.86.75", DestIP: "192.168.1.100", Type: "DDoS", Confidence: 0.97, Payload: "Volumetric SYN Flood. Packet Rate: 45000 pps"},
-		{CreatedAt: now.Add(-3 * time.Hour), SourceIP: "180.105.204.112", DestIP: "192.168.1.12", Type: "Brute Force", Confidence: 0.89, Payload: "FTP Login failed: 530 Login incorrect. User: anonymous"},
-		{CreatedAt: now.Add(-2 * time.Hour), SourceIP: "58.216.102.31", DestIP: "192.168.1.50", Type: "Web Attack", Confidence: 0.91, Payload: "GET /index.php?id=1 UNION SELECT null, version() HTTP/1.1\r\nHost: target\r\n"},
-		{CreatedAt: now.Add(-1 * time.Hour), SourceIP: "222.186.176.192", DestIP: "192.168.1.100", Type: "Bot", Confidence: 0.82, Payload: "Mirai Botnet signature detected. Hardcoded credential guess."},
-		{CreatedAt: now.Add(-20 * time.Minute), SourceIP: "114.237.67.68", DestIP: "192.168.1.50", Type: "Web Attack", Confidence: 0.95, Payload: "GET /?q=<script>alert('XSS')</script> HTTP/1.1\r\nHost: target\r\n"},
-	}
+// DB returns the underlying GORM handle for queries the repository does not cover
+func (s *Store) DB() *gorm.DB {
+	return s.db
+}
 
-	for _, a := range alerts {
-		DB.Create(&a)
-	}
+// Transaction runs fn in a database transaction and rolls it back when fn returns an error
+// The repository passed to fn issues all its queries inside the transaction
+func (s *Store) Transaction(fn func(tx IncidentRepository) error) error {
+	return s.transaction(func(tx *Store) error { return fn(tx) })
+}
+
+// transaction is Transaction for the Store's own multi-statement operations
+func (s *Store) transaction(fn func(tx *Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&Store{db: tx, driver: s.driver, path: s.path})
+	})
 }
 
 // CreateAlert saves a new alert to the database
-func CreateAlert(alert *Alert) error {
-	if DB == nil {
-		return fmt.Errorf("database not initialized")
-	}
-	result := DB.Create(alert)
+func (s *Store) CreateAlert(alert *Alert) error {
+	result := s.db.Create(alert)
 	return result.Error
 }
 
+// UpdateAlertAggregate saves the count, last-seen time and confidence of an aggregated alert
+// Concurrent updates may arrive out of order, so a stored count higher than alert.Count is kept
+func (s *Store) UpdateAlertAggregate(alert *Alert) error {
+	return s.db.Model(alert).Where("count < ?", alert.Count).Updates(map[string]interface{}{
+		"count":      alert.Count,
+		"last_seen":  alert.LastSeen,
+		"confidence": alert.Confidence,
+	}).Error
+}
+
 // GetRecentAlerts retrieves the latest N alerts
-func GetRecentAlerts(limit int) ([]Alert, error) {
+func (s *Store) GetRecentAlerts(limit int) ([]Alert, error) {
 	var alerts []Alert
-	result := DB.Order("created_at desc").Limit(limit).Find(&alerts)
+	result := s.db.Order("created_at desc").Limit(limit).Find(&alerts)
 	return alerts, result.Error
 }
 
-// StatsPoint represents a single data point in the chart
-type StatsPoint struct {
-	Label string `json:"label"` // Time label (e.g., "10:00", "Mon", "05-12")
-	Count int    `json:"count"`
+// GetAlert returns an alert with its comments
+func (s *Store) GetAlert(id uint) (*Alert, error) {
+	var alert Alert
+	err := s.db.Preload("Comments", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("id")
+	}).First(&alert, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
 }
 
-// GetThreatStats aggregates threat counts based on the time range
-func GetThreatStats(rangeType string) ([]StatsPoint, error) {
-	var points []StatsPoint
-	var err error
-	var rows *sql.Rows
-	now := time.Now()
-
-	/*
-	   Strategy:
-	   1. Generate complete time buckets in Go to ensure zero-filling.
-	   2. Query DB for counts grouped by formatted time string.
-	   3. Map DB results to the buckets.
-	*/
-
-	if rangeType == "Day" {
-		// Past 24 Hours
-		// Map: "15:00" -> Count
-		counts := make(map[string]int)
-
-		// SQLite: strftime('%H:00', created_at, 'localtime') might be needed depending on timezone
-		// For simplicity/portability, we trust system time.
-		// Select strftime('%H:00', created_at) as label, count(*) as count
-		// from alerts where created_at > ? group by label
-
-		rows, err = DB.Model(&Alert{}).
-			Select("strftime('%H:00', created_at, 'localtime') as label, count(*) as count").
-			Where("created_at > ?", now.Add(-24*time.Hour)).
-			Group("label").
-			Rows()
+// SaveAlertTriage saves the read state, acknowledgement and verdict of an alert
+func (s *Store) SaveAlertTriage(alert *Alert) error {
+	return s.db.Model(alert).Select("is_read", "acked_at", "acked_by", "verdict", "verdict_by", "verdict_at").
+		Updates(alert).Error
+}
 
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
+// MarkAlertsRead sets the read state of the given alerts and returns how many exist
+func (s *Store) MarkAlertsRead(ids []uint, read bool) (int64, error) {
+	result := s.db.Model(&Alert{}).Where("id IN ?", ids).Update("is_read", read)
+	return result.RowsAffected, result.Error
+}
 
-		for rows.Next() {
-			var label string
-			var count int
-			rows.Scan(&label, &count)
-			counts[label] = count
-		}
+// AddAlertComment appends a comment to an alert
+func (s *Store) AddAlertComment(comment *AlertComment) error {
+	return s.db.Create(comment).Error
+}
 
-		// Generate last 24 buckets properly
-		for i := 23; i >= 0; i-- {
-			t := now.Add(time.Duration(-i) * time.Hour)
-			label := t.Format("15:00") // HH:00
-			points = append(points, StatsPoint{Label: label, Count: counts[label]})
+// EachJudgedAlert calls fn for every alert with a verdict and a stored feature vector, oldest first
+// An empty verdict selects both true and false positives
+func (s *Store) EachJudgedAlert(verdict string, fn func(Alert) error) error {
+	query := s.db.Where("verdict <> '' AND features IS NOT NULL")
+	if verdict != "" {
+		query = query.Where("verdict = ?", verdict)
+	}
+	var batch []Alert
+	return query.FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, alert := range batch {
+			if len(alert.Features) == 0 {
+				continue
+			}
+			if err := fn(alert); err != nil {
+				return err
+			}
 		}
+		return nil
+	}).Error
+}
 
-	} else if rangeType == "Week" {
-		// Past 7 Days
-		counts := make(map[string]int)
+// CreateFlowRecords inserts flow records in batches
+func (s *Store) CreateFlowRecords(records []FlowRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return s.db.CreateInBatches(records, 100).Error
+}
 
-		rows, err = DB.Model(&Alert{}).
-			Select("strftime('%Y-%m-%d', created_at, 'localtime') as label, count(*) as count").
-			Where("created_at > ?", now.Add(-7*24*time.Hour)).
-			Group("label").
-			Rows()
+// GetAlertFlows returns the flows that produced an alert
+func (s *Store) GetAlertFlows(alertID uint) ([]FlowRecord, error) {
+	var records []FlowRecord
+	err := s.db.Where("alert_id = ?", alertID).Order("start_time").Find(&records).Error
+	return records, err
+}
 
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
+// SaveBlock inserts or updates the active block for block.IP
+func (s *Store) SaveBlock(block *Block) error {
+	var existing Block
+	err := s.db.Where("ip = ?", block.IP).Limit(1).Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	if existing.ID != 0 {
+		block.ID = existing.ID
+	}
+	return s.db.Save(block).Error
+}
 
-		for rows.Next() {
-			var label string
-			var count int
-			rows.Scan(&label, &count)
-			counts[label] = count
-		}
+// DeleteBlock removes the active block for ip
+func (s *Store) DeleteBlock(ip string) error {
+	return s.db.Where("ip = ?", ip).Delete(&Block{}).Error
+}
 
-		// Generate last 7 days buckets
-		for i := 6; i >= 0; i-- {
-			t := now.AddDate(0, 0, -i)
-			key := t.Format("2006-01-02")
-			displayLabel := t.Format("Mon") // Mon, Tue...
-			points = append(points, StatsPoint{Label: displayLabel, Count: counts[key]})
-		}
+// ListBlocks returns all active blocks, newest first
+func (s *Store) ListBlocks() ([]Block, error) {
+	var blocks []Block
+	result := s.db.Order("started_at desc").Find(&blocks)
+	return blocks, result.Error
+}
 
-	} else { // Month
-		// Past 30 Days
-		counts := make(map[string]int)
+// SaveWhitelistEntry stores a runtime whitelist entry
+func (s *Store) SaveWhitelistEntry(entry *WhitelistEntry) error {
+	return s.db.Create(entry).Error
+}
 
-		rows, err = DB.Model(&Alert{}).
-			Select("strftime('%Y-%m-%d', created_at, 'localtime') as label, count(*) as count").
-			Where("created_at > ?", now.Add(-30*24*time.Hour)).
-			Group("label").
-			Rows()
+// DeleteWhitelistEntry removes a runtime whitelist entry
+func (s *Store) DeleteWhitelistEntry(entry string) error {
+	return s.db.Where("entry = ?", entry).Delete(&WhitelistEntry{}).Error
+}
 
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
+// ListWhitelistEntries returns all runtime whitelist entries
+func (s *Store) ListWhitelistEntries() ([]WhitelistEntry, error) {
+	var entries []WhitelistEntry
+	result := s.db.Order("created_at").Find(&entries)
+	return entries, result.Error
+}
 
-		for rows.Next() {
-			var label string
-			var count int
-			rows.Scan(&label, &count)
-			counts[label] = count
-		}
+// CreateAuditLog appends an entry to the audit trail
+func (s *Store) CreateAuditLog(entry *AuditLog) error {
+	return s.db.Create(entry).Error
+}
 
-		for i := 29; i >= 0; i-- {
-			t := now.AddDate(0, 0, -i)
-			key := t.Format("2006-01-02")
-			displayLabel := t.Format("02") // Day of month
-			points = append(points, StatsPoint{Label: displayLabel, Count: counts[key]})
-		}
+// GetAuditLogs retrieves the latest N audit entries
+func (s *Store) GetAuditLogs(limit int) ([]AuditLog, error) {
+	var logs []AuditLog
+	result := s.db.Order("created_at desc, id desc").Limit(limit).Find(&logs)
+	return logs, result.Error
+}
+
+// CreateIncident inserts a new incident
+func (s *Store) CreateIncident(incident *Incident) error {
+	return s.db.Omit("Timeline").Create(incident).Error
+}
+
+// SaveIncident updates an incident, its timeline is written separately
+func (s *Store) SaveIncident(incident *Incident) error {
+	return s.db.Omit("Timeline").Save(incident).Error
+}
+
+// AddIncidentEvent appends an entry to an incident's timeline
+func (s *Store) AddIncidentEvent(event *IncidentEvent) error {
+	return s.db.Create(event).Error
+}
+
+// GetIncident returns an incident with its timeline
+func (s *Store) GetIncident(id uint) (*Incident, error) {
+	var incident Incident
+	err := s.db.Preload("Timeline", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("id")
+	}).First(&incident, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// ListIncidents returns the most recently active incidents, optionally filtered by status
+func (s *Store) ListIncidents(status string, limit int) ([]Incident, error) {
+	var incidents []Incident
+	query := s.db.Order("last_seen desc").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
 	}
+	err := query.Find(&incidents).Error
+	return incidents, err
+}
+
+// ListActiveIncidents returns incidents that are not closed and were active since the given time
+func (s *Store) ListActiveIncidents(since time.Time) ([]Incident, error) {
+	var incidents []Incident
+	err := s.db.Where("status <> ? AND last_seen >= ?", IncidentClosed, since).
+		Order("last_seen desc").Find(&incidents).Error
+	return incidents, err
+}
+
+// SetAlertIncident links an alert to an incident
+func (s *Store) SetAlertIncident(alertID, incidentID uint) error {
+	return s.db.Model(&Alert{}).Where("id = ?", alertID).Update("incident_id", incidentID).Error
+}
+
+// GetIncidentAlerts returns the alerts linked to an incident
+func (s *Store) GetIncidentAlerts(incidentID uint) ([]Alert, error) {
+	var alerts []Alert
+	err := s.db.Where("incident_id = ?", incidentID).Order("created_at").Find(&alerts).Error
+	return alerts, err
+}
+
+// EnqueueOutbox stores a notification for delivery
+func (s *Store) EnqueueOutbox(msg *OutboxMessage) error {
+	return s.db.Create(msg).Error
+}
+
+// PendingOutbox returns up to limit notifications queued for notifier, oldest first
+func (s *Store) PendingOutbox(notifier string, limit int) ([]OutboxMessage, error) {
+	var msgs []OutboxMessage
+	err := s.db.Where("notifier = ?", notifier).Order("id").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// SaveOutbox records a failed delivery attempt
+func (s *Store) SaveOutbox(msg *OutboxMessage) error {
+	return s.db.Save(msg).Error
+}
+
+// DeleteOutbox removes a delivered notification
+func (s *Store) DeleteOutbox(id uint) error {
+	return s.db.Delete(&OutboxMessage{}, id).Error
+}
 
-	return points, nil
+// DeleteOutboxExcept removes the notifications of notifiers that are no longer configured
+func (s *Store) DeleteOutboxExcept(notifiers []string) (int64, error) {
+	res := s.db.Where("notifier NOT IN ?", notifiers).Delete(&OutboxMessage{})
+	return res.RowsAffected, res.Error
 }
@@ -1,6 +1,7 @@
 package db_test
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 	"time"
@@ -13,7 +14,7 @@ func TestDB(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
 
-	err := db.InitDB(dbPath)
+	store, err := db.InitDB(db.DriverSQLite, dbPath)
 	if err != nil {
 		t.Fatalf("Failed to init DB: %v", err)
 	}
@@ -27,13 +28,13 @@ func TestDB(t *testing.T) {
 		Confidence: 0.95,
 	}
 
-	err = db.CreateAlert(alert)
+	err = store.CreateAlert(alert)
 	if err != nil {
 		t.Errorf("Failed to create alert: %v", err)
 	}
 
 	// Test Retrieval
-	alerts, err := db.GetRecentAlerts(10)
+	alerts, err := store.GetRecentAlerts(10)
 	if err != nil {
 		t.Errorf("Failed to get alerts: %v", err)
 	}
@@ -48,3 +49,441 @@ func TestDB(t *testing.T) {
 
 	// Cleanup happens automatically for t.TempDir
 }
+
+func TestTransactionRollback(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	failed := errors.New("timeline write failed")
+	err = store.Transaction(func(tx db.IncidentRepository) error {
+		incident := &db.Incident{Status: db.IncidentOpen, AttackerIP: "192.0.2.1", FirstSeen: now, LastSeen: now}
+		if err := tx.CreateIncident(incident); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Transaction() = %v", err)
+	}
+	// 回滚后不应留下没有时间线的事件
+	if incidents, err := store.ListIncidents("", 10); err != nil || len(incidents) != 0 {
+		t.Errorf("incidents after rollback = %d, %v", len(incidents), err)
+	}
+}
+
+func TestSearchAlerts(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+
+	base := time.Now().Add(-time.Hour)
+	fixtures := []db.Alert{
+		{SourceIP: "192.0.2.1", DestIP: "10.0.0.1", Type: "DDoS", Confidence: 0.99, Payload: "SYN flood"},
+		{SourceIP: "192.0.2.130", DestIP: "10.0.0.2", Type: "PortScan", Confidence: 0.80, Payload: "nmap 100%_done", IsRead: true},
+		{SourceIP: "192.0.2.200", DestIP: "10.0.0.1", Type: "DDoS", Confidence: 0.91, Payload: "UDP flood"},
+		{SourceIP: "2001:db8::1", DestIP: "2001:db8::2", Type: "Web Attack", Confidence: 0.95, Payload: "GET /etc/passwd"},
+		{SourceIP: "192.0.2.5", DestIP: "10.0.0.3", Type: "Bot", Confidence: 0.85, Payload: "nmap 1000 done"},
+	}
+	for i := range fixtures {
+		fixtures[i].CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if err := store.CreateAlert(&fixtures[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(page *db.AlertPage) []string {
+		var out []string
+		for _, a := range page.Alerts {
+			out = append(out, a.SourceIP)
+		}
+		return out
+	}
+	search := func(f db.AlertFilter) *db.AlertPage {
+		t.Helper()
+		page, err := store.SearchAlerts(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+	f32 := func(v float32) *float32 { return &v }
+
+	// 种子数据不在测试网段内
+	page := search(db.AlertFilter{Source: "192.0.2.0/24"})
+	if page.Total != 4 || len(page.Alerts) != 4 || page.Alerts[0].SourceIP != "192.0.2.5" {
+		t.Errorf("CIDR search: total=%d alerts=%v", page.Total, ids(page))
+	}
+	if page.Facets["DDoS"] != 2 || page.Facets["PortScan"] != 1 || page.Facets["Web Attack"] != 0 {
+		t.Errorf("facets: %v", page.Facets)
+	}
+
+	// 分面不受类型过滤影响
+	page = search(db.AlertFilter{Source: "192.0.2.128/25", Labels: []string{"DDoS"}})
+	if page.Total != 1 || ids(page)[0] != "192.0.2.200" || page.Facets["PortScan"] != 1 {
+		t.Errorf("label search: total=%d alerts=%v facets=%v", page.Total, ids(page), page.Facets)
+	}
+
+	page = search(db.AlertFilter{Source: "2001:db8:0::1"})
+	if page.Total != 1 || page.Alerts[0].Type != "Web Attack" {
+		t.Errorf("IPv6 search: %v", ids(page))
+	}
+	page = search(db.AlertFilter{Dest: "10.0.0.1", MinConfidence: f32(0.95)})
+	if page.Total != 1 || ids(page)[0] != "192.0.2.1" {
+		t.Errorf("dest/confidence search: %v", ids(page))
+	}
+	read := true
+	page = search(db.AlertFilter{Source: "192.0.2.0/24", IsRead: &read, MaxConfidence: f32(0.9)})
+	if page.Total != 1 || ids(page)[0] != "192.0.2.130" {
+		t.Errorf("read search: %v", ids(page))
+	}
+
+	// 载荷检索中的 % 与 _ 按字面匹配
+	page = search(db.AlertFilter{Query: "100%_"})
+	if page.Total != 1 || ids(page)[0] != "192.0.2.130" {
+		t.Errorf("payload search: %v", ids(page))
+	}
+
+	since, until := base.Add(90*time.Second), base.Add(4*time.Minute)
+	page = search(db.AlertFilter{Source: "192.0.2.0/24", Since: &since, Until: &until})
+	if page.Total != 1 || ids(page)[0] != "192.0.2.200" {
+		t.Errorf("time range search: %v", ids(page))
+	}
+
+	// 游标分页遍历全部结果
+	var all []string
+	f := db.AlertFilter{Source: "192.0.2.0/24", Limit: 3}
+	for {
+		page = search(f)
+		all = append(all, ids(page)...)
+		if page.NextCursor == 0 {
+			break
+		}
+		f.Cursor = page.NextCursor
+	}
+	if len(all) != 4 || all[0] != "192.0.2.5" || all[3] != "192.0.2.1" {
+		t.Errorf("pagination: %v", all)
+	}
+
+	if _, err := store.SearchAlerts(db.AlertFilter{Source: "not-an-ip"}); err == nil {
+		t.Error("expected error for invalid address filter")
+	}
+}
+
+func TestAlertTriage(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+
+	features := []float32{80, 1.5, 3}
+	alerts := []*db.Alert{
+		{SourceIP: "192.0.2.1", DestIP: "10.0.0.1", Type: "DDoS", Confidence: 0.9, Features: features},
+		{SourceIP: "192.0.2.2", DestIP: "10.0.0.1", Type: "Bot", Confidence: 0.8, Features: features},
+		{SourceIP: "192.0.2.3", DestIP: "10.0.0.1", Type: "Bot", Confidence: 0.8}, // 没有特征向量
+	}
+	for _, a := range alerts {
+		if err := store.CreateAlert(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	alerts[0].IsRead = true
+	alerts[0].AckedAt, alerts[0].AckedBy = &now, "analyst"
+	alerts[0].Verdict, alerts[0].VerdictBy, alerts[0].VerdictAt = db.VerdictFalsePositive, "analyst", &now
+	alerts[0].Type = "changed" // 只保存处置相关字段
+	if err := store.SaveAlertTriage(alerts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddAlertComment(&db.AlertComment{AlertID: alerts[0].ID, Author: "analyst", Body: "backup job"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetAlert(alerts[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsRead || got.AckedBy != "analyst" || got.Verdict != db.VerdictFalsePositive || got.Type != "DDoS" {
+		t.Errorf("unexpected triage state: %+v", got)
+	}
+	if len(got.Comments) != 1 || got.Comments[0].Body != "backup job" || len(got.Features) != 3 {
+		t.Errorf("unexpected comments/features: %+v %v", got.Comments, got.Features)
+	}
+
+	n, err := store.MarkAlertsRead([]uint{alerts[1].ID, alerts[2].ID, 999999}, true)
+	if err != nil || n != 2 {
+		t.Errorf("MarkAlertsRead: n=%d err=%v", n, err)
+	}
+
+	alerts[1].Verdict = db.VerdictTruePositive
+	alerts[2].Verdict = db.VerdictTruePositive
+	for _, a := range alerts[1:] {
+		if err := store.SaveAlertTriage(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	judged := func(verdict string) []string {
+		var types []string
+		err := store.EachJudgedAlert(verdict, func(a db.Alert) error {
+			types = append(types, a.Type)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return types
+	}
+	// 没有特征向量的告警无法导出
+	if got := judged(""); len(got) != 2 || got[0] != "DDoS" || got[1] != "Bot" {
+		t.Errorf("all verdicts: %v", got)
+	}
+	if got := judged(db.VerdictFalsePositive); len(got) != 1 || got[0] != "DDoS" {
+		t.Errorf("false positives: %v", got)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	store, err := db.Open(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// 旧版本写入的百分比置信度
+	if err := store.DB().AutoMigrate(&db.Alert{}); err != nil {
+		t.Fatal(err)
+	}
+	legacy := []db.Alert{
+		{SourceIP: "192.0.2.1", DestIP: "10.0.0.1", Type: "DDoS", Confidence: 95},
+		{SourceIP: "192.0.2.2", DestIP: "10.0.0.1", Type: "DDoS", Confidence: 250},
+		{SourceIP: "192.0.2.3", DestIP: "10.0.0.1", Type: "DDoS", Confidence: 0.5},
+	}
+	if err := store.DB().Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := store.Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			t.Errorf("migration %d applied before Migrate", s.Version)
+		}
+	}
+
+	applied, err := store.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(applied) != len(statuses) {
+		t.Errorf("applied %d migrations, want %d", len(applied), len(statuses))
+	}
+
+	var alerts []db.Alert
+	store.DB().Order("id").Find(&alerts)
+	if len(alerts) != 3 {
+		t.Fatalf("migration changed the number of alerts to %d", len(alerts))
+	}
+	want := []float32{0.95, 1, 0.5}
+	for i, a := range alerts {
+		if diff := a.Confidence - want[i]; diff > 1e-6 || diff < -1e-6 {
+			t.Errorf("alert %d confidence = %v, want %v", i, a.Confidence, want[i])
+		}
+	}
+
+	if applied, err := store.Migrate(); err != nil || len(applied) != 0 {
+		t.Errorf("second Migrate applied %d migrations, err %v", len(applied), err)
+	}
+
+	if n, err := store.SeedDemoAlerts(); err != nil || n != 0 {
+		t.Errorf("SeedDemoAlerts on a non-empty database wrote %d alerts, err %v", n, err)
+	}
+}
+
+func TestUTCTimestamps(t *testing.T) {
+	store, err := db.Open(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := store.DB().AutoMigrate(&db.Alert{}); err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本按本地时区写入的时间戳
+	err = store.DB().Exec(`INSERT INTO alerts (created_at, last_seen, source_ip, dest_ip, type, confidence)
+		VALUES ('2024-05-01 10:00:00.123456789+08:00', '2024-05-01 10:00:00+08:00', '192.0.2.1', '10.0.0.1', 'DDoS', 0.9)`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// 以其他时区写入的告警同样保存为 UTC
+	newYork := time.FixedZone("EDT", -4*3600)
+	at := time.Date(2024, 5, 1, 0, 30, 0, 0, newYork) // 04:30Z
+	if err := store.CreateAlert(&db.Alert{CreatedAt: at, LastSeen: at, SourceIP: "192.0.2.2", DestIP: "10.0.0.1", Type: "DDoS"}); err != nil {
+		t.Fatal(err)
+	}
+	var stored []string
+	store.DB().Raw("SELECT CAST(created_at AS TEXT) FROM alerts ORDER BY id").Scan(&stored)
+	want := []string{"2024-05-01 02:00:00.123456789+00:00", "2024-05-01 04:30:00+00:00"}
+	if len(stored) != 2 || stored[0] != want[0] || stored[1] != want[1] {
+		t.Errorf("stored timestamps = %q, want %q", stored, want)
+	}
+
+	// 另一时区的查询边界按时刻比较: 13:00+09:00 即 04:00Z
+	since := time.Date(2024, 5, 1, 13, 0, 0, 0, time.FixedZone("JST", 9*3600))
+	page, err := store.SearchAlerts(db.AlertFilter{Since: &since, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Alerts) != 1 || page.Alerts[0].SourceIP != "192.0.2.2" || !page.Alerts[0].CreatedAt.Equal(at) {
+		t.Errorf("alerts since %s = %+v", since, page.Alerts)
+	}
+}
+
+func TestSeedDemoAlerts(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	if alerts, _ := store.GetRecentAlerts(1); len(alerts) != 0 {
+		t.Fatal("fresh database contains alerts")
+	}
+	n, err := store.SeedDemoAlerts()
+	if err != nil || n == 0 {
+		t.Fatalf("SeedDemoAlerts wrote %d alerts, err %v", n, err)
+	}
+	if again, _ := store.SeedDemoAlerts(); again != 0 {
+		t.Errorf("second SeedDemoAlerts wrote %d alerts", again)
+	}
+}
+
+func TestGetThreatStats(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	now := time.Now()
+	for _, age := range []time.Duration{10 * time.Minute, 3 * time.Hour, 50 * time.Hour, 40 * 24 * time.Hour} {
+		alert := &db.Alert{CreatedAt: now.Add(-age), SourceIP: "192.0.2.1", DestIP: "10.0.0.1", Type: "DDoS", Confidence: 0.9}
+		if err := store.CreateAlert(alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	total := func(points []db.StatsPoint) int {
+		n := 0
+		for _, p := range points {
+			n += p.Count
+		}
+		return n
+	}
+	for _, tc := range []struct {
+		rangeType string
+		buckets   int
+		want      int
+	}{{"Day", 24, 2}, {"Week", 7, 3}, {"Month", 30, 3}} {
+		points, err := store.GetThreatStats(tc.rangeType)
+		if err != nil {
+			t.Fatalf("GetThreatStats(%s): %v", tc.rangeType, err)
+		}
+		if len(points) != tc.buckets || total(points) != tc.want {
+			t.Errorf("%s: %d buckets with %d alerts, want %d with %d", tc.rangeType, len(points), total(points), tc.buckets, tc.want)
+		}
+	}
+
+	points, _ := store.GetThreatStats("Day")
+	if last := points[len(points)-1]; last.Label != now.Format("15:00") {
+		t.Errorf("last hourly bucket is %q, want the current hour", last.Label)
+	}
+}
+
+func TestThreatStats(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	// 统计时区与存储时区不同，桶边界必须按统计时区划分
+	loc := time.FixedZone("UTC+9", 9*3600)
+	y, m, d := time.Now().In(loc).Date()
+	since := time.Date(y, m, d-2, 0, 0, 0, 0, loc)
+	until := since.Add(48 * time.Hour)
+
+	fixtures := []struct {
+		offset time.Duration
+		src    string
+		label  string
+		conf   float32
+		ports  []uint16
+	}{
+		{-time.Minute, "192.0.2.9", "Bot", 0.99, nil},
+		{time.Hour, "192.0.2.1", "DDoS", 0.95, []uint16{80, 80}},
+		{2 * time.Hour, "192.0.2.1", "DDoS", 0.91, nil},
+		{25 * time.Hour, "192.0.2.2", "PortScan", 0.35, []uint16{443}},
+		{49 * time.Hour, "192.0.2.9", "Bot", 0.99, nil},
+	}
+	for _, f := range fixtures {
+		alert := &db.Alert{CreatedAt: since.Add(f.offset).Local(), SourceIP: f.src, DestIP: "10.0.0.1", Type: f.label, Confidence: f.conf}
+		if err := store.CreateAlert(alert); err != nil {
+			t.Fatal(err)
+		}
+		var records []db.FlowRecord
+		for _, port := range f.ports {
+			records = append(records, db.FlowRecord{SrcIP: f.src, DstIP: "10.0.0.1", DstPort: port, Protocol: 6, Malicious: true, AlertID: &alert.ID})
+		}
+		if err := store.CreateFlowRecords(records); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := store.ThreatStats(db.StatsQuery{
+		Filter:   db.AlertFilter{Since: &since, Until: &until},
+		Bucket:   24 * time.Hour,
+		Location: loc,
+	})
+	if err != nil {
+		t.Fatalf("ThreatStats: %v", err)
+	}
+	if stats.Total != 3 || len(stats.Series) != 2 || stats.Series[0].Total != 2 || stats.Series[1].Total != 1 {
+		t.Fatalf("unexpected series: total %d, %+v", stats.Total, stats.Series)
+	}
+	if !stats.Series[1].Start.Equal(since.Add(24*time.Hour)) || stats.Series[0].ByLabel["DDoS"] != 2 {
+		t.Errorf("unexpected buckets: %+v", stats.Series)
+	}
+	if stats.ByLabel["DDoS"] != 2 || stats.ByLabel["PortScan"] != 1 || stats.ByLabel["Bot"] != 0 {
+		t.Errorf("unexpected label breakdown: %v", stats.ByLabel)
+	}
+	if len(stats.TopSources) != 2 || stats.TopSources[0] != (db.StatsCount{Key: "192.0.2.1", Count: 2}) {
+		t.Errorf("unexpected top sources: %+v", stats.TopSources)
+	}
+	if len(stats.TopTargets) != 1 || stats.TopTargets[0].Count != 3 {
+		t.Errorf("unexpected top targets: %+v", stats.TopTargets)
+	}
+	if len(stats.TopPorts) != 2 || stats.TopPorts[0] != (db.PortCount{Port: 80, Protocol: 6, Count: 2}) {
+		t.Errorf("unexpected top ports: %+v", stats.TopPorts)
+	}
+	if len(stats.Confidence) != 10 || stats.Confidence[9].Count != 2 || stats.Confidence[3].Count != 1 {
+		t.Errorf("unexpected confidence histogram: %+v", stats.Confidence)
+	}
+
+	// 小时桶按统计时区的整点对齐，类型过滤同样作用于端口排行
+	from := since.Add(24*time.Hour + 30*time.Minute)
+	stats, err = store.ThreatStats(db.StatsQuery{
+		Filter:   db.AlertFilter{Since: &from, Until: &until, Labels: []string{"PortScan"}},
+		Bucket:   time.Hour,
+		Location: loc,
+		Bins:     4,
+	})
+	if err != nil {
+		t.Fatalf("ThreatStats: %v", err)
+	}
+	if len(stats.Series) != 24 || !stats.Series[0].Start.Equal(since.Add(24*time.Hour)) || stats.Series[1].Total != 1 {
+		t.Errorf("unexpected hourly series: %d buckets, first two %+v", len(stats.Series), stats.Series[:2])
+	}
+	if len(stats.TopPorts) != 1 || stats.TopPorts[0].Port != 443 || len(stats.Confidence) != 4 || stats.Confidence[1].Count != 1 {
+		t.Errorf("label filter not applied: ports %+v, confidence %+v", stats.TopPorts, stats.Confidence)
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"time"
+)
+
+// SeedDemoAlerts 在告警表为空时写入演示用的攻击日志，返回写入的条数
+// 只用于演示环境 (--demo)，生产传感器不应出现伪造的告警
+func (s *Store) SeedDemoAlerts() (int, error) {
+	var total int64
+	if err := s.db.Unscoped().Model(&Alert{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	if total > 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	alerts := []Alert{
+		{CreatedAt: now.Add(-12 * time.Hour), SourceIP: "104.26.6.57", DestIP: "192.168.1.100", Type: "DDoS", Confidence: 0.99, Payload: "GET / HTTP/1.1\r\nHost: target.com\r\nUser-Agent: Hulk/1.0\r\n\r\n"},
+		{CreatedAt: now.Add(-11 * time.Hour), SourceIP: "45.33.18.12", DestIP: "192.168.1.50", Type: "Web Attack", Confidence: 0.94, Payload: "POST /login.php HTTP/1.1\r\nContent-Type: application/x-www-form-urlencoded\r\n\r\nusername=admin' OR '1'='1&password=123"},
+		{CreatedAt: now.Add(-9 * time.Hour), SourceIP: "185.199.108.133", DestIP: "192.168.1.10", Type: "PortScan", Confidence: 0.88, Payload: "SYN Stealth Scan (Nmap) Packet Signature Detected. Window Size = 1024."},
+		{CreatedAt: now.Add(-8 * time.Hour), SourceIP: "117.72.62.10", DestIP: "192.168.1.100", Type: "Brute Force", Confidence: 0.92, Payload: "SSH-2.0-OpenSSH_8.2p1 Ubuntu-4ubuntu0.1\npassword matching failed"},
+		{CreatedAt: now.Add(-7 * time.Hour), SourceIP: "52.220.222.172", DestIP: "192.168.1.15", Type: "Bot", Confidence: 0.85, Payload: "GET /c2_command.php?id=9928 HTTP/1.1\r\nUser-Agent: Mozilla/5.0 (Windows NT 10.0; Win64; x64)"},
+		{CreatedAt: now.Add(-6 * time.Hour), SourceIP: "151.101.193.91", DestIP: "192.168.1.50", Type: "Web Attack", Confidence: 0.96, Payload: "GET /../../../../etc/passwd HTTP/1.1\r\nHost: example.com\r\n"},
+		{CreatedAt: now.Add(-5 * time.Hour), SourceIP: "142.250.71.131", DestIP: "192.168.1.20", Type: "PortScan", Confidence: 0.78, Payload: "UDP Scan detected on ports: 53, 161, 123"},
+		{CreatedAt: now.Add(-4 * time.Hour), SourceIP: "3.168.86.75", DestIP: "192.168.1.100", Type: "DDoS", Confidence: 0.97, Payload: "Volumetric SYN Flood. Packet Rate: 45000 pps"},
+		{CreatedAt: now.Add(-3 * time.Hour), SourceIP: "180.105.204.112", DestIP: "192.168.1.12", Type: "Brute Force", Confidence: 0.89, Payload: "FTP Login failed: 530 Login incorrect. User: anonymous"},
+		{CreatedAt: now.Add(-2 * time.Hour), SourceIP: "58.216.102.31", DestIP: "192.168.1.50", Type: "Web Attack", Confidence: 0.91, Payload: "GET /index.php?id=1 UNION SELECT null, version() HTTP/1.1\r\nHost: target\r\n"},
+		{CreatedAt: now.Add(-1 * time.Hour), SourceIP: "222.186.176.192", DestIP: "192.168.1.100", Type: "Bot", Confidence: 0.82, Payload: "Mirai Botnet signature detected. Hardcoded credential guess."},
+		{CreatedAt: now.Add(-20 * time.Minute), SourceIP: "114.237.67.68", DestIP: "192.168.1.50", Type: "Web Attack", Confidence: 0.95, Payload: "GET /?q=<script>alert('XSS')</script> HTTP/1.1\r\nHost: target\r\n"},
+	}
+
+	for i := range alerts {
+		alerts[i].Count = 1
+		alerts[i].LastSeen = alerts[i].CreatedAt
+	}
+	if err := s.db.Create(&alerts).Error; err != nil {
+		return 0, err
+	}
+	return len(alerts), nil
+}
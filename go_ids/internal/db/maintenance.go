@@ -0,0 +1,254 @@
+package db
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sqliteAutoVacuumIncremental is the PRAGMA auto_vacuum value of incremental mode
+const sqliteAutoVacuumIncremental = 2
+
+// DBStats describes the size of the database
+type DBStats struct {
+	Driver    string           `json:"driver"`
+	Path      string           `json:"path,omitempty"`       // SQLite 数据库文件路径
+	FileSize  int64            `json:"file_size"`            // SQLite 为数据库文件与 WAL 文件的大小之和，PostgreSQL 为数据库大小 (字节)
+	PageSize  int64            `json:"page_size,omitempty"`  // 页大小 (字节)，仅 SQLite
+	PageCount int64            `json:"page_count,omitempty"` // 已分配的页数，仅 SQLite
+	FreePages int64            `json:"free_pages"`           // 可以通过 VACUUM 回收的空闲页数，仅 SQLite
+	Rows      map[string]int64 `json:"rows"`                 // 各表行数，包括软删除的行，PostgreSQL 为估计值
+}
+
+// IncrementalVacuumEnabled reports whether pages freed by the pruner are returned to the file system
+// It is always true for PostgreSQL, which reclaims space through autovacuum
+func (s *Store) IncrementalVacuumEnabled() (bool, error) {
+	if s.driver != DriverSQLite {
+		return true, nil
+	}
+	var mode int
+	if err := s.db.Raw("PRAGMA auto_vacuum").Scan(&mode).Error; err != nil {
+		return false, err
+	}
+	return mode == sqliteAutoVacuumIncremental, nil
+}
+
+// EnableIncrementalVacuum switches an existing SQLite database to incremental auto-vacuum so that pages
+// freed by the pruner can be returned to the file system without a full VACUUM.
+// The switch needs one full VACUUM, which rewrites the whole file and blocks every other writer while it
+// runs, so it is only done on request (ids db vacuum) and never when the database is opened
+func (s *Store) EnableIncrementalVacuum() error {
+	if s.driver != DriverSQLite {
+		return nil
+	}
+	if err := s.db.Exec("PRAGMA auto_vacuum = INCREMENTAL").Error; err != nil {
+		return err
+	}
+	return s.db.Exec("VACUUM").Error
+}
+
+// IncrementalVacuum returns the free pages to the file system and reports how many bytes were released
+// PostgreSQL reclaims space through autovacuum, so this is a no-op there
+func (s *Store) IncrementalVacuum() (int64, error) {
+	if s.driver != DriverSQLite {
+		return 0, nil
+	}
+	var pageSize, before, after int64
+	if err := s.db.Raw("PRAGMA page_size").Scan(&pageSize).Error; err != nil {
+		return 0, err
+	}
+	if err := s.db.Raw("PRAGMA freelist_count").Scan(&before).Error; err != nil {
+		return 0, err
+	}
+
+	// 需要逐步执行到结束，只执行一步时每次只释放一页
+	rows, err := s.db.Raw("PRAGMA incremental_vacuum").Rows()
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := s.db.Raw("PRAGMA freelist_count").Scan(&after).Error; err != nil {
+		return 0, err
+	}
+	return (before - after) * pageSize, nil
+}
+
+// pruneRows hard-deletes rows older than cutoff (per olderThan) and all but the newest maxRows rows
+// A zero cutoff or maxRows disables that condition. When scope is not empty, only rows matching it
+// are deleted or count towards maxRows
+func (s *Store) pruneRows(model interface{}, scope, olderThan string, cutoff time.Time, maxRows int) (int64, error) {
+	prunable := func() *gorm.DB {
+		query := s.db.Unscoped().Model(model)
+		if scope != "" {
+			query = query.Where(scope)
+		}
+		return query
+	}
+	var deleted int64
+	if !cutoff.IsZero() {
+		result := prunable().Where(olderThan, cutoff, cutoff).Delete(model)
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	if maxRows > 0 {
+		var boundary []uint
+		err := prunable().Order("id desc").Offset(maxRows).Limit(1).Pluck("id", &boundary).Error
+		if err != nil {
+			return deleted, err
+		}
+		if len(boundary) > 0 {
+			result := prunable().Where("id <= ?", boundary[0]).Delete(model)
+			if result.Error != nil {
+				return deleted, result.Error
+			}
+			deleted += result.RowsAffected
+		}
+	}
+	return deleted, nil
+}
+
+// alertReferences are the tables whose alert_id is cleared when the alert is pruned
+var alertReferences = []interface{}{&FlowRecord{}, &Block{}, &IncidentEvent{}}
+
+// PruneAlerts hard-deletes alerts last active before cutoff, all but the newest maxRows alerts
+// and soft-deleted alerts, together with their comments
+// Alerts with a verdict feed the labeled feedback dataset and are kept unless pruneJudged is set.
+// Flow records, blocks and incident events that pointed at a deleted alert keep their data but lose
+// the reference, all in one transaction
+func (s *Store) PruneAlerts(cutoff time.Time, maxRows int, pruneJudged bool) (int64, error) {
+	scope := "(verdict IS NULL OR verdict = '')"
+	if pruneJudged {
+		scope = ""
+	}
+	var deleted int64
+	err := s.transaction(func(tx *Store) error {
+		// 升级前的告警没有 last_seen
+		n, err := tx.pruneRows(&Alert{}, scope, "created_at < ? AND (last_seen IS NULL OR last_seen < ?)", cutoff, maxRows)
+		deleted += n
+		if err != nil {
+			return err
+		}
+		result := tx.db.Unscoped().Where("deleted_at IS NOT NULL").Delete(&Alert{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted += result.RowsAffected
+
+		remaining := tx.db.Unscoped().Model(&Alert{}).Select("id")
+		if err := tx.db.Where("alert_id NOT IN (?)", remaining).Delete(&AlertComment{}).Error; err != nil {
+			return err
+		}
+		for _, model := range alertReferences {
+			err := tx.db.Unscoped().Model(model).Where("alert_id IS NOT NULL AND alert_id NOT IN (?)", remaining).
+				UpdateColumn("alert_id", nil).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// PruneFlowRecords hard-deletes flow records that ended before cutoff and all but the newest maxRows records
+func (s *Store) PruneFlowRecords(cutoff time.Time, maxRows int) (int64, error) {
+	return s.pruneRows(&FlowRecord{}, "", "end_time < ? AND created_at < ?", cutoff, maxRows)
+}
+
+// PrunePayloads clears the payload of alerts created before cutoff and of all but the newest maxRows alerts with a payload
+func (s *Store) PrunePayloads(cutoff time.Time, maxRows int) (int64, error) {
+	withPayload := s.db.Unscoped().Model(&Alert{}).Where("payload <> ''")
+	var cleared int64
+	if !cutoff.IsZero() {
+		result := withPayload.Session(&gorm.Session{}).Where("created_at < ?", cutoff).UpdateColumn("payload", "")
+		if result.Error != nil {
+			return cleared, result.Error
+		}
+		cleared += result.RowsAffected
+	}
+	if maxRows > 0 {
+		var boundary []uint
+		err := withPayload.Session(&gorm.Session{}).Order("id desc").Offset(maxRows).Limit(1).Pluck("id", &boundary).Error
+		if err != nil {
+			return cleared, err
+		}
+		if len(boundary) > 0 {
+			result := withPayload.Session(&gorm.Session{}).Where("id <= ?", boundary[0]).UpdateColumn("payload", "")
+			if result.Error != nil {
+				return cleared, result.Error
+			}
+			cleared += result.RowsAffected
+		}
+	}
+	return cleared, nil
+}
+
+// GetDBStats reports the database size, page usage and row counts
+func (s *Store) GetDBStats() (*DBStats, error) {
+	stats := &DBStats{Driver: s.driver, Path: s.path, Rows: make(map[string]int64)}
+	if s.driver == DriverPostgres {
+		return stats, s.postgresStats(stats)
+	}
+
+	for _, name := range []string{s.path, s.path + "-wal"} {
+		if info, err := os.Stat(name); err == nil {
+			stats.FileSize += info.Size()
+		}
+	}
+	for pragma, dest := range map[string]*int64{
+		"page_size":      &stats.PageSize,
+		"page_count":     &stats.PageCount,
+		"freelist_count": &stats.FreePages,
+	} {
+		if err := s.db.Raw("PRAGMA " + pragma).Scan(dest).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	tables, err := s.db.Migrator().GetTables()
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		if strings.HasPrefix(table, "sqlite_") {
+			continue
+		}
+		var n int64
+		if err := s.db.Table(table).Count(&n).Error; err != nil {
+			return nil, err
+		}
+		stats.Rows[table] = n
+	}
+	return stats, nil
+}
+
+// postgresStats fills the size and the estimated row counts of a PostgreSQL database
+// Exact counts would scan every table, the statistics collector's live tuple counts are used instead
+func (s *Store) postgresStats(stats *DBStats) error {
+	if err := s.db.Raw("SELECT pg_database_size(current_database())").Scan(&stats.FileSize).Error; err != nil {
+		return err
+	}
+	var rows []struct {
+		Relname  string
+		NLiveTup int64
+	}
+	if err := s.db.Raw("SELECT relname, n_live_tup FROM pg_stat_user_tables").Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, r := range rows {
+		stats.Rows[r.Relname] = r.NLiveTup
+	}
+	return nil
+}
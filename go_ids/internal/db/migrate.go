@@ -0,0 +1,186 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// SchemaMigration records an applied data migration
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey" json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// migration is a versioned data fix. Migrations run once, in version order, each in its own transaction
+// They must never delete alerts: fix or annotate the data instead
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// migrationLockKey is the PostgreSQL advisory lock that serializes data migrations
+const migrationLockKey = 0x6964736d // "idsm"
+
+// migrations 按版本号递增排列，已发布的迁移不得修改，只能追加新版本
+var migrations = []migration{
+	{Version: 1, Name: "backfill alert address keys", Up: backfillAlertAddrs},
+	{Version: 2, Name: "rescale percentage confidences", Up: rescaleConfidences},
+	{Version: 3, Name: "store timestamps in UTC", Up: utcTimestamps},
+}
+
+// models lists every table managed by AutoMigrate
+var models = []interface{}{
+	&Alert{}, &AlertComment{}, &FlowRecord{}, &Block{}, &WhitelistEntry{}, &AuditLog{},
+	&Incident{}, &IncidentEvent{}, &OutboxMessage{}, &SchemaMigration{},
+}
+
+// MigrationStatus describes a migration and whether it has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"` // 为空表示尚未执行
+}
+
+// Migrate brings the schema up to date and applies the pending data migrations
+// It returns the migrations applied by this call
+func (s *Store) Migrate() ([]MigrationStatus, error) {
+	if err := s.db.AutoMigrate(models...); err != nil {
+		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+
+	statuses, err := s.migrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	var applied []MigrationStatus
+	for i, m := range migrations {
+		if statuses[i].AppliedAt != nil {
+			continue
+		}
+		record := SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}
+		ran := false
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			// 多个传感器共用 PostgreSQL 时可能同时启动，加锁后重新确认迁移尚未执行
+			if s.driver == DriverPostgres {
+				if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error; err != nil {
+					return err
+				}
+			}
+			var done int64
+			if err := tx.Model(&SchemaMigration{}).Where("version = ?", m.Version).Count(&done).Error; err != nil {
+				return err
+			}
+			if done > 0 {
+				return nil
+			}
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&record).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			applied = append(applied, MigrationStatus{Version: m.Version, Name: m.Name, AppliedAt: &record.AppliedAt})
+		}
+	}
+	return applied, nil
+}
+
+// Migrations reports every known migration with the time it was applied
+func (s *Store) Migrations() ([]MigrationStatus, error) {
+	if !s.db.Migrator().HasTable(&SchemaMigration{}) {
+		statuses := make([]MigrationStatus, len(migrations))
+		for i, m := range migrations {
+			statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name}
+		}
+		return statuses, nil
+	}
+	return s.migrationStatus()
+}
+
+// migrationStatus joins the known migrations with the applied ones
+func (s *Store) migrationStatus() ([]MigrationStatus, error) {
+	var done []SchemaMigration
+	if err := s.db.Find(&done).Error; err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(done))
+	for _, d := range done {
+		appliedAt[d.Version] = d.AppliedAt
+	}
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name}
+		if t, ok := appliedAt[m.Version]; ok {
+			statuses[i].AppliedAt = &t
+		}
+	}
+	return statuses, nil
+}
+
+// backfillAlertAddrs fills the address search columns of alerts stored before they existed
+func backfillAlertAddrs(tx *gorm.DB) error {
+	var batch []Alert
+	return tx.Select("id", "source_ip", "dest_ip").Where("src_addr IS NULL").
+		FindInBatches(&batch, 500, func(batchTx *gorm.DB, _ int) error {
+			for _, a := range batch {
+				err := tx.Model(&Alert{}).Where("id = ?", a.ID).UpdateColumns(map[string]interface{}{
+					"src_addr": addrKey(a.SourceIP),
+					"dst_addr": addrKey(a.DestIP),
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// rescaleConfidences fixes alerts written by old versions that stored the confidence as a percentage
+// Values that are not percentages either are clamped to 1
+func rescaleConfidences(tx *gorm.DB) error {
+	err := tx.Unscoped().Model(&Alert{}).Where("confidence > 1 AND confidence <= 100").
+		UpdateColumn("confidence", gorm.Expr("confidence / 100")).Error
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&Alert{}).Where("confidence > 1").UpdateColumn("confidence", 1).Error
+}
+
+// utcTimestamps rewrites SQLite timestamps written by older versions in the local offset to UTC
+// The go-sqlite3 format is "2006-01-02 15:04:05.999999999-07:00": the date and time are converted by
+// strftime, the fractional seconds are copied unchanged so no precision is lost
+func utcTimestamps(tx *gorm.DB) error {
+	if tx.Dialector.Name() != DriverSQLite {
+		return nil // PostgreSQL 的 timestamptz 按时刻比较
+	}
+	const update = `UPDATE "{table}" SET "{col}" = strftime('%Y-%m-%d %H:%M:%S', "{col}") || ` +
+		`substr("{col}", 20, length("{col}") - 25) || '+00:00' ` +
+		`WHERE "{col}" GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]*[+-][0-9][0-9]:[0-9][0-9]' ` +
+		`AND substr("{col}", -6) <> '+00:00'`
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DataType != schema.Time || field.DBName == "" {
+				continue
+			}
+			sql := strings.NewReplacer("{table}", stmt.Schema.Table, "{col}", field.DBName).Replace(update)
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -15,8 +15,197 @@ type Alert struct {
 
 	SourceIP   string  `gorm:"index" json:"source_ip"`
 	DestIP     string  `json:"dest_ip"`
-	Type       string  `json:"type"`       // e.g., "PortScan", "DDoS"
-	Confidence float32 `json:"confidence"` // 0.0 - 1.0
-	IsRead     bool    `gorm:"default:false" json:"is_read"`
+	Type       string  `gorm:"index" json:"type"`       // e.g., "PortScan", "DDoS"
+	Confidence float32 `gorm:"index" json:"confidence"` // 0.0 - 1.0
+	IsRead     bool    `gorm:"default:false;index" json:"is_read"`
 	Payload    string  `gorm:"type:text" json:"payload"` // 新增：保存攻击报文/特征载荷
+	Interface  string  `json:"interface"`                // 入口接口
+
+	// 地址的 16 字节十六进制形式，按字典序比较即按地址比较，用于 IP/CIDR 检索
+	SrcAddr string `gorm:"index" json:"-"`
+	DstAddr string `gorm:"index" json:"-"`
+
+	// 聚合窗口内相同 (源, 目的, 类型) 的事件合并到同一条告警
+	Count    int       `gorm:"default:1" json:"count"`
+	LastSeen time.Time `gorm:"index" json:"last_seen"`
+
+	IncidentID *uint `gorm:"index" json:"incident_id,omitempty"` // 关联的事件 (Incident)
+
+	// 分析人员处置
+	AckedAt   *time.Time     `json:"acked_at,omitempty"`
+	AckedBy   string         `json:"acked_by,omitempty"`
+	Verdict   string         `gorm:"index" json:"verdict,omitempty"` // 为空表示未判定
+	VerdictBy string         `json:"verdict_by,omitempty"`
+	VerdictAt *time.Time     `json:"verdict_at,omitempty"`
+	Comments  []AlertComment `gorm:"foreignKey:AlertID" json:"comments,omitempty"`
+
+	// 触发告警的原始 (未标准化) 特征向量，用于导出误报样本重新训练
+	Features []float32 `gorm:"serializer:json" json:"-"`
+}
+
+// 告警判定结果
+const (
+	VerdictTruePositive  = "true_positive"
+	VerdictFalsePositive = "false_positive"
+)
+
+// FlowRecord is a detected flow with its statistics, feature vector and prediction
+type FlowRecord struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	SrcIP     string    `gorm:"index" json:"src_ip"`
+	DstIP     string    `gorm:"index" json:"dst_ip"`
+	SrcPort   uint16    `json:"src_port"`
+	DstPort   uint16    `json:"dst_port"`
+	Protocol  uint8     `json:"protocol"` // IP 协议号
+	Interface string    `json:"interface"`
+	StartTime time.Time `gorm:"index" json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+
+	FwdPackets uint64 `json:"fwd_packets"`
+	BwdPackets uint64 `json:"bwd_packets"`
+	FwdBytes   uint64 `json:"fwd_bytes"`
+	BwdBytes   uint64 `json:"bwd_bytes"`
+
+	Features   []float32 `gorm:"serializer:json" json:"features"` // 原始 (未标准化) 特征向量
+	Label      string    `gorm:"index" json:"label"`              // 模型预测的类别，推理失败时为空
+	Confidence float32   `json:"confidence"`
+	Malicious  bool      `gorm:"index" json:"malicious"` // 是否超过检测阈值
+	AlertID    *uint     `gorm:"index" json:"alert_id,omitempty"`
+}
+
+// AlertComment is an analyst note on an alert
+type AlertComment struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AlertID   uint      `gorm:"index" json:"alert_id"`
+	CreatedAt time.Time `json:"timestamp"`
+	Author    string    `json:"author"`
+	Body      string    `gorm:"type:text" json:"body"`
+}
+
+// BeforeSave fills the address search columns
+func (a *Alert) BeforeSave(tx *gorm.DB) error {
+	a.SrcAddr = addrKey(a.SourceIP)
+	a.DstAddr = addrKey(a.DestIP)
+	return nil
+}
+
+// Block is an active firewall block on a source IP
+type Block struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	IP        string     `gorm:"uniqueIndex" json:"ip"`
+	Reason    string     `json:"reason"`
+	AlertID   *uint      `gorm:"index" json:"alert_id,omitempty"` // 触发封禁的告警，手动封禁时为空
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"` // 为空表示永久封禁
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Expired reports whether the block has passed its expiry time
+func (b *Block) Expired(now time.Time) bool {
+	return b.ExpiresAt != nil && !now.Before(*b.ExpiresAt)
+}
+
+// WhitelistEntry is a whitelist entry added at runtime through the API
+// Entry is an IP, a CIDR prefix or a hostname resolved when the entry is loaded.
+// Entries from config.yaml are not stored; they are reported with Source "config"
+type WhitelistEntry struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Entry     string     `gorm:"uniqueIndex" json:"entry"`
+	Comment   string     `json:"comment"`
+	CreatedBy string     `json:"created_by"`
+	ExpiresAt *time.Time `json:"expires_at"`                  // 为空表示永久有效
+	Source    string     `gorm:"-" json:"source"`             // "config" 或 "api"
+	Resolved  []string   `gorm:"-" json:"resolved,omitempty"` // 主机名条目解析出的地址
+}
+
+// Expired reports whether the entry has passed its expiry time
+func (e *WhitelistEntry) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// AuditLog records a manual change made through the API
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"timestamp"`
+	Actor     string    `json:"actor"`  // 操作者 (客户端地址或用户名)
+	Action    string    `json:"action"` // e.g. "block", "unblock", "whitelist_add"
+	Target    string    `gorm:"index" json:"target"`
+	Detail    string    `json:"detail"`
+}
+
+// Incident 状态
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentClosed       = "closed"
+)
+
+// Incident 严重程度，按从低到高排列
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Incident groups correlated alerts into one attack campaign
+type Incident struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Title      string     `json:"title"`
+	Severity   string     `gorm:"index" json:"severity"`
+	Status     string     `gorm:"index" json:"status"`
+	Assignee   string     `json:"assignee"`
+	AttackerIP string     `gorm:"index" json:"attacker_ip"`         // 首个攻击源
+	Attackers  []string   `gorm:"serializer:json" json:"attackers"` // 所有攻击源
+	Victims    []string   `gorm:"serializer:json" json:"victims"`   // 所有受害主机
+	Labels     []string   `gorm:"serializer:json" json:"labels"`    // 攻击类型，按首次出现顺序
+	AlertCount int        `json:"alert_count"`
+	FirstSeen  time.Time  `json:"first_seen"`
+	LastSeen   time.Time  `gorm:"index" json:"last_seen"`
+	ClosedAt   *time.Time `json:"closed_at,omitempty"`
+
+	Timeline []IncidentEvent `gorm:"foreignKey:IncidentID" json:"timeline,omitempty"`
+}
+
+// Active reports whether the incident can still receive alerts
+func (i *Incident) Active() bool {
+	return i.Status != IncidentClosed
+}
+
+// Incident 时间线条目类型
+const (
+	IncidentEventCreated  = "created"
+	IncidentEventAlert    = "alert"
+	IncidentEventSeverity = "severity"
+	IncidentEventStatus   = "status"
+	IncidentEventAssignee = "assignee"
+	IncidentEventComment  = "comment"
+)
+
+// IncidentEvent is an entry in an incident's timeline
+type IncidentEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	IncidentID uint      `gorm:"index" json:"incident_id"`
+	CreatedAt  time.Time `json:"timestamp"`
+	Kind       string    `json:"kind"`
+	AlertID    *uint     `json:"alert_id,omitempty"`
+	Actor      string    `json:"actor"` // "system" 或操作者
+	Detail     string    `json:"detail"`
+}
+
+// OutboxMessage is a notification waiting to be delivered to a notifier
+type OutboxMessage struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Notifier    string    `gorm:"index" json:"notifier"`    // 目标名称
+	Payload     string    `gorm:"type:text" json:"payload"` // 已渲染的请求体
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `gorm:"index" json:"next_attempt"` // 目标退避结束的时间，仅供查看
+	LastError   string    `json:"last_error"`
 }
@@ -0,0 +1,88 @@
+package db
+
+import "time"
+
+// AlertRepository stores alerts and their triage state
+type AlertRepository interface {
+	CreateAlert(alert *Alert) error
+	UpdateAlertAggregate(alert *Alert) error
+	GetRecentAlerts(limit int) ([]Alert, error)
+	GetAlert(id uint) (*Alert, error)
+	SearchAlerts(f AlertFilter) (*AlertPage, error)
+	SaveAlertTriage(alert *Alert) error
+	MarkAlertsRead(ids []uint, read bool) (int64, error)
+	AddAlertComment(comment *AlertComment) error
+	EachJudgedAlert(verdict string, fn func(Alert) error) error
+	EachAlert(f AlertFilter, fn func(Alert) error) error
+	GetThreatStats(rangeType string) ([]StatsPoint, error)
+	ThreatStats(q StatsQuery) (*ThreatStats, error)
+}
+
+// FlowRecordRepository stores detected flows
+type FlowRecordRepository interface {
+	CreateFlowRecords(records []FlowRecord) error
+	GetAlertFlows(alertID uint) ([]FlowRecord, error)
+	EachFlowRecord(f FlowFilter, fn func(FlowRecord) error) error
+}
+
+// ResponseRepository stores active blocks and the runtime whitelist
+type ResponseRepository interface {
+	SaveBlock(block *Block) error
+	DeleteBlock(ip string) error
+	ListBlocks() ([]Block, error)
+	SaveWhitelistEntry(entry *WhitelistEntry) error
+	DeleteWhitelistEntry(entry string) error
+	ListWhitelistEntries() ([]WhitelistEntry, error)
+}
+
+// AuditRepository stores the audit trail
+type AuditRepository interface {
+	CreateAuditLog(entry *AuditLog) error
+	GetAuditLogs(limit int) ([]AuditLog, error)
+}
+
+// IncidentRepository stores incidents and links alerts to them
+type IncidentRepository interface {
+	CreateIncident(incident *Incident) error
+	SaveIncident(incident *Incident) error
+	AddIncidentEvent(event *IncidentEvent) error
+	GetIncident(id uint) (*Incident, error)
+	ListIncidents(status string, limit int) ([]Incident, error)
+	ListActiveIncidents(since time.Time) ([]Incident, error)
+	SetAlertIncident(alertID, incidentID uint) error
+	GetIncidentAlerts(incidentID uint) ([]Alert, error)
+	Transaction(fn func(tx IncidentRepository) error) error
+}
+
+// OutboxRepository stores notifications awaiting delivery
+type OutboxRepository interface {
+	EnqueueOutbox(msg *OutboxMessage) error
+	PendingOutbox(notifier string, limit int) ([]OutboxMessage, error)
+	SaveOutbox(msg *OutboxMessage) error
+	DeleteOutbox(id uint) error
+	DeleteOutboxExcept(notifiers []string) (int64, error)
+}
+
+// MaintenanceRepository prunes old data and reports the database size
+type MaintenanceRepository interface {
+	PruneAlerts(cutoff time.Time, maxRows int, pruneJudged bool) (int64, error)
+	PruneFlowRecords(cutoff time.Time, maxRows int) (int64, error)
+	PrunePayloads(cutoff time.Time, maxRows int) (int64, error)
+	IncrementalVacuum() (int64, error)
+	IncrementalVacuumEnabled() (bool, error)
+	GetDBStats() (*DBStats, error)
+}
+
+// Repository is the persistence layer used by the sensor and the web console
+// Components depend on the narrowest interface they need; Store implements all of them
+type Repository interface {
+	AlertRepository
+	FlowRecordRepository
+	ResponseRepository
+	AuditRepository
+	IncidentRepository
+	OutboxRepository
+	MaintenanceRepository
+}
+
+var _ Repository = (*Store)(nil)
@@ -0,0 +1,282 @@
+package db
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AlertFilter describes an alert search. Zero values do not filter
+type AlertFilter struct {
+	Source        string   // 源地址，IP 或 CIDR
+	Dest          string   // 目的地址，IP 或 CIDR
+	Labels        []string // 告警类型，满足任意一个即可
+	MinConfidence *float32
+	MaxConfidence *float32
+	Since         *time.Time // 创建时间下限 (含)
+	Until         *time.Time // 创建时间上限 (不含)
+	IsRead        *bool
+	Query         string // 载荷中包含的文本
+	Cursor        uint   // 上一页最后一条告警的 ID，只返回更早的告警
+	Limit         int
+}
+
+// FlowFilter describes a flow record export. Zero values do not filter
+type FlowFilter struct {
+	Source    string     // 源地址，IP 或 CIDR
+	Dest      string     // 目的地址，IP 或 CIDR
+	Labels    []string   // 预测类别，满足任意一个即可
+	Malicious *bool      // 是否超过检测阈值
+	AlertID   *uint      // 关联的告警
+	Since     *time.Time // 流开始时间下限 (含)
+	Until     *time.Time // 流开始时间上限 (不含)
+}
+
+// AlertPage is one page of alert search results, newest first
+type AlertPage struct {
+	Alerts     []Alert          `json:"alerts"`
+	Total      int64            `json:"total"`                 // 满足过滤条件的告警总数
+	NextCursor uint             `json:"next_cursor,omitempty"` // 为 0 表示没有更多结果
+	Facets     map[string]int64 `json:"facets"`                // 各告警类型的数量，不受类型过滤影响
+}
+
+// addrKey returns the search key of an IP address, or "" when it is not an IP
+// IPv4 addresses are stored in their IPv4-mapped IPv6 form so both families share one ordering
+func addrKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	b := addr.Unmap().As16()
+	return hex.EncodeToString(b[:])
+}
+
+// addrRange returns the inclusive key range covered by an IP or CIDR prefix
+func addrRange(s string) (string, string, error) {
+	var prefix netip.Prefix
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid CIDR %q", s)
+		}
+		prefix = p.Masked()
+	} else {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid IP %q", s)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	bits := prefix.Bits()
+	addr := prefix.Addr()
+	if addr.Is4() {
+		bits += 96
+	}
+	first := addr.Unmap().As16()
+	last := first
+	for i := bits; i < 128; i++ {
+		last[i/8] |= 0x80 >> (i % 8)
+	}
+	return hex.EncodeToString(first[:]), hex.EncodeToString(last[:]), nil
+}
+
+// whereAddr restricts column to an IP or CIDR prefix
+func whereAddr(query *gorm.DB, column, value string) (*gorm.DB, error) {
+	first, last, err := addrRange(value)
+	if err != nil {
+		return nil, err
+	}
+	if first == last {
+		return query.Where(column+" = ?", first), nil
+	}
+	return query.Where(column+" BETWEEN ? AND ?", first, last), nil
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// applyAlertFilter adds every condition except labels and cursor to query
+func applyAlertFilter(query *gorm.DB, f AlertFilter) (*gorm.DB, error) {
+	var err error
+	if f.Source != "" {
+		if query, err = whereAddr(query, "src_addr", f.Source); err != nil {
+			return nil, err
+		}
+	}
+	if f.Dest != "" {
+		if query, err = whereAddr(query, "dst_addr", f.Dest); err != nil {
+			return nil, err
+		}
+	}
+	if f.MinConfidence != nil {
+		query = query.Where("confidence >= ?", *f.MinConfidence)
+	}
+	if f.MaxConfidence != nil {
+		query = query.Where("confidence <= ?", *f.MaxConfidence)
+	}
+	if f.Since != nil {
+		query = query.Where("created_at >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		query = query.Where("created_at < ?", *f.Until)
+	}
+	if f.IsRead != nil {
+		query = query.Where("is_read = ?", *f.IsRead)
+	}
+	if f.Query != "" {
+		query = query.Where(`payload LIKE ? ESCAPE '\'`, "%"+escapeLike(f.Query)+"%")
+	}
+	return query, nil
+}
+
+// addrMatcher returns a predicate for an IP or CIDR prefix; an empty value matches every address
+func addrMatcher(value string) (func(string) bool, error) {
+	if value == "" {
+		return func(string) bool { return true }, nil
+	}
+	first, last, err := addrRange(value)
+	if err != nil {
+		return nil, err
+	}
+	return func(ip string) bool {
+		key := addrKey(ip)
+		return key != "" && key >= first && key <= last
+	}, nil
+}
+
+// canonicalAddr returns the stored form of a single IP address, or "" for CIDR prefixes and empty values
+// Flow records store addresses as written by the decoder: IPv4 dotted quads and compressed lower-case IPv6
+func canonicalAddr(s string) string {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return ""
+	}
+	return addr.Unmap().WithZone("").String()
+}
+
+// exportBatchSize is the number of rows read per query while streaming an export
+const exportBatchSize = 500
+
+// EachAlert calls fn for every alert matching the filter, oldest first, reading them in batches
+// Cursor and Limit are ignored
+func (s *Store) EachAlert(f AlertFilter, fn func(Alert) error) error {
+	query, err := applyAlertFilter(s.db.Model(&Alert{}), f)
+	if err != nil {
+		return err
+	}
+	if len(f.Labels) > 0 {
+		query = query.Where("type IN ?", f.Labels)
+	}
+	var batch []Alert
+	return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, alert := range batch {
+			if err := fn(alert); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// EachFlowRecord calls fn for every flow record matching the filter, oldest first, reading them in batches
+// Flow records have no address search columns, so CIDR prefixes are matched while streaming
+func (s *Store) EachFlowRecord(f FlowFilter, fn func(FlowRecord) error) error {
+	matchSrc, err := addrMatcher(f.Source)
+	if err != nil {
+		return err
+	}
+	matchDst, err := addrMatcher(f.Dest)
+	if err != nil {
+		return err
+	}
+	query := s.db.Model(&FlowRecord{})
+	// 单个地址可以直接用索引过滤，比较前转换为存储时的规范写法
+	if addr := canonicalAddr(f.Source); addr != "" {
+		query = query.Where("src_ip = ?", addr)
+	}
+	if addr := canonicalAddr(f.Dest); addr != "" {
+		query = query.Where("dst_ip = ?", addr)
+	}
+	if len(f.Labels) > 0 {
+		query = query.Where("label IN ?", f.Labels)
+	}
+	if f.Malicious != nil {
+		query = query.Where("malicious = ?", *f.Malicious)
+	}
+	if f.AlertID != nil {
+		query = query.Where("alert_id = ?", *f.AlertID)
+	}
+	if f.Since != nil {
+		query = query.Where("start_time >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		query = query.Where("start_time < ?", *f.Until)
+	}
+
+	var batch []FlowRecord
+	return query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, record := range batch {
+			if !matchSrc(record.SrcIP) || !matchDst(record.DstIP) {
+				continue
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// SearchAlerts returns one page of alerts matching the filter with the total count and label facets
+// Pages are ordered by descending ID; pass NextCursor back as Cursor to fetch the next page
+func (s *Store) SearchAlerts(f AlertFilter) (*AlertPage, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	base, err := applyAlertFilter(s.db.Model(&Alert{}), f)
+	if err != nil {
+		return nil, err
+	}
+
+	// 类型分面不应用类型过滤，便于前端展示切换到其他类型后的数量
+	var facetRows []struct {
+		Type  string
+		Count int64
+	}
+	if err := base.Session(&gorm.Session{}).Select("type, COUNT(*) AS count").Group("type").Scan(&facetRows).Error; err != nil {
+		return nil, err
+	}
+	page := &AlertPage{Alerts: []Alert{}, Facets: make(map[string]int64, len(facetRows))}
+	for _, row := range facetRows {
+		page.Facets[row.Type] = row.Count
+	}
+
+	filtered := base.Session(&gorm.Session{})
+	if len(f.Labels) > 0 {
+		filtered = filtered.Where("type IN ?", f.Labels)
+	}
+	if err := filtered.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	list := filtered.Session(&gorm.Session{})
+	if f.Cursor > 0 {
+		list = list.Where("id < ?", f.Cursor)
+	}
+	// 多取一条以判断是否还有下一页
+	if err := list.Order("id desc").Limit(f.Limit + 1).Find(&page.Alerts).Error; err != nil {
+		return nil, err
+	}
+	if len(page.Alerts) > f.Limit {
+		page.Alerts = page.Alerts[:f.Limit]
+		page.NextCursor = page.Alerts[f.Limit-1].ID
+	}
+	return page, nil
+}
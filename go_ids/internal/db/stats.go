@@ -0,0 +1,310 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidQuery marks statistics errors caused by the query parameters rather than the database
+var ErrInvalidQuery = errors.New("invalid query")
+
+const (
+	// maxStatsBuckets 单次统计最多的时间桶数
+	maxStatsBuckets = 2000
+	defaultTopN     = 10
+	defaultBins     = 10
+)
+
+// StatsPoint represents a single data point in the chart
+type StatsPoint struct {
+	Label string `json:"label"` // Time label (e.g., "10:00", "Mon", "05-12")
+	Count int    `json:"count"`
+}
+
+// StatsQuery describes a threat statistics request. Zero values select the defaults
+type StatsQuery struct {
+	Filter   AlertFilter    // 告警过滤条件，Since/Until 为统计窗口，Cursor 与 Limit 不使用
+	Bucket   time.Duration  // 时间桶大小，0 表示按窗口长度自动选择；整天的桶按日历天划分
+	Location *time.Location // 时间桶边界所在的时区，nil 表示本地时区
+	TopN     int            // 排行榜长度，默认 10
+	Bins     int            // 置信度直方图的分箱数，默认 10
+}
+
+// StatsBucket is the number of alerts created in one time bucket
+type StatsBucket struct {
+	Start   time.Time        `json:"start"`
+	Total   int64            `json:"total"`
+	ByLabel map[string]int64 `json:"by_label"`
+}
+
+// StatsCount is one entry of a top-N list
+type StatsCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// PortCount is the number of malicious flows towards a port
+type PortCount struct {
+	Port     uint16 `json:"port"`
+	Protocol uint8  `json:"protocol"` // IP 协议号
+	Count    int64  `json:"count"`
+}
+
+// HistogramBin is one bin of the confidence histogram, covering [Min, Max)
+type HistogramBin struct {
+	Min   float32 `json:"min"`
+	Max   float32 `json:"max"`
+	Count int64   `json:"count"`
+}
+
+// ThreatStats is the result of a threat statistics query
+type ThreatStats struct {
+	Since      time.Time        `json:"since"`
+	Until      time.Time        `json:"until"`
+	Bucket     int64            `json:"bucket_seconds"`
+	Total      int64            `json:"total"`
+	Series     []StatsBucket    `json:"series"`
+	ByLabel    map[string]int64 `json:"by_label"`
+	TopSources []StatsCount     `json:"top_sources"`
+	TopTargets []StatsCount     `json:"top_targets"`
+	TopPorts   []PortCount      `json:"top_ports"` // 与匹配告警关联的流的目的端口
+	Confidence []HistogramBin   `json:"confidence"`
+}
+
+// autoBucket picks a bucket size that gives a readable number of points for the window
+func autoBucket(window time.Duration) time.Duration {
+	switch {
+	case window <= 6*time.Hour:
+		return 15 * time.Minute
+	case window <= 48*time.Hour:
+		return time.Hour
+	case window <= 90*24*time.Hour:
+		return 24 * time.Hour
+	default:
+		return 7 * 24 * time.Hour
+	}
+}
+
+// bucketStarts returns the start of every bucket overlapping [since, until)
+// Buckets of whole days follow the calendar of loc, so they stay aligned to local midnight across DST changes;
+// shorter buckets are aligned to multiples of the bucket size since local midnight
+func bucketStarts(since, until time.Time, bucket time.Duration, loc *time.Location) ([]time.Time, error) {
+	since = since.In(loc)
+	y, m, d := since.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, loc)
+
+	var first time.Time
+	var next func(time.Time) time.Time
+	if day := 24 * time.Hour; bucket%day == 0 {
+		days := int(bucket / day)
+		first = midnight
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, days) }
+	} else {
+		first = midnight.Add(since.Sub(midnight) / bucket * bucket)
+		next = func(t time.Time) time.Time { return t.Add(bucket) }
+	}
+
+	var starts []time.Time
+	for t := first; t.Before(until); t = next(t) {
+		if len(starts) == maxStatsBuckets {
+			return nil, fmt.Errorf("%w: too many buckets, at most %d are allowed", ErrInvalidQuery, maxStatsBuckets)
+		}
+		starts = append(starts, t)
+	}
+	return starts, nil
+}
+
+// caseIndex returns a CASE expression mapping column to the index of the last bound it reaches
+// Values below bounds[1] map to 0. Comparing against bound parameters instead of using date or math
+// functions keeps the grouping portable across SQL dialects
+func caseIndex(column string, bounds []interface{}) (string, []interface{}) {
+	var expr strings.Builder
+	args := make([]interface{}, 0, len(bounds))
+	expr.WriteString("CASE")
+	for i := len(bounds) - 1; i > 0; i-- {
+		expr.WriteString(" WHEN " + column + " >= ? THEN " + strconv.Itoa(i))
+		args = append(args, bounds[i])
+	}
+	expr.WriteString(" ELSE 0 END")
+	return expr.String(), args
+}
+
+// alertSeries counts the alerts of query per time bucket and label
+func alertSeries(query *gorm.DB, starts []time.Time) ([]StatsBucket, error) {
+	series := make([]StatsBucket, len(starts))
+	bounds := make([]interface{}, len(starts))
+	for i, t := range starts {
+		series[i] = StatsBucket{Start: t, ByLabel: map[string]int64{}}
+		bounds[i] = t
+	}
+	if len(starts) == 0 {
+		return series, nil
+	}
+
+	expr, args := caseIndex("created_at", bounds)
+	var rows []struct {
+		Bucket int
+		Type   string
+		Count  int64
+	}
+	err := query.Select(expr+" AS bucket, type, COUNT(*) AS count", args...).
+		Group("bucket, type").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if r.Bucket < 0 || r.Bucket >= len(series) {
+			continue
+		}
+		series[r.Bucket].Total += r.Count
+		series[r.Bucket].ByLabel[r.Type] += r.Count
+	}
+	return series, nil
+}
+
+// topCounts returns the n most frequent values of column
+func topCounts(query *gorm.DB, column string, n int) ([]StatsCount, error) {
+	counts := []StatsCount{}
+	err := query.Select(column + " AS key, COUNT(*) AS count").
+		Group(column).Order("count DESC, key").Limit(n).Scan(&counts).Error
+	return counts, err
+}
+
+// ThreatStats computes the alert time series, label breakdown, top sources, targets and ports
+// and the confidence histogram for a time window
+func (s *Store) ThreatStats(q StatsQuery) (*ThreatStats, error) {
+	loc := q.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	until := time.Now().In(loc)
+	if q.Filter.Until != nil {
+		until = q.Filter.Until.In(loc)
+	}
+	since := until.Add(-24 * time.Hour)
+	if q.Filter.Since != nil {
+		since = q.Filter.Since.In(loc)
+	}
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: since must be before until", ErrInvalidQuery)
+	}
+	q.Filter.Since, q.Filter.Until = &since, &until
+	if q.Bucket <= 0 {
+		q.Bucket = autoBucket(until.Sub(since))
+	}
+	if q.TopN <= 0 {
+		q.TopN = defaultTopN
+	}
+	if q.Bins <= 0 {
+		q.Bins = defaultBins
+	}
+
+	starts, err := bucketStarts(since, until, q.Bucket, loc)
+	if err != nil {
+		return nil, err
+	}
+	base, err := applyAlertFilter(s.db.Model(&Alert{}), q.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	if len(q.Filter.Labels) > 0 {
+		base = base.Where("type IN ?", q.Filter.Labels)
+	}
+	query := func() *gorm.DB { return base.Session(&gorm.Session{}) }
+
+	stats := &ThreatStats{Since: since, Until: until, Bucket: int64(q.Bucket / time.Second), ByLabel: map[string]int64{}}
+	if stats.Series, err = alertSeries(query(), starts); err != nil {
+		return nil, err
+	}
+	for _, b := range stats.Series {
+		stats.Total += b.Total
+		for label, n := range b.ByLabel {
+			stats.ByLabel[label] += n
+		}
+	}
+	if stats.TopSources, err = topCounts(query(), "source_ip", q.TopN); err != nil {
+		return nil, err
+	}
+	if stats.TopTargets, err = topCounts(query(), "dest_ip", q.TopN); err != nil {
+		return nil, err
+	}
+
+	stats.TopPorts = []PortCount{}
+	err = s.db.Model(&FlowRecord{}).Select("dst_port AS port, protocol, COUNT(*) AS count").
+		Where("alert_id IN (?)", query().Select("id")).
+		Group("dst_port, protocol").Order("count DESC, port").Limit(q.TopN).Scan(&stats.TopPorts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Confidence, err = confidenceHistogram(query(), q.Bins); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// confidenceHistogram counts alerts in equal-width confidence bins over [0, 1]
+// The last bin also holds confidences of exactly 1
+func confidenceHistogram(query *gorm.DB, bins int) ([]HistogramBin, error) {
+	hist := make([]HistogramBin, bins)
+	bounds := make([]interface{}, bins)
+	for i := range hist {
+		hist[i] = HistogramBin{Min: float32(i) / float32(bins), Max: float32(i+1) / float32(bins)}
+		bounds[i] = hist[i].Min
+	}
+
+	expr, args := caseIndex("confidence", bounds)
+	var rows []struct {
+		Bin   int
+		Count int64
+	}
+	if err := query.Select(expr+" AS bin, COUNT(*) AS count", args...).Group("bin").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if r.Bin >= 0 && r.Bin < bins {
+			hist[r.Bin].Count = r.Count
+		}
+	}
+	return hist, nil
+}
+
+// GetThreatStats returns the alert counts for the dashboard chart
+// "Day" covers the last 24 hours by hour, "Week" and any other range the last 7 or 30 days by day
+func (s *Store) GetThreatStats(rangeType string) ([]StatsPoint, error) {
+	now := time.Now()
+	y, m, d := now.Date()
+
+	var since time.Time
+	var bucket time.Duration
+	var layout string
+	switch rangeType {
+	case "Day":
+		since, bucket, layout = time.Date(y, m, d, now.Hour()-23, 0, 0, 0, now.Location()), time.Hour, "15:00"
+	case "Week":
+		since, bucket, layout = time.Date(y, m, d-6, 0, 0, 0, 0, now.Location()), 24*time.Hour, "Mon"
+	default: // Month
+		since, bucket, layout = time.Date(y, m, d-29, 0, 0, 0, 0, now.Location()), 24*time.Hour, "02"
+	}
+
+	starts, err := bucketStarts(since, now, bucket, now.Location())
+	if err != nil {
+		return nil, err
+	}
+	query := s.db.Model(&Alert{}).Where("created_at >= ?", since)
+	series, err := alertSeries(query, starts)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]StatsPoint, len(series))
+	for i, b := range series {
+		points[i] = StatsPoint{Label: b.Start.Format(layout), Count: int(b.Total)}
+	}
+	return points, nil
+}
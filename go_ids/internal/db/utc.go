@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// utcConnPool binds every time argument in UTC
+// SQLite keeps timestamps as text in the offset of the bound value and compares them as strings,
+// so values bound in different offsets (another time zone, or either side of a DST change) would not
+// sort by instant. Converting the arguments here covers inserts, updates and query bounds alike
+type utcConnPool struct {
+	*sql.DB
+}
+
+func (p *utcConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.DB.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (p *utcConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.DB.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (p *utcConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.DB.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+// BeginTx starts a transaction that converts its arguments as well
+func (p *utcConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &utcTx{tx}, nil
+}
+
+// GetDBConn exposes the connection pool to gorm.DB.DB()
+func (p *utcConnPool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
+
+// utcTx is a transaction of utcConnPool
+type utcTx struct {
+	*sql.Tx
+}
+
+func (t *utcTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+func (t *utcTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+func (t *utcTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+// utcArgs converts the time arguments of a query to UTC in place
+func utcArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				args[i] = v.UTC()
+			}
+		}
+	}
+	return args
+}
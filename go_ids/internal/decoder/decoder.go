@@ -1,6 +1,7 @@
 package decoder
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/gopacket"
@@ -26,24 +27,76 @@ type DecodedPacket struct {
 	TTL uint8
 }
 
+// 解码失败原因，用于按原因分组统计
+const (
+	FailureTruncated = "truncated" // 数据包被截断 (snaplen 过小或报文不完整)
+	FailureMalformed = "malformed" // 协议首部格式错误
+)
+
+// DecodeError 描述一次解码失败及其原因
+type DecodeError struct {
+	Reason string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("解码失败 (%s): %v", e.Reason, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
 // Decoder 定义了解码接口
 type Decoder struct {
-	eth  layers.Ethernet
-	ip4  layers.IPv4
-	ip6  layers.IPv6
-	tcp  layers.TCP
-	udp  layers.UDP
-	icmp layers.ICMPv4
-
-	parser *gopacket.DecodingLayerParser
+	eth   layers.Ethernet
+	dot1q layers.Dot1Q
+	sll   layers.LinuxSLL
+	sll2  LinuxSLL2
+	loop  layers.Loopback
+	ip4   layers.IPv4
+	ip6   layers.IPv6
+	tcp   layers.TCP
+	udp   layers.UDP
+	icmp  layers.ICMPv4
+
+	linkType layers.LinkType
+	parser   *gopacket.DecodingLayerParser
+	// 裸 IP 链路没有链路层首部，IPv6 报文需要单独的起始层
+	parser6 *gopacket.DecodingLayerParser
+	layers  []gopacket.LayerType
 }
 
-// NewDecoder 创建一个新的解码器
+// NewDecoder 创建一个以以太网为链路层的解码器
 func NewDecoder() *Decoder {
-	d := &Decoder{}
-	d.parser = gopacket.NewDecodingLayerParser(
-		layers.LayerTypeEthernet,
+	d, _ := NewDecoderForLinkType(layers.LinkTypeEthernet)
+	return d
+}
+
+// NewDecoderForLinkType 根据抓包句柄的链路类型创建解码器
+func NewDecoderForLinkType(linkType layers.LinkType) (*Decoder, error) {
+	first, rawIP, ok := firstLayerType(linkType)
+	if !ok {
+		return nil, fmt.Errorf("不支持的链路层类型: %s", linkType)
+	}
+
+	d := &Decoder{linkType: linkType}
+	d.parser = d.newParser(first)
+	if rawIP {
+		d.parser6 = d.newParser(layers.LayerTypeIPv6)
+	}
+	return d, nil
+}
+
+func (d *Decoder) newParser(first gopacket.LayerType) *gopacket.DecodingLayerParser {
+	parser := gopacket.NewDecodingLayerParser(
+		first,
 		&d.eth,
+		// VLAN 标签，QinQ 的外层标签之后仍是 Dot1Q
+		&d.dot1q,
+		&d.sll,
+		&d.sll2,
+		&d.loop,
 		&d.ip4,
 		&d.ip6,
 		&d.tcp,
@@ -51,8 +104,13 @@ func NewDecoder() *Decoder {
 		&d.icmp,
 	)
 	// 忽略未知层
-	d.parser.IgnoreUnsupported = true
-	return d
+	parser.IgnoreUnsupported = true
+	return parser
+}
+
+// LinkType 返回解码器使用的链路类型
+func (d *Decoder) LinkType() layers.LinkType {
+	return d.linkType
 }
 
 // Decode 解析一个原始数据包
@@ -62,39 +120,55 @@ func (d *Decoder) Decode(packet gopacket.Packet) (*DecodedPacket, error) {
 		Length:    packet.Metadata().Length,
 	}
 
-	// 使用 gopacket 的标准解析方式
-	// 或者使用 DecodingLayerParser 性能更高，但这里先用标准方式简化实现
-
-	// 1. 获取网络层 (IP)
-	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
-		ip, _ := ipLayer.(*layers.IPv4)
-		decoded.SrcIP = ip.SrcIP.String()
-		decoded.DstIP = ip.DstIP.String()
-		decoded.Protocol = uint8(ip.Protocol)
-		decoded.TTL = ip.TTL
-	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
-		ip, _ := ipLayer.(*layers.IPv6)
-		decoded.SrcIP = ip.SrcIP.String()
-		decoded.DstIP = ip.DstIP.String()
-		decoded.Protocol = uint8(ip.NextHeader)
-		decoded.TTL = ip.HopLimit
-	} else {
-		return nil, nil // 非 IP 包，忽略
+	// 直接对原始字节使用 DecodingLayerParser，不依赖 gopacket 对链路类型的识别
+	data := packet.Data()
+	parser := d.parser
+	if d.parser6 != nil && len(data) > 0 && data[0]>>4 == 6 {
+		parser = d.parser6
+	}
+	if err := parser.DecodeLayers(data, &d.layers); err != nil {
+		reason := FailureMalformed
+		if parser.Truncated {
+			reason = FailureTruncated
+		}
+		return nil, &DecodeError{Reason: reason, Err: err}
+	}
+
+	hasIP := false
+	for _, layerType := range d.layers {
+		switch layerType {
+		// 1. 网络层 (IP)
+		case layers.LayerTypeIPv4:
+			hasIP = true
+			decoded.SrcIP = d.ip4.SrcIP.String()
+			decoded.DstIP = d.ip4.DstIP.String()
+			decoded.Protocol = uint8(d.ip4.Protocol)
+			decoded.TTL = d.ip4.TTL
+		case layers.LayerTypeIPv6:
+			hasIP = true
+			decoded.SrcIP = d.ip6.SrcIP.String()
+			decoded.DstIP = d.ip6.DstIP.String()
+			decoded.Protocol = uint8(d.ip6.NextHeader)
+			decoded.TTL = d.ip6.HopLimit
+
+		// 2. 传输层 (TCP/UDP)
+		case layers.LayerTypeTCP:
+			decoded.SrcPort = uint16(d.tcp.SrcPort)
+			decoded.DstPort = uint16(d.tcp.DstPort)
+			decoded.TCPFlags = d.tcp
+			// 解析器会复用选项切片，这里复制一份
+			decoded.TCPFlags.Options = append([]layers.TCPOption(nil), d.tcp.Options...)
+			decoded.Window = d.tcp.Window
+			decoded.Payload = d.tcp.Payload
+		case layers.LayerTypeUDP:
+			decoded.SrcPort = uint16(d.udp.SrcPort)
+			decoded.DstPort = uint16(d.udp.DstPort)
+			decoded.Payload = d.udp.Payload
+		}
 	}
 
-	// 2. 获取传输层 (TCP/UDP)
-	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
-		tcp, _ := tcpLayer.(*layers.TCP)
-		decoded.SrcPort = uint16(tcp.SrcPort)
-		decoded.DstPort = uint16(tcp.DstPort)
-		decoded.TCPFlags = *tcp
-		decoded.Window = tcp.Window
-		decoded.Payload = tcp.Payload
-	} else if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
-		udp, _ := udpLayer.(*layers.UDP)
-		decoded.SrcPort = uint16(udp.SrcPort)
-		decoded.DstPort = uint16(udp.DstPort)
-		decoded.Payload = udp.Payload
+	if !hasIP {
+		return nil, nil // 非 IP 包，忽略
 	}
 
 	return decoded, nil
@@ -1,7 +1,9 @@
 package decoder
 
 import (
+	"errors"
 	"net"
+	"slices"
 	"testing"
 
 	"github.com/google/gopacket"
@@ -98,3 +100,188 @@ func TestDecodeNonIP(t *testing.T) {
 		t.Error("Expected nil for Non-IP packet, got struct")
 	}
 }
+
+// serializeIPTCP 构造不带链路层首部的 IP/TCP 报文
+func serializeIPTCP(t *testing.T, v6 bool) []byte {
+	tcp := layers.TCP{
+		SrcPort: layers.TCPPort(40000),
+		DstPort: layers.TCPPort(443),
+		ACK:     true,
+		Window:  1024,
+	}
+	var ipLayer gopacket.SerializableLayer
+	if v6 {
+		ip := &layers.IPv6{
+			Version:    6,
+			SrcIP:      net.ParseIP("2001:db8::1"),
+			DstIP:      net.ParseIP("2001:db8::2"),
+			NextHeader: layers.IPProtocolTCP,
+			HopLimit:   64,
+		}
+		tcp.SetNetworkLayerForChecksum(ip)
+		ipLayer = ip
+	} else {
+		ip := &layers.IPv4{
+			Version:  4,
+			SrcIP:    net.IP{10, 0, 0, 1},
+			DstIP:    net.IP{10, 0, 0, 2},
+			Protocol: layers.IPProtocolTCP,
+			TTL:      64,
+		}
+		tcp.SetNetworkLayerForChecksum(ip)
+		ipLayer = ip
+	}
+
+	buffer := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buffer, opts, ipLayer, &tcp, gopacket.Payload("data")); err != nil {
+		t.Fatalf("Failed to serialize packet: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func decodeWithLinkType(t *testing.T, linkType layers.LinkType, data []byte) *DecodedPacket {
+	d, err := NewDecoderForLinkType(linkType)
+	if err != nil {
+		t.Fatalf("NewDecoderForLinkType(%s) failed: %v", linkType, err)
+	}
+	// 与抓包源一致，使用 LinkDecoder 构造数据包
+	pkt := gopacket.NewPacket(data, LinkDecoder(linkType), gopacket.Default)
+	decoded, err := d.Decode(pkt)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded == nil {
+		t.Fatal("Decoded packet is nil")
+	}
+	return decoded
+}
+
+func checkIPv4TCP(t *testing.T, decoded *DecodedPacket) {
+	if decoded.SrcIP != "10.0.0.1" || decoded.DstIP != "10.0.0.2" {
+		t.Errorf("Unexpected IPs %s -> %s", decoded.SrcIP, decoded.DstIP)
+	}
+	if decoded.SrcPort != 40000 || decoded.DstPort != 443 {
+		t.Errorf("Unexpected ports %d -> %d", decoded.SrcPort, decoded.DstPort)
+	}
+	if decoded.Protocol != 6 {
+		t.Errorf("Expected Protocol 6, got %d", decoded.Protocol)
+	}
+	if string(decoded.Payload) != "data" {
+		t.Errorf("Expected payload 'data', got %q", decoded.Payload)
+	}
+}
+
+func TestDecodeLinuxSLL(t *testing.T) {
+	header := []byte{
+		0x00, 0x00, // packet type: host
+		0x00, 0x01, // ARPHRD_ETHER
+		0x00, 0x06, // address length
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x00,
+		0x08, 0x00, // IPv4
+	}
+	data := append(header, serializeIPTCP(t, false)...)
+	checkIPv4TCP(t, decodeWithLinkType(t, layers.LinkTypeLinuxSLL, data))
+}
+
+func TestDecodeLinuxSLL2(t *testing.T) {
+	header := []byte{
+		0x08, 0x00, // IPv4
+		0x00, 0x00, // reserved
+		0x00, 0x00, 0x00, 0x02, // interface index
+		0x00, 0x01, // ARPHRD_ETHER
+		0x00,                                           // packet type: host
+		0x06,                                           // address length
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x00, // address
+	}
+	data := append(header, serializeIPTCP(t, false)...)
+	checkIPv4TCP(t, decodeWithLinkType(t, LinkTypeLinuxSLL2, data))
+
+	// gopacket 的常规解码路径也应能识别 SLL2 之后的各层
+	pkt := gopacket.NewPacket(data, LinkDecoder(LinkTypeLinuxSLL2), gopacket.Default)
+	sll2, ok := pkt.LinkLayer().(*LinuxSLL2)
+	if !ok {
+		t.Fatal("Expected LinuxSLL2 link layer")
+	}
+	if sll2.InterfaceIndex != 2 {
+		t.Errorf("Expected interface index 2, got %d", sll2.InterfaceIndex)
+	}
+	if pkt.Layer(layers.LayerTypeTCP) == nil {
+		t.Error("Expected TCP layer after SLL2")
+	}
+}
+
+func TestDecodeVLAN(t *testing.T) {
+	eth := []byte{
+		0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, // dst
+		0x00, 0x11, 0x22, 0x33, 0x44, 0x55, // src
+	}
+	vlan := []byte{0x81, 0x00, 0x00, 0x64} // 802.1Q, VLAN 100
+	ipv4 := []byte{0x08, 0x00}
+
+	// 单层 VLAN 标签
+	data := slices.Concat(eth, vlan, ipv4, serializeIPTCP(t, false))
+	checkIPv4TCP(t, decodeWithLinkType(t, layers.LinkTypeEthernet, data))
+
+	// QinQ: 802.1ad 外层标签 + 802.1Q 内层标签
+	qinq := []byte{0x88, 0xa8, 0x00, 0xc8}
+	data = slices.Concat(eth, qinq, vlan, ipv4, serializeIPTCP(t, false))
+	checkIPv4TCP(t, decodeWithLinkType(t, layers.LinkTypeEthernet, data))
+}
+
+func TestDecodeRawIP(t *testing.T) {
+	// pcap_datalink 在 Linux 上返回 DLT_RAW (12)，文件中为 LINKTYPE_RAW (101)
+	for _, linkType := range []layers.LinkType{layers.LinkTypeRaw, 12} {
+		checkIPv4TCP(t, decodeWithLinkType(t, linkType, serializeIPTCP(t, false)))
+
+		decoded := decodeWithLinkType(t, linkType, serializeIPTCP(t, true))
+		if decoded.SrcIP != "2001:db8::1" || decoded.DstIP != "2001:db8::2" {
+			t.Errorf("Unexpected IPv6 addresses %s -> %s", decoded.SrcIP, decoded.DstIP)
+		}
+		if decoded.DstPort != 443 {
+			t.Errorf("Expected DstPort 443, got %d", decoded.DstPort)
+		}
+	}
+
+	checkIPv4TCP(t, decodeWithLinkType(t, layers.LinkTypeIPv4, serializeIPTCP(t, false)))
+	decoded := decodeWithLinkType(t, layers.LinkTypeIPv6, serializeIPTCP(t, true))
+	if decoded.TTL != 64 {
+		t.Errorf("Expected HopLimit 64, got %d", decoded.TTL)
+	}
+}
+
+func TestDecodeLoopback(t *testing.T) {
+	// BSD DLT_NULL 使用主机字节序的地址族
+	data := append([]byte{0x02, 0x00, 0x00, 0x00}, serializeIPTCP(t, false)...)
+	checkIPv4TCP(t, decodeWithLinkType(t, layers.LinkTypeNull, data))
+
+	// OpenBSD DLT_LOOP 使用网络字节序
+	data = append([]byte{0x00, 0x00, 0x00, 0x02}, serializeIPTCP(t, false)...)
+	checkIPv4TCP(t, decodeWithLinkType(t, layers.LinkTypeLoop, data))
+}
+
+func TestUnsupportedLinkType(t *testing.T) {
+	if _, err := NewDecoderForLinkType(layers.LinkTypeIEEE802_11); err == nil {
+		t.Error("Expected error for unsupported link type")
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	d := NewDecoder()
+	pkt := createTestPacket(t, []byte("hello"))
+	// 截掉 TCP 首部的后半部分
+	data := pkt.Data()[:14+20+10]
+	truncated := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+
+	decoded, err := d.Decode(truncated)
+	if decoded != nil {
+		t.Error("Expected nil for truncated packet")
+	}
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Expected DecodeError, got %v", err)
+	}
+	if decodeErr.Reason != FailureTruncated {
+		t.Errorf("Expected reason %s, got %s", FailureTruncated, decodeErr.Reason)
+	}
+}
@@ -0,0 +1,118 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// 非以太网链路类型
+// layers.LinkType 是 uint8，gopacket 的 pcap.Handle.LinkType() 直接截断 pcap_datalink 的返回值，
+// 因此 LINKTYPE_LINUX_SLL2 (276) 在这里表现为 20
+const (
+	LinkTypeLinuxSLL2 layers.LinkType = 276 & 0xff
+
+	// pcap_datalink 返回的是 DLT 值而非 LINKTYPE 值:
+	// DLT_RAW 在大多数平台上为 12，OpenBSD 上为 14，gopacket 只为 LINKTYPE_RAW (101) 注册了解码器
+	linkTypeRawDLT        layers.LinkType = 12
+	linkTypeRawDLTOpenBSD layers.LinkType = 14
+)
+
+// LayerTypeLinuxSLL2 是 Linux cooked capture v2 (抓包接口为 "any" 时的链路层)
+var LayerTypeLinuxSLL2 = gopacket.RegisterLayerType(1276, gopacket.LayerTypeMetadata{
+	Name:    "LinuxSLL2",
+	Decoder: gopacket.DecodeFunc(decodeLinuxSLL2),
+})
+
+// LinuxSLL2 是 LINKTYPE_LINUX_SLL2 的 20 字节首部
+// https://www.tcpdump.org/linktypes/LINKTYPE_LINUX_SLL2.html
+type LinuxSLL2 struct {
+	layers.BaseLayer
+	ProtocolType   layers.EthernetType
+	InterfaceIndex uint32
+	ARPHRDType     uint16
+	PacketType     layers.LinuxSLLPacketType
+	AddrLen        uint8
+	Addr           net.HardwareAddr
+}
+
+// LayerType 返回 LayerTypeLinuxSLL2
+func (s *LinuxSLL2) LayerType() gopacket.LayerType { return LayerTypeLinuxSLL2 }
+
+// CanDecode 返回可解码的层类型
+func (s *LinuxSLL2) CanDecode() gopacket.LayerClass { return LayerTypeLinuxSLL2 }
+
+// NextLayerType 返回下一层类型
+func (s *LinuxSLL2) NextLayerType() gopacket.LayerType { return s.ProtocolType.LayerType() }
+
+// LinkFlow 返回链路层地址构成的流
+func (s *LinuxSLL2) LinkFlow() gopacket.Flow {
+	return gopacket.NewFlow(layers.EndpointMAC, s.Addr, nil)
+}
+
+// DecodeFromBytes 解析 SLL2 首部
+func (s *LinuxSLL2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 20 {
+		df.SetTruncated()
+		return errors.New("Linux SLL2 packet too small")
+	}
+	s.ProtocolType = layers.EthernetType(binary.BigEndian.Uint16(data[0:2]))
+	s.InterfaceIndex = binary.BigEndian.Uint32(data[4:8])
+	s.ARPHRDType = binary.BigEndian.Uint16(data[8:10])
+	s.PacketType = layers.LinuxSLLPacketType(data[10])
+	s.AddrLen = data[11]
+	addrLen := int(s.AddrLen)
+	if addrLen > 8 {
+		addrLen = 8
+	}
+	s.Addr = net.HardwareAddr(data[12 : 12+addrLen])
+	s.BaseLayer = layers.BaseLayer{Contents: data[:20], Payload: data[20:]}
+	return nil
+}
+
+func decodeLinuxSLL2(data []byte, p gopacket.PacketBuilder) error {
+	s := &LinuxSLL2{}
+	if err := s.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(s)
+	p.SetLinkLayer(s)
+	return p.NextDecoder(s.ProtocolType)
+}
+
+// LinkDecoder 返回给 gopacket.NewPacketSource 使用的链路层解码器，
+// 补齐 gopacket 默认不认识的 SLL2 与 DLT_RAW
+func LinkDecoder(linkType layers.LinkType) gopacket.Decoder {
+	switch linkType {
+	case LinkTypeLinuxSLL2:
+		return LayerTypeLinuxSLL2
+	case linkTypeRawDLT, linkTypeRawDLTOpenBSD:
+		return layers.LinkTypeRaw
+	}
+	return linkType
+}
+
+// firstLayerType 根据链路类型返回快速解析路径的起始层
+// 对裸 IP 链路返回 IPv4，实际版本在解码时按首字节判断
+func firstLayerType(linkType layers.LinkType) (first gopacket.LayerType, rawIP bool, ok bool) {
+	switch linkType {
+	case layers.LinkTypeEthernet:
+		return layers.LayerTypeEthernet, false, true
+	case layers.LinkTypeLinuxSLL:
+		return layers.LayerTypeLinuxSLL, false, true
+	case LinkTypeLinuxSLL2:
+		return LayerTypeLinuxSLL2, false, true
+	case layers.LinkTypeNull, layers.LinkTypeLoop:
+		return layers.LayerTypeLoopback, false, true
+	case layers.LinkTypeRaw, linkTypeRawDLT, linkTypeRawDLTOpenBSD:
+		return layers.LayerTypeIPv4, true, true
+	case layers.LinkTypeIPv4:
+		return layers.LayerTypeIPv4, false, true
+	case layers.LinkTypeIPv6:
+		return layers.LayerTypeIPv6, false, true
+	}
+	return gopacket.LayerTypeZero, false, false
+}
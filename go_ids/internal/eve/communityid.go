@@ -0,0 +1,52 @@
+package eve
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"net/netip"
+
+	"go-ids/internal/flow"
+
+	"github.com/google/gopacket/layers"
+)
+
+// CommunityID 计算流的 Community ID v1 (https://github.com/corelight/community-id-spec)
+// 相同的流在 Suricata、Zeek 与 go-ids 中得到相同的值，方便跨工具关联
+// ICMP 需要按类型/代码映射端口，流键中没有这些信息，因此返回空字符串
+func CommunityID(key flow.FlowKey, seed uint16) string {
+	src, err1 := netip.ParseAddr(key.SrcIP)
+	dst, err2 := netip.ParseAddr(key.DstIP)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	src, dst = src.Unmap(), dst.Unmap()
+
+	var hasPorts bool
+	switch key.Proto {
+	case layers.IPProtocolTCP, layers.IPProtocolUDP, layers.IPProtocolSCTP:
+		hasPorts = true
+	case layers.IPProtocolICMPv4, layers.IPProtocolICMPv6:
+		return ""
+	}
+
+	// 端点排序，使两个方向得到同一个值
+	sport, dport := key.SrcPort, key.DstPort
+	srcBytes, dstBytes := src.AsSlice(), dst.AsSlice()
+	if c := bytes.Compare(srcBytes, dstBytes); c > 0 || (c == 0 && hasPorts && sport > dport) {
+		srcBytes, dstBytes = dstBytes, srcBytes
+		sport, dport = dport, sport
+	}
+
+	h := sha1.New()
+	binary.Write(h, binary.BigEndian, seed)
+	h.Write(srcBytes)
+	h.Write(dstBytes)
+	h.Write([]byte{byte(key.Proto), 0})
+	if hasPorts {
+		binary.Write(h, binary.BigEndian, sport)
+		binary.Write(h, binary.BigEndian, dport)
+	}
+	return "1:" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,27 @@
+package eve
+
+import (
+	"testing"
+
+	"go-ids/internal/flow"
+
+	"github.com/google/gopacket/layers"
+)
+
+// 测试向量来自 community-id-spec 的参考实现
+func TestCommunityID(t *testing.T) {
+	cases := []struct {
+		key  flow.FlowKey
+		seed uint16
+		want string
+	}{
+		{flow.FlowKey{SrcIP: "128.232.110.120", DstIP: "66.35.250.204", SrcPort: 34855, DstPort: 80, Proto: layers.IPProtocolTCP}, 0, "1:LQU9qZlK+B5F3KDmev6m5PMibrg="},
+		{flow.FlowKey{SrcIP: "66.35.250.204", DstIP: "128.232.110.120", SrcPort: 80, DstPort: 34855, Proto: layers.IPProtocolTCP}, 0, "1:LQU9qZlK+B5F3KDmev6m5PMibrg="},
+		{flow.FlowKey{SrcIP: "192.168.1.52", DstIP: "8.8.8.8", SrcPort: 54585, DstPort: 53, Proto: layers.IPProtocolUDP}, 0, "1:d/FP5EW3wiY1vCndhwleRRKHowQ="},
+	}
+	for _, tc := range cases {
+		if got := CommunityID(tc.key, tc.seed); got != tc.want {
+			t.Errorf("CommunityID(%s) = %s, want %s", tc.key, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package eve
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ids/internal/flow"
+	"go-ids/internal/inference"
+	"go-ids/internal/loader"
+	"go-ids/internal/metrics"
+
+	"github.com/google/gopacket/layers"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// timeLayout Suricata 的时间戳格式
+const timeLayout = "2006-01-02T15:04:05.000000-0700"
+
+// signatureBase go-ids 告警的 signature_id 起始值，各攻击类型按名称哈希落在其后的 100000 个编号中
+const signatureBase = 9000000
+
+// Time 按 Suricata 格式序列化的时间
+type Time time.Time
+
+// MarshalJSON 实现 json.Marshaler
+func (t Time) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + time.Time(t).Format(timeLayout) + `"`), nil
+}
+
+// Event 一条 EVE 记录，字段名与 Suricata eve.json 一致
+type Event struct {
+	Timestamp   Time          `json:"timestamp"`
+	FlowID      uint64        `json:"flow_id,omitempty"`
+	InIface     string        `json:"in_iface,omitempty"`
+	EventType   string        `json:"event_type"`
+	SrcIP       string        `json:"src_ip,omitempty"`
+	SrcPort     uint16        `json:"src_port,omitempty"`
+	DestIP      string        `json:"dest_ip,omitempty"`
+	DestPort    uint16        `json:"dest_port,omitempty"`
+	Proto       string        `json:"proto,omitempty"`
+	CommunityID string        `json:"community_id,omitempty"`
+	Alert       *AlertInfo    `json:"alert,omitempty"`
+	Flow        *FlowInfo     `json:"flow,omitempty"`
+	Stats       *StatsInfo    `json:"stats,omitempty"`
+	Payload     string        `json:"payload_printable,omitempty"`
+	Prediction  *PredictionML `json:"ml,omitempty"` // go-ids 扩展: 模型的预测结果
+}
+
+// AlertInfo alert 事件的告警信息
+type AlertInfo struct {
+	Action      string              `json:"action"` // allowed 或 blocked
+	GID         int                 `json:"gid"`
+	SignatureID int                 `json:"signature_id"`
+	Rev         int                 `json:"rev"`
+	Signature   string              `json:"signature"`
+	Category    string              `json:"category"`
+	Severity    int                 `json:"severity"` // 1 最高，3 最低
+	Metadata    map[string][]string `json:"metadata,omitempty"`
+}
+
+// FlowInfo 流统计，alert 事件中记录到告警时为止的统计
+type FlowInfo struct {
+	PktsToServer  uint64 `json:"pkts_toserver"`
+	PktsToClient  uint64 `json:"pkts_toclient"`
+	BytesToServer uint64 `json:"bytes_toserver"`
+	BytesToClient uint64 `json:"bytes_toclient"`
+	Start         Time   `json:"start"`
+	End           *Time  `json:"end,omitempty"`
+	Age           int64  `json:"age,omitempty"`
+	State         string `json:"state,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	Alerted       bool   `json:"alerted"`
+}
+
+// PredictionML 模型对流的分类结果
+type PredictionML struct {
+	Label       string  `json:"label"`
+	Probability float32 `json:"probability"`
+}
+
+// StatsInfo stats 事件，结构参照 Suricata 的同名字段
+type StatsInfo struct {
+	Uptime  int64          `json:"uptime"`
+	Capture StatsCapture   `json:"capture"`
+	Decoder StatsDecoder   `json:"decoder"`
+	Flow    StatsFlow      `json:"flow"`
+	Detect  StatsDetect    `json:"detect"`
+	Ips     *StatsVerdicts `json:"ips,omitempty"`
+	Syslog  *StatsSyslog   `json:"syslog,omitempty"`
+}
+
+type StatsCapture struct {
+	KernelPackets int64 `json:"kernel_packets"`
+	KernelDrops   int64 `json:"kernel_drops"`
+	KernelIfdrops int64 `json:"kernel_ifdrops"`
+}
+
+type StatsDecoder struct {
+	Pkts    uint64 `json:"pkts"`
+	Invalid uint64 `json:"invalid"`
+}
+
+type StatsFlow struct {
+	Total   uint64 `json:"total"`
+	Expired uint64 `json:"expired"`
+}
+
+type StatsDetect struct {
+	Alert           uint64 `json:"alert"`
+	AlertSuppressed uint64 `json:"alerts_suppressed"`
+	Inferences      uint64 `json:"inferences"`
+	InferenceErrors uint64 `json:"inference_errors"`
+}
+
+type StatsVerdicts struct {
+	Accepted uint64 `json:"accepted"`
+	Blocked  uint64 `json:"blocked"`
+}
+
+type StatsSyslog struct {
+	Dropped uint64 `json:"dropped"`
+}
+
+// Logger 写入 Suricata EVE 兼容的 NDJSON 事件日志，按 lumberjack 轮转
+// 所有方法在接收者为 nil 时不做任何事，未启用时调用方无需判断
+type Logger struct {
+	out     io.WriteCloser
+	types   map[string]bool
+	seed    uint16
+	started time.Time
+
+	mu   sync.Mutex
+	sigs map[string]int // 攻击类型 -> signature_id
+}
+
+// New 按配置创建 EVE 日志，文件所在目录不存在时自动创建
+func New(cfg loader.EveConfig) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.FilePath), 0755); err != nil {
+		return nil, err
+	}
+	out := &lumberjack.Logger{
+		Filename:   cfg.FilePath,
+		MaxSize:    cfg.MaxSize, // MB
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge, // days
+		Compress:   true,
+	}
+	return newLogger(out, cfg), nil
+}
+
+func newLogger(out io.WriteCloser, cfg loader.EveConfig) *Logger {
+	types := make(map[string]bool)
+	for _, t := range cfg.Types {
+		types[t] = true
+	}
+	return &Logger{
+		out:     out,
+		types:   types,
+		seed:    cfg.CommunityIDSeed,
+		started: time.Now(),
+		sigs:    make(map[string]int),
+	}
+}
+
+// Enabled 报告是否输出某种事件类型
+func (l *Logger) Enabled(eventType string) bool {
+	return l != nil && l.types[eventType]
+}
+
+// Alert 记录一条告警，blocked 表示源已被封禁或流量在内联模式下被丢弃
+func (l *Logger) Alert(f *flow.Flow, pred inference.Prediction, blocked bool) {
+	if !l.Enabled(loader.EveTypeAlert) {
+		return
+	}
+	action := "allowed"
+	if blocked {
+		action = "blocked"
+	}
+
+	ev := l.flowEvent(loader.EveTypeAlert, f, time.Now())
+	ev.Alert = &AlertInfo{
+		Action:      action,
+		GID:         1,
+		SignatureID: l.signatureID(pred.Label),
+		Rev:         1,
+		Signature:   "GO-IDS ML " + pred.Label + " detected",
+		Category:    pred.Label,
+		Severity:    severity(pred.Probability),
+		Metadata:    map[string][]string{"confidence": {strconv.FormatFloat(float64(pred.Probability), 'f', 4, 32)}},
+	}
+	ev.Flow = flowInfo(f)
+	ev.Flow.Alerted = true
+	ev.Payload = printable(f.RawPayload)
+	ev.Prediction = &PredictionML{Label: pred.Label, Probability: pred.Probability}
+	l.write(ev)
+}
+
+// Flow 记录一条已结束的流，pred 为 nil 表示流没有经过推理 (如已在检查点告警)
+func (l *Logger) Flow(f *flow.Flow, pred *inference.Prediction, alerted bool) {
+	if !l.Enabled(loader.EveTypeFlow) {
+		return
+	}
+	ev := l.flowEvent(loader.EveTypeFlow, f, time.Now())
+	ev.Flow = flowInfo(f)
+	end := Time(f.LastTime)
+	ev.Flow.End = &end
+	ev.Flow.Age = int64(f.LastTime.Sub(f.StartTime).Seconds())
+	ev.Flow.State = "closed"
+	if f.Key.Proto == layers.IPProtocolTCP && f.FINFlagCount == 0 && f.RSTFlagCount == 0 {
+		ev.Flow.State = "established"
+	}
+	ev.Flow.Reason = "timeout"
+	ev.Flow.Alerted = alerted
+	if pred != nil {
+		ev.Prediction = &PredictionML{Label: pred.Label, Probability: pred.Probability}
+	}
+	l.write(ev)
+}
+
+// Stats 记录一次统计快照
+func (l *Logger) Stats(s metrics.Snapshot) {
+	if !l.Enabled(loader.EveTypeStats) {
+		return
+	}
+	capture := s.CaptureTotals()
+	stats := &StatsInfo{
+		Uptime: int64(time.Since(l.started).Seconds()),
+		Capture: StatsCapture{
+			KernelPackets: capture.Received,
+			KernelDrops:   capture.Dropped,
+			KernelIfdrops: capture.IfDropped,
+		},
+		Decoder: StatsDecoder{Pkts: s.PacketsProcessed, Invalid: sum(s.DecodeFailures)},
+		Flow:    StatsFlow{Total: s.FlowsCreated, Expired: s.FlowsExpired},
+		Detect: StatsDetect{
+			Alert:           s.AlertsRaised,
+			AlertSuppressed: s.AlertsDeduped + s.AlertsThrottled,
+			Inferences:      s.Inferences,
+			InferenceErrors: s.InferenceErrors,
+		},
+	}
+	if s.VerdictsAccepted+s.VerdictsDropped > 0 {
+		stats.Ips = &StatsVerdicts{Accepted: s.VerdictsAccepted, Blocked: s.VerdictsDropped}
+	}
+	if s.SyslogDropped > 0 {
+		stats.Syslog = &StatsSyslog{Dropped: s.SyslogDropped}
+	}
+	l.write(Event{Timestamp: Time(s.Timestamp), EventType: loader.EveTypeStats, Stats: stats})
+}
+
+// Run 按 interval 输出 stats 事件，直到 stop 被关闭
+func (l *Logger) Run(collector *metrics.Collector, interval time.Duration, stop <-chan struct{}) {
+	if !l.Enabled(loader.EveTypeStats) {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.Stats(collector.Latest())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Close 关闭日志文件
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.out.Close()
+}
+
+func (l *Logger) flowEvent(eventType string, f *flow.Flow, now time.Time) Event {
+	return Event{
+		Timestamp:   Time(now),
+		FlowID:      flowID(f),
+		InIface:     f.Interface,
+		EventType:   eventType,
+		SrcIP:       f.Key.SrcIP,
+		SrcPort:     f.Key.SrcPort,
+		DestIP:      f.Key.DstIP,
+		DestPort:    f.Key.DstPort,
+		Proto:       protoName(f.Key.Proto),
+		CommunityID: CommunityID(f.Key, l.seed),
+	}
+}
+
+func (l *Logger) write(ev Event) {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		logrus.Errorf("序列化 EVE 事件失败: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.out.Write(line); err != nil {
+		logrus.Errorf("写入 EVE 日志失败: %v", err)
+	}
+}
+
+// signatureID 返回攻击类型的 signature_id，由名称决定，重启后保持不变
+func (l *Logger) signatureID(label string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id, ok := l.sigs[label]
+	if !ok {
+		h := fnv.New32a()
+		h.Write([]byte(label))
+		id = signatureBase + int(h.Sum32()%100000)
+		l.sigs[label] = id
+	}
+	return id
+}
+
+// flowID 由流键与开始时间生成，同一条流的 alert 与 flow 事件相同
+// 限制在 2^51 以内，避免 JavaScript 等按 double 解析时丢失精度
+func flowID(f *flow.Flow) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(f.Key.String()))
+	h.Write([]byte(f.StartTime.Format(time.RFC3339Nano)))
+	return h.Sum64() & (1<<51 - 1)
+}
+
+func flowInfo(f *flow.Flow) *FlowInfo {
+	return &FlowInfo{
+		PktsToServer:  f.FwdPackets,
+		PktsToClient:  f.BwdPackets,
+		BytesToServer: f.FwdBytes,
+		BytesToClient: f.BwdBytes,
+		Start:         Time(f.StartTime),
+	}
+}
+
+// severity 将置信度映射为 Suricata 的 1-3 级严重程度
+func severity(probability float32) int {
+	switch {
+	case probability >= 0.95:
+		return 1
+	case probability >= 0.85:
+		return 2
+	}
+	return 3
+}
+
+func protoName(p layers.IPProtocol) string {
+	switch p {
+	case layers.IPProtocolTCP:
+		return "TCP"
+	case layers.IPProtocolUDP:
+		return "UDP"
+	case layers.IPProtocolICMPv4:
+		return "ICMP"
+	case layers.IPProtocolICMPv6:
+		return "IPv6-ICMP"
+	case layers.IPProtocolSCTP:
+		return "SCTP"
+	}
+	return strconv.Itoa(int(p))
+}
+
+// printable 与 Suricata 的 payload_printable 相同，不可打印字符替换为 '.'
+func printable(payload []byte) string {
+	var b strings.Builder
+	b.Grow(len(payload))
+	for _, c := range payload {
+		if c < 0x20 && c != '\n' && c != '\r' || c >= 0x7f {
+			c = '.'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func sum(m map[string]uint64) uint64 {
+	var total uint64
+	for _, n := range m {
+		total += n
+	}
+	return total
+}
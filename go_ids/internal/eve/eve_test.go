@@ -0,0 +1,150 @@
+package eve
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-ids/internal/flow"
+	"go-ids/internal/inference"
+	"go-ids/internal/loader"
+	"go-ids/internal/metrics"
+
+	"github.com/google/gopacket/layers"
+)
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func testFlow() *flow.Flow {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return &flow.Flow{
+		Key:        flow.FlowKey{SrcIP: "128.232.110.120", DstIP: "66.35.250.204", SrcPort: 34855, DstPort: 80, Proto: layers.IPProtocolTCP},
+		Interface:  "eth0",
+		StartTime:  start,
+		LastTime:   start.Add(90 * time.Second),
+		FwdPackets: 10,
+		BwdPackets: 8,
+		FwdBytes:   1200,
+		BwdBytes:   5400,
+		RawPayload: []byte("GET / HTTP/1.1\r\n\x00\xff"),
+	}
+}
+
+// decode 解析 NDJSON 输出中的每一行
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var events []map[string]any
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var ev map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestAlertAndFlowEvents(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(nopCloser{&buf}, loader.EveConfig{Types: []string{loader.EveTypeAlert, loader.EveTypeFlow}})
+
+	f := testFlow()
+	pred := inference.Prediction{Label: "Web Attack", Probability: 0.97}
+	l.Alert(f, pred, true)
+	l.Flow(f, &pred, true)
+	l.Stats(metrics.Snapshot{}) // 未启用 stats
+
+	events := decode(t, &buf)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	alert, fl := events[0], events[1]
+
+	if alert["event_type"] != "alert" || alert["src_ip"] != "128.232.110.120" || alert["dest_port"] != float64(80) ||
+		alert["proto"] != "TCP" || alert["in_iface"] != "eth0" || alert["community_id"] != "1:LQU9qZlK+B5F3KDmev6m5PMibrg=" {
+		t.Errorf("unexpected alert header: %v", alert)
+	}
+	info := alert["alert"].(map[string]any)
+	if info["action"] != "blocked" || info["category"] != "Web Attack" || info["severity"] != float64(1) {
+		t.Errorf("unexpected alert info: %v", info)
+	}
+	if alert["payload_printable"] != "GET / HTTP/1.1\r\n.." {
+		t.Errorf("unexpected payload: %q", alert["payload_printable"])
+	}
+	if alert["flow_id"] != fl["flow_id"] {
+		t.Error("alert and flow events should share flow_id")
+	}
+
+	stats := fl["flow"].(map[string]any)
+	if stats["pkts_toserver"] != float64(10) || stats["bytes_toclient"] != float64(5400) || stats["age"] != float64(90) ||
+		stats["start"] != "2024-05-01T12:00:00.000000+0000" || stats["alerted"] != true || stats["state"] != "established" {
+		t.Errorf("unexpected flow info: %v", stats)
+	}
+	if ml := fl["ml"].(map[string]any); ml["label"] != "Web Attack" {
+		t.Errorf("unexpected prediction: %v", ml)
+	}
+}
+
+func TestStatsEvent(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(nopCloser{&buf}, loader.EveConfig{Types: []string{loader.EveTypeStats}})
+	l.Stats(metrics.Snapshot{
+		Timestamp:        time.Now(),
+		Capture:          []metrics.CaptureStats{{Received: 100, Dropped: 2}, {Received: 50, IfDropped: 1}},
+		PacketsProcessed: 148,
+		DecodeFailures:   map[string]uint64{"non_ip": 3, "truncated": 1},
+		AlertsRaised:     5,
+		AlertsDeduped:    7,
+		AlertsThrottled:  1,
+	})
+
+	events := decode(t, &buf)
+	if len(events) != 1 || events[0]["event_type"] != "stats" {
+		t.Fatalf("unexpected events: %v", events)
+	}
+	stats := events[0]["stats"].(map[string]any)
+	capture := stats["capture"].(map[string]any)
+	detect := stats["detect"].(map[string]any)
+	if capture["kernel_packets"] != float64(150) || capture["kernel_drops"] != float64(2) ||
+		stats["decoder"].(map[string]any)["invalid"] != float64(4) || detect["alerts_suppressed"] != float64(8) {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+	if _, ok := stats["ips"]; ok {
+		t.Error("ips section should be omitted in passive mode")
+	}
+}
+
+func TestLoggerFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "eve.json")
+	l, err := New(loader.EveConfig{FilePath: path, MaxSize: 1, Types: []string{loader.EveTypeFlow}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Flow(testFlow(), nil, false)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(data, []byte("\n")) || !bytes.Contains(data, []byte(`"event_type":"flow"`)) {
+		t.Errorf("unexpected file content: %s", data)
+	}
+
+	// 未启用时 main 持有 nil 日志
+	var disabled *Logger
+	disabled.Alert(testFlow(), inference.Prediction{}, false)
+	disabled.Flow(testFlow(), nil, false)
+	if err := disabled.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
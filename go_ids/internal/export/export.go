@@ -0,0 +1,170 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ids/internal/db"
+)
+
+// Format 导出格式
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson" // 每行一个 JSON 对象
+	FormatSTIX   Format = "stix"   // STIX 2.1 bundle
+)
+
+// ParseFormat 解析导出格式，空字符串表示 CSV
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatNDJSON, FormatSTIX:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, expected csv, ndjson or stix", s)
+}
+
+// ContentType 返回格式对应的 HTTP Content-Type
+func (f Format) ContentType() string {
+	switch f {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatSTIX:
+		return "application/stix+json;version=2.1"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Extension 返回格式对应的文件扩展名
+func (f Format) Extension() string {
+	if f == FormatSTIX {
+		return "json"
+	}
+	return string(f)
+}
+
+// Alerts 将满足过滤条件的告警按格式写入 w，返回写出的告警数
+// 告警分批从数据库读取并立即写出，不会一次性加载到内存
+func Alerts(w io.Writer, format Format, store db.AlertRepository, f db.AlertFilter) (int, error) {
+	var write func(db.Alert) error
+	var finish func() error
+	switch format {
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(a db.Alert) error { return enc.Encode(a) }
+		finish = func() error { return nil }
+	case FormatSTIX:
+		sw := newSTIXWriter(w, time.Now())
+		write, finish = sw.writeAlert, sw.close
+	default:
+		cw := csv.NewWriter(w)
+		cw.Write(alertHeader)
+		write = func(a db.Alert) error { return cw.Write(alertRow(a)) }
+		finish = func() error { cw.Flush(); return cw.Error() }
+	}
+
+	n := 0
+	err := store.EachAlert(f, func(a db.Alert) error {
+		n++
+		return write(a)
+	})
+	// 出错时也写出结尾，使已写出的部分仍是完整的文件
+	if ferr := finish(); err == nil {
+		err = ferr
+	}
+	return n, err
+}
+
+// Flows 将满足过滤条件的流记录按格式写入 w，返回写出的记录数
+func Flows(w io.Writer, format Format, store db.FlowRecordRepository, f db.FlowFilter) (int, error) {
+	var write func(db.FlowRecord) error
+	var finish func() error
+	switch format {
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(r db.FlowRecord) error { return enc.Encode(r) }
+		finish = func() error { return nil }
+	case FormatSTIX:
+		sw := newSTIXWriter(w, time.Now())
+		write, finish = sw.writeFlow, sw.close
+	default:
+		cw := csv.NewWriter(w)
+		cw.Write(flowHeader)
+		write = func(r db.FlowRecord) error { return cw.Write(flowRow(r)) }
+		finish = func() error { cw.Flush(); return cw.Error() }
+	}
+
+	n := 0
+	err := store.EachFlowRecord(f, func(r db.FlowRecord) error {
+		n++
+		return write(r)
+	})
+	if ferr := finish(); err == nil {
+		err = ferr
+	}
+	return n, err
+}
+
+// csvTime 导出的时间统一为 RFC 3339 UTC
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// csvText 转义可能被电子表格当作公式执行的文本单元格 (CSV 注入)
+// 载荷、攻击类型与接口名可能受攻击者控制，以 = + - @ 制表符或回车开头时加上 ' 前缀
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func csvFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+func csvID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+var alertHeader = []string{
+	"id", "timestamp", "last_seen", "source_ip", "dest_ip", "type", "confidence", "count",
+	"interface", "is_read", "acked_by", "verdict", "incident_id", "payload",
+}
+
+func alertRow(a db.Alert) []string {
+	return []string{
+		strconv.FormatUint(uint64(a.ID), 10), csvTime(a.CreatedAt), csvTime(a.LastSeen),
+		a.SourceIP, a.DestIP, csvText(a.Type), csvFloat(a.Confidence), strconv.Itoa(a.Count),
+		csvText(a.Interface), strconv.FormatBool(a.IsRead), csvText(a.AckedBy), csvText(a.Verdict), csvID(a.IncidentID), csvText(a.Payload),
+	}
+}
+
+var flowHeader = []string{
+	"id", "start_time", "end_time", "src_ip", "src_port", "dst_ip", "dst_port", "protocol", "interface",
+	"fwd_packets", "bwd_packets", "fwd_bytes", "bwd_bytes", "label", "confidence", "malicious", "alert_id",
+}
+
+func flowRow(r db.FlowRecord) []string {
+	return []string{
+		strconv.FormatUint(uint64(r.ID), 10), csvTime(r.StartTime), csvTime(r.EndTime),
+		r.SrcIP, strconv.Itoa(int(r.SrcPort)), r.DstIP, strconv.Itoa(int(r.DstPort)), strconv.Itoa(int(r.Protocol)), csvText(r.Interface),
+		strconv.FormatUint(r.FwdPackets, 10), strconv.FormatUint(r.BwdPackets, 10),
+		strconv.FormatUint(r.FwdBytes, 10), strconv.FormatUint(r.BwdBytes, 10),
+		csvText(r.Label), csvFloat(r.Confidence), strconv.FormatBool(r.Malicious), csvID(r.AlertID),
+	}
+}
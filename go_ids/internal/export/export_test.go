@@ -0,0 +1,244 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ids/internal/db"
+)
+
+func initTestDB(t *testing.T) *db.Store {
+	t.Helper()
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// seed 写入三条告警与三条流记录
+func seed(t *testing.T, store *db.Store) {
+	t.Helper()
+	now := time.Now()
+	alerts := []db.Alert{
+		{CreatedAt: now.Add(-2 * time.Hour), SourceIP: "192.0.2.7", DestIP: "10.0.0.1", Type: "PortScan", Confidence: 0.91, Count: 3, Payload: "a,\"b\"\nc"},
+		{CreatedAt: now.Add(-time.Hour), SourceIP: "2001:db8::9", DestIP: "10.0.0.2", Type: "DDoS", Confidence: 0.99},
+		{CreatedAt: now, SourceIP: "198.51.100.4", DestIP: "10.0.0.1", Type: "PortScan", Confidence: 0.7},
+	}
+	for i := range alerts {
+		if err := store.CreateAlert(&alerts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alertID := alerts[0].ID
+	flows := []db.FlowRecord{
+		{SrcIP: "192.0.2.7", DstIP: "10.0.0.1", SrcPort: 40000, DstPort: 22, Protocol: 6, StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-2*time.Hour + time.Second), FwdPackets: 3, FwdBytes: 180, Label: "PortScan", Confidence: 0.91, Malicious: true, AlertID: &alertID},
+		{SrcIP: "192.0.2.7", DstIP: "10.0.0.1", SrcPort: 40001, DstPort: 23, Protocol: 6, StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-2 * time.Hour), Label: "PortScan", Confidence: 0.9, Malicious: true, AlertID: &alertID},
+		{SrcIP: "10.0.0.5", DstIP: "8.8.8.8", SrcPort: 5353, DstPort: 53, Protocol: 17, StartTime: now, EndTime: now, Label: "Benign", Confidence: 0.99},
+	}
+	if err := store.CreateFlowRecords(flows); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": FormatCSV, "csv": FormatCSV, "ndjson": FormatNDJSON, "stix": FormatSTIX} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	store := initTestDB(t)
+	seed(t, store)
+
+	var buf bytes.Buffer
+	n, err := Alerts(&buf, FormatCSV, store, db.AlertFilter{Labels: []string{"PortScan"}})
+	if err != nil || n != 2 {
+		t.Fatalf("Alerts() = %d, %v; want 2 alerts", n, err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(alertHeader, ",") {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+	// 按时间从早到晚，载荷中的逗号、引号与换行被正确转义
+	if rows[1][3] != "192.0.2.7" || rows[1][13] != "a,\"b\"\nc" || rows[2][3] != "198.51.100.4" {
+		t.Errorf("unexpected alert rows: %v", rows[1:])
+	}
+
+	// 以公式字符开头的单元格加上 ' 前缀
+	formula := db.Alert{CreatedAt: time.Now(), SourceIP: "203.0.113.1", DestIP: "10.0.0.1", Type: "+Bot", Interface: "@eth0", Payload: "=HYPERLINK(\"http://evil\")"}
+	if err := store.CreateAlert(&formula); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if _, err := Alerts(&buf, FormatCSV, store, db.AlertFilter{Source: "203.0.113.1"}); err != nil {
+		t.Fatal(err)
+	}
+	rows, _ = csv.NewReader(&buf).ReadAll()
+	if len(rows) != 2 || rows[1][5] != "'+Bot" || rows[1][8] != "'@eth0" || rows[1][13] != "'=HYPERLINK(\"http://evil\")" {
+		t.Errorf("formula cells not escaped: %v", rows[1:])
+	}
+	for _, cell := range []string{"-1", "\tcmd", "\rcmd"} {
+		if got := csvText(cell); got != "'"+cell {
+			t.Errorf("csvText(%q) = %q", cell, got)
+		}
+	}
+
+	buf.Reset()
+	if n, err := Flows(&buf, FormatCSV, store, db.FlowFilter{}); err != nil || n != 3 {
+		t.Fatalf("Flows() = %d, %v; want 3 flows", n, err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Errorf("flow CSV has %d lines, want 4", lines)
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	store := initTestDB(t)
+	seed(t, store)
+
+	var buf bytes.Buffer
+	n, err := Flows(&buf, FormatNDJSON, store, db.FlowFilter{Source: "192.0.2.0/24"})
+	if err != nil || n != 2 {
+		t.Fatalf("Flows() = %d, %v; want 2 flows", n, err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r db.FlowRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.SrcIP != "192.0.2.7" || r.AlertID == nil {
+			t.Errorf("unexpected flow: %+v", r)
+		}
+	}
+
+	malicious := false
+	buf.Reset()
+	if n, err := Flows(&buf, FormatNDJSON, store, db.FlowFilter{Malicious: &malicious, Dest: "8.8.8.8"}); err != nil || n != 1 {
+		t.Errorf("Flows(benign) = %d, %v; want 1 flow", n, err)
+	}
+	// 非规范写法的单个地址也能匹配
+	v6 := []db.FlowRecord{{SrcIP: "2001:db8::9", DstIP: "10.0.0.2", Protocol: 6, StartTime: time.Now(), EndTime: time.Now(), Label: "DDoS"}}
+	if err := store.CreateFlowRecords(v6); err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []string{"2001:DB8:0:0::9", "2001:0db8::0009"} {
+		buf.Reset()
+		if n, err := Flows(&buf, FormatNDJSON, store, db.FlowFilter{Source: src}); err != nil || n != 1 {
+			t.Errorf("Flows(src=%s) = %d, %v; want 1 flow", src, n, err)
+		}
+	}
+	buf.Reset()
+	if n, err := Flows(&buf, FormatNDJSON, store, db.FlowFilter{Source: "::ffff:192.0.2.7"}); err != nil || n != 2 {
+		t.Errorf("Flows(IPv4-mapped) = %d, %v; want 2 flows", n, err)
+	}
+	if _, err := Flows(&buf, FormatNDJSON, store, db.FlowFilter{Source: "bad"}); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+// bundle 解析导出的 STIX bundle，检查对象引用都在 bundle 中
+func bundle(t *testing.T, data []byte) map[string][]map[string]interface{} {
+	t.Helper()
+	var b struct {
+		Type    string                   `json:"type"`
+		ID      string                   `json:"id"`
+		Objects []map[string]interface{} `json:"objects"`
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		t.Fatalf("invalid bundle: %v\n%s", err, data)
+	}
+	if b.Type != "bundle" || !strings.HasPrefix(b.ID, "bundle--") {
+		t.Fatalf("unexpected bundle header: %s %s", b.Type, b.ID)
+	}
+	ids := make(map[string]bool)
+	byType := make(map[string][]map[string]interface{})
+	for _, obj := range b.Objects {
+		id := obj["id"].(string)
+		if ids[id] {
+			t.Errorf("duplicate object %s", id)
+		}
+		ids[id] = true
+		byType[obj["type"].(string)] = append(byType[obj["type"].(string)], obj)
+	}
+	for _, obj := range b.Objects {
+		refs := []interface{}{obj["created_by_ref"], obj["src_ref"], obj["dst_ref"]}
+		if list, ok := obj["object_refs"].([]interface{}); ok {
+			refs = append(refs, list...)
+		}
+		for _, ref := range refs {
+			if ref != nil && !ids[ref.(string)] {
+				t.Errorf("%s references %s which is not in the bundle", obj["id"], ref)
+			}
+		}
+	}
+	return byType
+}
+
+func TestExportSTIX(t *testing.T) {
+	store := initTestDB(t)
+	seed(t, store)
+
+	var buf bytes.Buffer
+	if n, err := Alerts(&buf, FormatSTIX, store, db.AlertFilter{}); err != nil || n != 3 {
+		t.Fatalf("Alerts() = %d, %v; want 3 alerts", n, err)
+	}
+	objects := bundle(t, buf.Bytes())
+	indicators := objects["indicator"]
+	if len(objects["identity"]) != 1 || len(indicators) != 3 {
+		t.Fatalf("unexpected objects: %v", objects)
+	}
+	if indicators[0]["pattern"] != "[ipv4-addr:value = '192.0.2.7']" || indicators[0]["confidence"] != float64(91) {
+		t.Errorf("unexpected indicator: %v", indicators[0])
+	}
+	if indicators[1]["pattern"] != "[ipv6-addr:value = '2001:db8::9']" {
+		t.Errorf("unexpected IPv6 indicator: %v", indicators[1])
+	}
+
+	// 重复导出时对象 ID 不变
+	first := indicators[0]["id"]
+	buf.Reset()
+	Alerts(&buf, FormatSTIX, store, db.AlertFilter{Source: "192.0.2.7"})
+	if again := bundle(t, buf.Bytes())["indicator"]; len(again) != 1 || again[0]["id"] != first {
+		t.Errorf("indicator id changed between exports: %v, %v", first, again)
+	}
+
+	buf.Reset()
+	if n, err := Flows(&buf, FormatSTIX, store, db.FlowFilter{}); err != nil || n != 3 {
+		t.Fatalf("Flows() = %d, %v; want 3 flows", n, err)
+	}
+	objects = bundle(t, buf.Bytes())
+	// 地址对象去重: 192.0.2.7, 10.0.0.1, 10.0.0.5, 8.8.8.8
+	if len(objects["ipv4-addr"]) != 4 || len(objects["network-traffic"]) != 3 || len(objects["observed-data"]) != 3 {
+		t.Fatalf("unexpected flow objects: %d addrs, %d traffic, %d observed",
+			len(objects["ipv4-addr"]), len(objects["network-traffic"]), len(objects["observed-data"]))
+	}
+	traffic := objects["network-traffic"][2]
+	if traffic["dst_port"] != float64(53) || traffic["protocols"].([]interface{})[1] != "udp" {
+		t.Errorf("unexpected network-traffic: %v", traffic)
+	}
+}
+
+func TestUUIDv5(t *testing.T) {
+	// 与 Python uuid.uuid5 的结果一致
+	if got := uuidV5(stixNamespace, `{"value":"198.51.100.3"}`); got != "28bb3599-77cd-5a82-a950-b5bc3caf07c4" {
+		t.Errorf("uuidV5 = %s", got)
+	}
+	if id := uuidV4(); len(id) != 36 || id[14] != '4' {
+		t.Errorf("unexpected uuidV4 %s", id)
+	}
+}
@@ -0,0 +1,356 @@
+package export
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"go-ids/internal/db"
+)
+
+// stixTimeLayout STIX 2.1 要求 UTC 时间，精度到毫秒
+const stixTimeLayout = "2006-01-02T15:04:05.000Z"
+
+var (
+	// stixNamespace STIX 2.1 规定的 SCO 确定性 ID 命名空间 (UUIDv5)
+	stixNamespace = mustUUID("00abedb4-aa42-466c-9c01-fed23315a9b7")
+	// idsNamespace go-ids 生成的对象 ID 命名空间，同一条告警或流重复导出时 ID 不变
+	idsNamespace = mustUUID("6a1f4c2e-93d0-4b7e-a5c8-2e1d9b3f7a60")
+)
+
+// stixTime 按 STIX 格式序列化的时间
+type stixTime time.Time
+
+// MarshalJSON 实现 json.Marshaler
+func (t stixTime) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + time.Time(t).UTC().Format(stixTimeLayout) + `"`), nil
+}
+
+// stixIdentity 导出数据的生产者
+type stixIdentity struct {
+	Type          string   `json:"type"`
+	SpecVersion   string   `json:"spec_version"`
+	ID            string   `json:"id"`
+	Created       stixTime `json:"created"`
+	Modified      stixTime `json:"modified"`
+	Name          string   `json:"name"`
+	IdentityClass string   `json:"identity_class"`
+}
+
+// stixExternalReference 指回 go-ids 中的原始记录
+type stixExternalReference struct {
+	SourceName string `json:"source_name"`
+	ExternalID string `json:"external_id"`
+}
+
+// stixIndicator 攻击源地址的指标，每条告警一个
+type stixIndicator struct {
+	Type               string                  `json:"type"`
+	SpecVersion        string                  `json:"spec_version"`
+	ID                 string                  `json:"id"`
+	CreatedByRef       string                  `json:"created_by_ref"`
+	Created            stixTime                `json:"created"`
+	Modified           stixTime                `json:"modified"`
+	Name               string                  `json:"name"`
+	Description        string                  `json:"description"`
+	IndicatorTypes     []string                `json:"indicator_types"`
+	Pattern            string                  `json:"pattern"`
+	PatternType        string                  `json:"pattern_type"`
+	ValidFrom          stixTime                `json:"valid_from"`
+	Labels             []string                `json:"labels,omitempty"`
+	Confidence         int                     `json:"confidence"` // 0-100
+	ExternalReferences []stixExternalReference `json:"external_references"`
+}
+
+// stixAddr ipv4-addr / ipv6-addr 对象
+type stixAddr struct {
+	Type        string `json:"type"`
+	SpecVersion string `json:"spec_version"`
+	ID          string `json:"id"`
+	Value       string `json:"value"`
+}
+
+// stixNetworkTraffic 一条流的五元组与统计
+type stixNetworkTraffic struct {
+	Type         string   `json:"type"`
+	SpecVersion  string   `json:"spec_version"`
+	ID           string   `json:"id"`
+	Start        stixTime `json:"start"`
+	End          stixTime `json:"end"`
+	SrcRef       string   `json:"src_ref"`
+	DstRef       string   `json:"dst_ref"`
+	SrcPort      uint16   `json:"src_port,omitempty"` // ICMP 等没有端口的协议不填
+	DstPort      uint16   `json:"dst_port,omitempty"`
+	Protocols    []string `json:"protocols"`
+	SrcByteCount uint64   `json:"src_byte_count"`
+	DstByteCount uint64   `json:"dst_byte_count"`
+	SrcPackets   uint64   `json:"src_packets"`
+	DstPackets   uint64   `json:"dst_packets"`
+}
+
+// stixObservedData 观测到的流，引用 network-traffic 与两端地址
+type stixObservedData struct {
+	Type               string                  `json:"type"`
+	SpecVersion        string                  `json:"spec_version"`
+	ID                 string                  `json:"id"`
+	CreatedByRef       string                  `json:"created_by_ref"`
+	Created            stixTime                `json:"created"`
+	Modified           stixTime                `json:"modified"`
+	FirstObserved      stixTime                `json:"first_observed"`
+	LastObserved       stixTime                `json:"last_observed"`
+	NumberObserved     int                     `json:"number_observed"`
+	ObjectRefs         []string                `json:"object_refs"`
+	Labels             []string                `json:"labels,omitempty"` // 模型预测的类别
+	Confidence         int                     `json:"confidence"`
+	ExternalReferences []stixExternalReference `json:"external_references"`
+}
+
+// stixWriter 流式写出一个 STIX 2.1 bundle
+// bundle 的开头在创建时写出，对象逐个追加，close 写出结尾
+type stixWriter struct {
+	w        io.Writer
+	identity string
+	written  int
+	seen     map[string]bool // 已写出的地址对象，同一地址在 bundle 中只出现一次
+	err      error
+}
+
+func newSTIXWriter(w io.Writer, now time.Time) *stixWriter {
+	s := &stixWriter{w: w, seen: make(map[string]bool)}
+	s.identity = "identity--" + uuidV5(idsNamespace, "identity:go-ids")
+	_, s.err = fmt.Fprintf(w, `{"type":"bundle","id":"bundle--%s","objects":[`, uuidV4())
+	s.write(stixIdentity{
+		Type:          "identity",
+		SpecVersion:   "2.1",
+		ID:            s.identity,
+		Created:       stixTime(now),
+		Modified:      stixTime(now),
+		Name:          "go-ids",
+		IdentityClass: "system",
+	})
+	return s
+}
+
+// write 追加一个对象，出错后不再写入
+func (s *stixWriter) write(obj interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		s.err = err
+		return err
+	}
+	sep := ",\n"
+	if s.written == 0 {
+		sep = "\n"
+	}
+	if _, err := io.WriteString(s.w, sep); err != nil {
+		s.err = err
+		return err
+	}
+	if _, err := s.w.Write(b); err != nil {
+		s.err = err
+		return err
+	}
+	s.written++
+	return nil
+}
+
+func (s *stixWriter) close() error {
+	if s.err != nil {
+		return s.err
+	}
+	_, s.err = io.WriteString(s.w, "\n]}\n")
+	return s.err
+}
+
+// writeAlert 写出告警攻击源地址的指标
+// 源地址不是 IP 的告警 (如旧版本写入的数据) 无法表示为指标，跳过
+func (s *stixWriter) writeAlert(a db.Alert) error {
+	addr, err := netip.ParseAddr(a.SourceIP)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+	modified := a.LastSeen
+	if modified.Before(a.CreatedAt) {
+		modified = a.CreatedAt
+	}
+	return s.write(stixIndicator{
+		Type:           "indicator",
+		SpecVersion:    "2.1",
+		ID:             "indicator--" + uuidV5(idsNamespace, "alert:"+strconv.FormatUint(uint64(a.ID), 10)),
+		CreatedByRef:   s.identity,
+		Created:        stixTime(a.CreatedAt),
+		Modified:       stixTime(modified),
+		Name:           fmt.Sprintf("%s from %s", a.Type, addr),
+		Description:    fmt.Sprintf("%s traffic from %s to %s detected %d time(s)", a.Type, addr, a.DestIP, a.Count),
+		IndicatorTypes: []string{"malicious-activity"},
+		Pattern:        fmt.Sprintf("[%s:value = '%s']", addrType(addr), addr),
+		PatternType:    "stix",
+		ValidFrom:      stixTime(a.CreatedAt),
+		Labels:         []string{a.Type},
+		Confidence:     stixConfidence(a.Confidence),
+		ExternalReferences: []stixExternalReference{
+			{SourceName: "go-ids", ExternalID: "alert-" + strconv.FormatUint(uint64(a.ID), 10)},
+		},
+	})
+}
+
+// writeFlow 写出流对应的地址、network-traffic 与 observed-data 对象
+func (s *stixWriter) writeFlow(r db.FlowRecord) error {
+	src, err1 := netip.ParseAddr(r.SrcIP)
+	dst, err2 := netip.ParseAddr(r.DstIP)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	srcRef, err := s.writeAddr(src.Unmap())
+	if err != nil {
+		return err
+	}
+	dstRef, err := s.writeAddr(dst.Unmap())
+	if err != nil {
+		return err
+	}
+
+	end := r.EndTime
+	if end.Before(r.StartTime) {
+		end = r.StartTime
+	}
+	name := "flow:" + strconv.FormatUint(uint64(r.ID), 10)
+	traffic := stixNetworkTraffic{
+		Type:         "network-traffic",
+		SpecVersion:  "2.1",
+		ID:           "network-traffic--" + uuidV5(idsNamespace, name),
+		Start:        stixTime(r.StartTime),
+		End:          stixTime(end),
+		SrcRef:       srcRef,
+		DstRef:       dstRef,
+		Protocols:    protocols(src.Unmap(), r.Protocol),
+		SrcByteCount: r.FwdBytes,
+		DstByteCount: r.BwdBytes,
+		SrcPackets:   r.FwdPackets,
+		DstPackets:   r.BwdPackets,
+	}
+	if r.Protocol == 6 || r.Protocol == 17 || r.Protocol == 132 {
+		traffic.SrcPort, traffic.DstPort = r.SrcPort, r.DstPort
+	}
+	if err := s.write(traffic); err != nil {
+		return err
+	}
+
+	observed := stixObservedData{
+		Type:           "observed-data",
+		SpecVersion:    "2.1",
+		ID:             "observed-data--" + uuidV5(idsNamespace, name),
+		CreatedByRef:   s.identity,
+		Created:        stixTime(r.CreatedAt),
+		Modified:       stixTime(r.CreatedAt),
+		FirstObserved:  stixTime(r.StartTime),
+		LastObserved:   stixTime(end),
+		NumberObserved: 1,
+		ObjectRefs:     []string{traffic.ID, srcRef, dstRef},
+		Confidence:     stixConfidence(r.Confidence),
+		ExternalReferences: []stixExternalReference{
+			{SourceName: "go-ids", ExternalID: "flow-" + strconv.FormatUint(uint64(r.ID), 10)},
+		},
+	}
+	if r.Label != "" {
+		observed.Labels = []string{r.Label}
+	}
+	return s.write(observed)
+}
+
+// writeAddr 写出地址对象 (每个地址只写一次) 并返回其 ID
+func (s *stixWriter) writeAddr(addr netip.Addr) (string, error) {
+	typ := addrType(addr)
+	// SCO 的 ID 由 ID 相关属性的规范化 JSON 计算，其他工具导出的同一地址得到相同的 ID
+	id := typ + "--" + uuidV5(stixNamespace, `{"value":"`+addr.String()+`"}`)
+	if s.seen[id] {
+		return id, nil
+	}
+	s.seen[id] = true
+	return id, s.write(stixAddr{Type: typ, SpecVersion: "2.1", ID: id, Value: addr.String()})
+}
+
+func addrType(addr netip.Addr) string {
+	if addr.Is4() {
+		return "ipv4-addr"
+	}
+	return "ipv6-addr"
+}
+
+// protocols 返回 network-traffic 的协议栈，从网络层到传输层
+func protocols(addr netip.Addr, proto uint8) []string {
+	network := "ipv6"
+	if addr.Is4() {
+		network = "ipv4"
+	}
+	switch proto {
+	case 1:
+		return []string{network, "icmp"}
+	case 6:
+		return []string{network, "tcp"}
+	case 17:
+		return []string{network, "udp"}
+	case 58:
+		return []string{network, "ipv6-icmp"}
+	case 132:
+		return []string{network, "sctp"}
+	}
+	return []string{network}
+}
+
+// stixConfidence 将 0-1 的置信度换算为 STIX 的 0-100
+func stixConfidence(c float32) int {
+	switch {
+	case c <= 0:
+		return 0
+	case c >= 1:
+		return 100
+	}
+	return int(c*100 + 0.5)
+}
+
+// uuidV5 按 RFC 4122 计算基于 SHA-1 的 UUID
+func uuidV5(namespace [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+// uuidV4 生成随机 UUID
+func uuidV4() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+func formatUUID(u [16]byte) string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+func mustUUID(s string) [16]byte {
+	var u [16]byte
+	b, err := hex.DecodeString(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if err != nil || len(b) != 16 {
+		panic("invalid uuid " + s)
+	}
+	copy(u[:], b)
+	return u
+}
@@ -2,6 +2,8 @@ package flow
 
 import (
 	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/gopacket"
@@ -12,6 +14,7 @@ import (
 // 这些统计信息最终将被转换为 78 个输入特征
 type Flow struct {
 	Key       FlowKey
+	Interface string // 首个数据包的入口接口
 	StartTime time.Time
 	LastTime  time.Time
 
@@ -84,6 +87,10 @@ type Flow struct {
 	FwdActDataPkts  uint32
 	FwdMinSegSize   uint32
 
+	// TCP 序列号跟踪，用于向流两端注入 RST
+	FwdTCP TCPSeq
+	BwdTCP TCPSeq
+
 	// Active/Idle 统计 (简化实现)
 	ActiveSum   float64
 	ActiveMax   float64
@@ -99,6 +106,14 @@ type Flow struct {
 	// 攻击审计: 缓存前 N 个包的应用层 Payload
 	RawPayload []byte
 	pktCount   int
+
+	// mu 保护统计字段，检测协程在流仍然活跃时通过 Snapshot 读取
+	// 使用指针以便 Snapshot 可以按值复制整个结构
+	mu *sync.Mutex
+	// checkpointAt 上一次活跃超时检查点的时间
+	checkpointAt time.Time
+	// malicious 非零表示流已在检查点被判定为恶意 (原子访问)
+	malicious uint32
 }
 
 // NewFlow 初始化一个新的流
@@ -123,13 +138,80 @@ func NewFlow(key FlowKey, pkt gopacket.Packet) *Flow {
 		IdleMin:      1e9,
 
 		lastFlowPktTime: now,
+		mu:              new(sync.Mutex),
 	}
 
 	return f
 }
 
+// Snapshot 返回流统计的一致副本，可以在流继续更新时用于特征提取
+func (f *Flow) Snapshot() *Flow {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.snapshotLocked()
+}
+
+func (f *Flow) snapshotLocked() *Flow {
+	s := *f
+	s.RawPayload = append([]byte(nil), f.RawPayload...)
+	s.mu = new(sync.Mutex)
+	return &s
+}
+
+// checkpoint 在流自上个检查点 (或开始) 起活跃超过 activeTimeout 时返回快照
+func (f *Flow) checkpoint(now time.Time, activeTimeout time.Duration) *Flow {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	since := f.checkpointAt
+	if since.IsZero() {
+		since = f.StartTime
+	}
+	if now.Sub(since) < activeTimeout {
+		return nil
+	}
+	f.checkpointAt = now
+	return f.snapshotLocked()
+}
+
+// SetInterface 记录流的入口接口，只有首次设置生效
+func (f *Flow) SetInterface(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Interface == "" {
+		f.Interface = name
+	}
+}
+
+// iface 返回流的入口接口
+func (f *Flow) iface() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Interface
+}
+
+// MarkMalicious 将流标记为恶意，内联模式下该流后续的数据包会被丢弃
+func (f *Flow) MarkMalicious() {
+	atomic.StoreUint32(&f.malicious, 1)
+}
+
+// IsMalicious 报告流是否已被判定为恶意
+func (f *Flow) IsMalicious() bool {
+	return atomic.LoadUint32(&f.malicious) != 0
+}
+
+// lastSeen 返回最后一个数据包的时间
+func (f *Flow) lastSeen() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.LastTime
+}
+
 // Update 根据新到达的数据包更新流状态
 func (f *Flow) Update(pkt gopacket.Packet, isForward bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	now := pkt.Metadata().Timestamp
 	if now.IsZero() {
 		now = time.Now()
@@ -180,6 +262,11 @@ func (f *Flow) Update(pkt gopacket.Packet, isForward bool) {
 	if tcpLayer := pkt.Layer(layers.LayerTypeTCP); tcpLayer != nil {
 		tcp = tcpLayer.(*layers.TCP)
 		f.updateTCPFlags(tcp)
+		if isForward {
+			f.FwdTCP.update(tcp)
+		} else {
+			f.BwdTCP.update(tcp)
+		}
 		if isForward && f.FwdPackets == 0 {
 			f.InitWinBytesFwd = uint32(tcp.Window)
 		} else if !isForward && f.BwdPackets == 0 {
@@ -301,6 +388,42 @@ func (f *Flow) updateTCPFlags(tcp *layers.TCP) {
 	} // gopacket 中是 CWR
 }
 
+// TCPSeq 记录 TCP 流一个方向上最近观测到的序列号状态
+type TCPSeq struct {
+	NextSeq  uint32 // 该方向下一个字节的序列号 (seq + 载荷长度 + SYN/FIN)
+	Ack      uint32 // 该方向最近的确认号，即对端下一个字节的序列号
+	SeqValid bool
+	AckValid bool
+}
+
+// update 按数据包推进序列号，乱序与重传的旧报文不会使状态回退
+func (s *TCPSeq) update(tcp *layers.TCP) {
+	// RST 不占用序列号空间，也可能是伪造的
+	if tcp.RST {
+		return
+	}
+	next := tcp.Seq + uint32(len(tcp.Payload))
+	if tcp.SYN {
+		next++
+	}
+	if tcp.FIN {
+		next++
+	}
+	if !s.SeqValid || seqAfter(next, s.NextSeq) {
+		s.NextSeq = next
+		s.SeqValid = true
+	}
+	if tcp.ACK && (!s.AckValid || seqAfter(tcp.Ack, s.Ack)) {
+		s.Ack = tcp.Ack
+		s.AckValid = true
+	}
+}
+
+// seqAfter 按 RFC 1982 序列号算术判断 a 是否在 b 之后
+func seqAfter(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
 // GetMean 返回平均值
 func GetMean(sum float64, count uint64) float64 {
 	if count == 0 {
@@ -0,0 +1,75 @@
+package flow
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// tcpPacket 构造一个带载荷的 TCP 数据包
+func tcpPacket(t *testing.T, src, dst string, sport, dport uint16, tcp layers.TCP, payload []byte) gopacket.Packet {
+	t.Helper()
+	ip := layers.IPv4{
+		Version:  4,
+		IHL:      5,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.ParseIP(src),
+		DstIP:    net.ParseIP(dst),
+	}
+	tcp.SrcPort = layers.TCPPort(sport)
+	tcp.DstPort = layers.TCPPort(dport)
+	tcp.SetNetworkLayerForChecksum(&ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, &ip, &tcp, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+	pkt := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	pkt.Metadata().Timestamp = time.Now()
+	pkt.Metadata().Length = len(buf.Bytes())
+	return pkt
+}
+
+func TestFlowTCPSeq(t *testing.T) {
+	const client, server = "192.0.2.10", "198.51.100.20"
+	mgr := NewManager(time.Minute)
+	feed := func(src, dst string, sport, dport uint16, tcp layers.TCP, payload []byte) {
+		key := FlowKey{SrcIP: src, DstIP: dst, SrcPort: sport, DstPort: dport, Proto: layers.IPProtocolTCP}
+		pkt := tcpPacket(t, src, dst, sport, dport, tcp, payload)
+		f, fwd := mgr.GetOrCreate(key, pkt)
+		f.Update(pkt, fwd)
+	}
+	key := FlowKey{SrcIP: client, DstIP: server, SrcPort: 40000, DstPort: 80, Proto: layers.IPProtocolTCP}
+
+	// 握手: SYN 占用一个序列号
+	feed(client, server, 40000, 80, layers.TCP{SYN: true, Seq: 1000}, nil)
+	f := mgr.Snapshot(key)
+	if !f.FwdTCP.SeqValid || f.FwdTCP.NextSeq != 1001 || f.FwdTCP.AckValid || f.BwdTCP.SeqValid {
+		t.Fatalf("after SYN: fwd=%+v bwd=%+v", f.FwdTCP, f.BwdTCP)
+	}
+	feed(server, client, 80, 40000, layers.TCP{SYN: true, ACK: true, Seq: 0xfffffff0, Ack: 1001}, nil)
+	feed(client, server, 40000, 80, layers.TCP{ACK: true, PSH: true, Seq: 1001, Ack: 0xfffffff1}, []byte("GET / HTTP/1.0\r\n\r\n"))
+	// 服务端的响应跨越序列号回绕
+	feed(server, client, 80, 40000, layers.TCP{ACK: true, Seq: 0xfffffff1, Ack: 1019}, make([]byte, 100))
+	feed(client, server, 40000, 80, layers.TCP{ACK: true, Seq: 1019, Ack: 0x55}, nil)
+	// 重传的旧报文与 RST 不使状态回退
+	feed(client, server, 40000, 80, layers.TCP{ACK: true, PSH: true, Seq: 1001, Ack: 0xfffffff1}, []byte("GET"))
+	feed(server, client, 80, 40000, layers.TCP{RST: true, Seq: 7}, nil)
+
+	f = mgr.Snapshot(key)
+	if f.FwdTCP != (TCPSeq{NextSeq: 1019, Ack: 0x55, SeqValid: true, AckValid: true}) {
+		t.Errorf("fwd: %+v", f.FwdTCP)
+	}
+	if f.BwdTCP != (TCPSeq{NextSeq: 0x55, Ack: 1019, SeqValid: true, AckValid: true}) {
+		t.Errorf("bwd: %+v", f.BwdTCP)
+	}
+
+	if mgr.Snapshot(key.Reverse()) != nil {
+		t.Error("Snapshot should only match the stored key")
+	}
+}
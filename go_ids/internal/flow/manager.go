@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	"go-ids/internal/metrics"
 	"go-ids/internal/server"
 
 	"github.com/google/gopacket"
@@ -44,6 +45,7 @@ func (m *Manager) GetOrCreate(key FlowKey, pkt gopacket.Packet) (*Flow, bool) {
 	// 3. 都不存在，创建新流（默认为正向）
 	f := NewFlow(key, pkt)
 	m.flows[key] = f
+	metrics.Pipeline.FlowsCreated.Add(1)
 	return f, true
 }
 
@@ -57,15 +59,62 @@ func (m *Manager) Cleanup() []*Flow {
 	var expired []*Flow
 
 	for key, f := range m.flows {
-		if now.Sub(f.LastTime) > m.timeout {
+		if now.Sub(f.lastSeen()) > m.timeout {
 			expired = append(expired, f)
 			delete(m.flows, key)
 		}
 	}
+	metrics.Pipeline.FlowsExpired.Add(uint64(len(expired)))
 
 	return expired
 }
 
+// Checkpoint 返回持续活跃超过 activeTimeout 的流的快照，用于在长连接结束前提前检测
+// 每条流每隔 activeTimeout 最多产生一次快照，已被判定为恶意的流不再重复检测
+func (m *Manager) Checkpoint(activeTimeout time.Duration) []*Flow {
+	if activeTimeout <= 0 {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var snapshots []*Flow
+	for _, f := range m.flows {
+		if f.IsMalicious() {
+			continue
+		}
+		if s := f.checkpoint(now, activeTimeout); s != nil {
+			snapshots = append(snapshots, s)
+		}
+	}
+	return snapshots
+}
+
+// MarkMalicious 将仍然活跃的流标记为恶意，流已过期时返回 false
+func (m *Manager) MarkMalicious(key FlowKey) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if f, ok := m.flows[key]; ok {
+		f.MarkMalicious()
+		return true
+	}
+	return false
+}
+
+// Snapshot 返回仍然活跃的流的最新快照，流已过期时返回 nil
+func (m *Manager) Snapshot(key FlowKey) *Flow {
+	m.mu.RLock()
+	f, ok := m.flows[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return f.Snapshot()
+}
+
 // Count 返回当前管理的流数量
 func (m *Manager) Count() int {
 	m.mu.RLock()
@@ -102,10 +151,11 @@ func (m *Manager) GetRecentFlows(limit int) []server.FlowBrief {
 		}
 
 		flows = append(flows, server.FlowBrief{
-			SrcPort:  f.Key.SrcPort,
-			DstPort:  f.Key.DstPort,
-			Protocol: proto,
-			Duration: dur,
+			SrcPort:   f.Key.SrcPort,
+			DstPort:   f.Key.DstPort,
+			Protocol:  proto,
+			Duration:  dur,
+			Interface: f.iface(),
 		})
 		count++
 	}
@@ -107,3 +107,67 @@ func TestManager_Cleanup(t *testing.T) {
 		t.Errorf("Expected 0 active flows, got %d", mgr.Count())
 	}
 }
+
+func TestManager_Checkpoint(t *testing.T) {
+	mgr := NewManager(time.Minute)
+
+	key, pkt := createKeyAndPacket(t, "10.0.0.1", "10.0.0.2", 1000, 2000)
+	f, isFwd := mgr.GetOrCreate(key, pkt)
+	f.Update(pkt, isFwd)
+
+	// 尚未达到活跃超时
+	if snaps := mgr.Checkpoint(time.Hour); len(snaps) != 0 {
+		t.Fatalf("Expected no checkpoint, got %d", len(snaps))
+	}
+
+	f.StartTime = time.Now().Add(-2 * time.Second)
+	snaps := mgr.Checkpoint(time.Second)
+	if len(snaps) != 1 {
+		t.Fatalf("Expected 1 checkpoint snapshot, got %d", len(snaps))
+	}
+	if snaps[0] == f || snaps[0].FwdPackets != 1 || snaps[0].Key != key {
+		t.Errorf("Unexpected snapshot: %+v", snaps[0])
+	}
+
+	// 快照与原流相互独立
+	f.Update(pkt, true)
+	if snaps[0].FwdPackets != 1 {
+		t.Error("Snapshot changed after flow update")
+	}
+
+	// 同一活跃周期内不重复产生快照
+	if snaps := mgr.Checkpoint(time.Second); len(snaps) != 0 {
+		t.Errorf("Expected no repeated checkpoint, got %d", len(snaps))
+	}
+
+	if !mgr.MarkMalicious(key) || !f.IsMalicious() {
+		t.Error("Expected flow to be marked malicious")
+	}
+	f.checkpointAt = time.Now().Add(-2 * time.Second)
+	if snaps := mgr.Checkpoint(time.Second); len(snaps) != 0 {
+		t.Error("Malicious flow should not be checkpointed again")
+	}
+	if mgr.MarkMalicious(key.Reverse()) {
+		t.Error("MarkMalicious should fail for unknown key")
+	}
+}
+
+func TestManager_RecentFlowsInterface(t *testing.T) {
+	mgr := NewManager(time.Minute)
+	key, pkt := createKeyAndPacket(t, "192.168.1.1", "192.168.1.2", 12345, 80)
+	f, _ := mgr.GetOrCreate(key, pkt)
+
+	// 抓包协程设置入口接口时，仪表盘可能同时在读取
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.SetInterface("eth0")
+	}()
+	mgr.GetRecentFlows(10)
+	<-done
+
+	flows := mgr.GetRecentFlows(10)
+	if len(flows) != 1 || flows[0].Interface != "eth0" {
+		t.Errorf("unexpected recent flows: %+v", flows)
+	}
+}
@@ -0,0 +1,87 @@
+package flowrecord
+
+import (
+	"math/rand"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/flow"
+	"go-ids/internal/inference"
+	"go-ids/internal/loader"
+)
+
+// Recorder 把检测过的流保存到 flow_records 表
+// 产生告警的流总是保存，良性流按配置全部保存、抽样保存或不保存，以控制数据库大小。
+// 记录先缓存在内存中，由检测协程在每轮检测后调用 Flush 批量写入，不是并发安全的
+type Recorder struct {
+	store   db.FlowRecordRepository
+	cfg     loader.FlowRecordsConfig
+	random  func() float64 // 抽样用随机数，测试中可替换
+	pending []db.FlowRecord
+}
+
+// NewRecorder 按配置创建记录器
+func NewRecorder(cfg loader.FlowRecordsConfig, store db.FlowRecordRepository) *Recorder {
+	return &Recorder{store: store, cfg: cfg, random: rand.Float64}
+}
+
+// Record 缓存一条流记录，返回该流是否被保存
+// pred 为 nil 表示推理失败，alertID 为 0 表示流没有记入告警
+func (r *Recorder) Record(f *flow.Flow, features []float32, pred *inference.Prediction, malicious bool, alertID uint) bool {
+	if !malicious && !r.keepBenign() {
+		return false
+	}
+
+	rec := db.FlowRecord{
+		CreatedAt:  time.Now(),
+		SrcIP:      f.Key.SrcIP,
+		DstIP:      f.Key.DstIP,
+		SrcPort:    f.Key.SrcPort,
+		DstPort:    f.Key.DstPort,
+		Protocol:   uint8(f.Key.Proto),
+		Interface:  f.Interface,
+		StartTime:  f.StartTime,
+		EndTime:    f.LastTime,
+		FwdPackets: f.FwdPackets,
+		BwdPackets: f.BwdPackets,
+		FwdBytes:   f.FwdBytes,
+		BwdBytes:   f.BwdBytes,
+		Features:   features,
+		Malicious:  malicious,
+	}
+	if pred != nil {
+		rec.Label = pred.Label
+		rec.Confidence = pred.Probability
+	}
+	if alertID != 0 {
+		rec.AlertID = &alertID
+	}
+	r.pending = append(r.pending, rec)
+	return true
+}
+
+// keepBenign 按存储策略决定是否保存一条良性流
+func (r *Recorder) keepBenign() bool {
+	switch r.cfg.Benign {
+	case loader.FlowRecordsAll:
+		return true
+	case loader.FlowRecordsSample:
+		return r.random() < r.cfg.SampleRate
+	}
+	return false
+}
+
+// Pending 返回尚未写入数据库的记录数
+func (r *Recorder) Pending() int {
+	return len(r.pending)
+}
+
+// Flush 把缓存的记录写入数据库，失败时丢弃这一批记录以免内存无限增长
+func (r *Recorder) Flush() error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	err := r.store.CreateFlowRecords(r.pending)
+	r.pending = r.pending[:0]
+	return err
+}
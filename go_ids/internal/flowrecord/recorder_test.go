@@ -0,0 +1,95 @@
+package flowrecord
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/flow"
+	"go-ids/internal/inference"
+	"go-ids/internal/loader"
+
+	"github.com/google/gopacket/layers"
+)
+
+func testFlow(src string) *flow.Flow {
+	start := time.Now().Add(-time.Minute)
+	return &flow.Flow{
+		Key:        flow.FlowKey{SrcIP: src, DstIP: "10.0.0.1", SrcPort: 40000, DstPort: 443, Proto: layers.IPProtocolTCP},
+		Interface:  "eth0",
+		StartTime:  start,
+		LastTime:   start.Add(30 * time.Second),
+		FwdPackets: 12,
+		BwdPackets: 10,
+		FwdBytes:   1500,
+		BwdBytes:   64000,
+	}
+}
+
+func TestRecorderSampling(t *testing.T) {
+	benign := &inference.Prediction{Label: "Benign", Probability: 0.99}
+	cases := []struct {
+		cfg  loader.FlowRecordsConfig
+		draw float64
+		want bool
+	}{
+		{loader.FlowRecordsConfig{}, 0, false},
+		{loader.FlowRecordsConfig{Benign: loader.FlowRecordsNone}, 0, false},
+		{loader.FlowRecordsConfig{Benign: loader.FlowRecordsAll}, 0.99, true},
+		{loader.FlowRecordsConfig{Benign: loader.FlowRecordsSample, SampleRate: 0.1}, 0.05, true},
+		{loader.FlowRecordsConfig{Benign: loader.FlowRecordsSample, SampleRate: 0.1}, 0.5, false},
+		{loader.FlowRecordsConfig{Benign: loader.FlowRecordsSample, SampleRate: 0}, 0, false},
+	}
+	for _, tc := range cases {
+		r := NewRecorder(tc.cfg, nil)
+		r.random = func() float64 { return tc.draw }
+		if got := r.Record(testFlow("192.0.2.1"), nil, benign, false, 0); got != tc.want {
+			t.Errorf("%+v draw=%v: kept=%v, want %v", tc.cfg, tc.draw, got, tc.want)
+		}
+		// 恶意流总是保存
+		if !r.Record(testFlow("192.0.2.1"), nil, &inference.Prediction{Label: "DDoS"}, true, 0) {
+			t.Errorf("%+v: malicious flow not kept", tc.cfg)
+		}
+	}
+}
+
+func TestRecorderFlush(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	alert := db.Alert{SourceIP: "192.0.2.7", DestIP: "10.0.0.1", Type: "Web Attack", Confidence: 0.97}
+	if err := store.CreateAlert(&alert); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRecorder(loader.FlowRecordsConfig{Benign: loader.FlowRecordsAll}, store)
+	features := make([]float32, 78)
+	features[0] = 443
+	r.Record(testFlow("192.0.2.7"), features, &inference.Prediction{Label: "Web Attack", Probability: 0.97}, true, alert.ID)
+	r.Record(testFlow("192.0.2.8"), features, nil, false, 0)
+	if r.Pending() != 2 {
+		t.Fatalf("expected 2 pending records, got %d", r.Pending())
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Pending() != 0 {
+		t.Error("Flush should clear pending records")
+	}
+
+	records, err := store.GetAlertFlows(alert.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 linked flow, got %d", len(records))
+	}
+	rec := records[0]
+	if rec.SrcIP != "192.0.2.7" || rec.DstPort != 443 || rec.Protocol != 6 || rec.BwdBytes != 64000 ||
+		rec.Label != "Web Attack" || !rec.Malicious || len(rec.Features) != 78 || rec.Features[0] != 443 ||
+		rec.EndTime.Sub(rec.StartTime) != 30*time.Second {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+}
@@ -0,0 +1,225 @@
+package incident
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+	"go-ids/internal/server"
+)
+
+// labelStages 攻击类型在杀伤链中的阶段: 侦察 -> 入侵尝试 -> 利用/破坏 -> 受控
+var labelStages = map[string]int{
+	"PortScan":    1,
+	"Brute Force": 2,
+	"Web Attack":  3,
+	"DoS":         3,
+	"Bot":         4,
+}
+
+// labelSeverity 单个攻击类型的基础严重程度，未知类型按 medium 处理
+var labelSeverity = map[string]string{
+	"PortScan":    db.SeverityLow,
+	"Brute Force": db.SeverityMedium,
+	"Web Attack":  db.SeverityHigh,
+	"DoS":         db.SeverityHigh,
+	"Bot":         db.SeverityCritical,
+}
+
+// severities 严重程度从低到高
+var severities = []string{db.SeverityLow, db.SeverityMedium, db.SeverityHigh, db.SeverityCritical}
+
+// wideScope 攻击源或受害主机达到该数量时提升一级严重程度
+const wideScope = 10
+
+// Correlator 将告警按攻击源、受害主机与时间邻近程度归并为事件 (Incident)
+type Correlator struct {
+	store  db.IncidentRepository
+	window time.Duration
+	mu     sync.Mutex
+}
+
+// NewCorrelator 创建告警关联器
+func NewCorrelator(cfg loader.IncidentConfig, store db.IncidentRepository) *Correlator {
+	return &Correlator{store: store, window: time.Duration(cfg.Window) * time.Second}
+}
+
+// Correlate 将告警归入活跃事件，没有匹配的事件时新建一个
+// 匹配规则: 同一攻击源的事件优先；其次是同一受害主机上的同类攻击 (分布式攻击)
+func (c *Correlator) Correlate(alert db.Alert) (*db.Incident, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 事件、告警关联与时间线在同一事务中写入，中途失败时不会留下缺少时间线的事件或未关联的告警
+	var incident *db.Incident
+	err := c.store.Transaction(func(tx db.IncidentRepository) error {
+		var err error
+		incident, err = c.correlate(tx, alert)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	server.Manager.Publish(server.EventIncident, *incident)
+	return incident, nil
+}
+
+// correlate 在事务 tx 中更新或新建告警所属的事件并写入时间线
+func (c *Correlator) correlate(tx db.IncidentRepository, alert db.Alert) (*db.Incident, error) {
+	now := alert.CreatedAt
+	active, err := tx.ListActiveIncidents(now.Add(-c.window))
+	if err != nil {
+		return nil, err
+	}
+
+	incident := match(active, alert)
+	created := incident == nil
+	if created {
+		incident = &db.Incident{
+			Status:     db.IncidentOpen,
+			AttackerIP: alert.SourceIP,
+			FirstSeen:  now,
+		}
+	}
+
+	previous := incident.Severity
+	incident.AlertCount++
+	if now.After(incident.LastSeen) {
+		incident.LastSeen = now
+	}
+	incident.Attackers = appendUnique(incident.Attackers, alert.SourceIP)
+	incident.Victims = appendUnique(incident.Victims, alert.DestIP)
+	incident.Labels = appendUnique(incident.Labels, alert.Type)
+	incident.Severity = maxSeverity(previous, severity(incident))
+	incident.Title = title(incident)
+
+	if created {
+		err = tx.CreateIncident(incident)
+	} else {
+		err = tx.SaveIncident(incident)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.SetAlertIncident(alert.ID, incident.ID); err != nil {
+		return nil, err
+	}
+
+	alertID := alert.ID
+	detail := fmt.Sprintf("%s %s -> %s (%.2f)", alert.Type, alert.SourceIP, alert.DestIP, alert.Confidence)
+	events := []db.IncidentEvent{{Kind: db.IncidentEventAlert, AlertID: &alertID, Detail: detail}}
+	if created {
+		events[0].Kind = db.IncidentEventCreated
+	} else if incident.Severity != previous {
+		events = append(events, db.IncidentEvent{
+			Kind:   db.IncidentEventSeverity,
+			Detail: previous + " -> " + incident.Severity,
+		})
+	}
+	for _, event := range events {
+		event.IncidentID = incident.ID
+		event.CreatedAt = now
+		event.Actor = "system"
+		if err := tx.AddIncidentEvent(&event); err != nil {
+			return nil, err
+		}
+	}
+	return incident, nil
+}
+
+// match 在活跃事件中查找告警所属的事件，active 按最后活动时间倒序
+func match(active []db.Incident, alert db.Alert) *db.Incident {
+	for i := range active {
+		if contains(active[i].Attackers, alert.SourceIP) {
+			return &active[i]
+		}
+	}
+	for i := range active {
+		if contains(active[i].Victims, alert.DestIP) && contains(active[i].Labels, alert.Type) {
+			return &active[i]
+		}
+	}
+	return nil
+}
+
+// severity 根据攻击类型、杀伤链推进程度与影响范围计算严重程度
+func severity(incident *db.Incident) string {
+	level := 0
+	for _, label := range incident.Labels {
+		base, ok := labelSeverity[label]
+		if !ok {
+			base = db.SeverityMedium
+		}
+		level = max(level, rank(base))
+	}
+
+	// 按首次出现顺序统计逐级推进的阶段数，如 PortScan -> Brute Force -> Web Attack 为 3
+	steps, stage := 0, 0
+	for _, label := range incident.Labels {
+		if s := labelStages[label]; s > stage {
+			steps++
+			stage = s
+		}
+	}
+	switch {
+	case steps >= 3:
+		level = rank(db.SeverityCritical)
+	case steps == 2:
+		level++
+	}
+
+	if len(incident.Attackers) >= wideScope || len(incident.Victims) >= wideScope {
+		level++
+	}
+	return severities[min(level, len(severities)-1)]
+}
+
+// title 生成事件标题，如 "PortScan → Brute Force from 192.0.2.1 against 10.0.0.5"
+func title(incident *db.Incident) string {
+	source := incident.AttackerIP
+	if n := len(incident.Attackers); n > 1 {
+		source = fmt.Sprintf("%s and %d others", incident.AttackerIP, n-1)
+	}
+	target := incident.Victims[0]
+	if n := len(incident.Victims); n > 1 {
+		target = fmt.Sprintf("%d hosts", n)
+	}
+	return fmt.Sprintf("%s from %s against %s", strings.Join(incident.Labels, " → "), source, target)
+}
+
+func rank(severity string) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return 0
+}
+
+// maxSeverity 返回较高的严重程度，事件的严重程度只升不降
+func maxSeverity(a, b string) string {
+	if a == "" || rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	if s == "" || contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -0,0 +1,123 @@
+package incident
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+)
+
+func newAlert(t *testing.T, store *db.Store, src, dst, label string, at time.Time) db.Alert {
+	t.Helper()
+	alert := db.Alert{CreatedAt: at, SourceIP: src, DestIP: dst, Type: label, Confidence: 0.9, Count: 1, LastSeen: at}
+	if err := store.CreateAlert(&alert); err != nil {
+		t.Fatal(err)
+	}
+	return alert
+}
+
+func TestCorrelateKillChain(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCorrelator(loader.IncidentConfig{Window: 3600}, store)
+	now := time.Now()
+
+	steps := []struct {
+		label    string
+		severity string
+	}{
+		{"PortScan", db.SeverityLow},
+		{"Brute Force", db.SeverityHigh}, // 侦察后的入侵尝试提升一级
+		{"Web Attack", db.SeverityCritical},
+	}
+	var id uint
+	for i, step := range steps {
+		inc, err := c.Correlate(newAlert(t, store, "192.0.2.1", "10.0.0.5", step.label, now.Add(time.Duration(i)*time.Minute)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			id = inc.ID
+		} else if inc.ID != id {
+			t.Fatalf("step %d: expected incident %d, got %d", i, id, inc.ID)
+		}
+		if inc.Severity != step.severity {
+			t.Errorf("after %s: severity %s, want %s", step.label, inc.Severity, step.severity)
+		}
+	}
+
+	inc, err := store.GetIncident(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inc.AlertCount != 3 || inc.Title != "PortScan → Brute Force → Web Attack from 192.0.2.1 against 10.0.0.5" {
+		t.Errorf("unexpected incident: %+v", inc)
+	}
+	kinds := make([]string, len(inc.Timeline))
+	for i, ev := range inc.Timeline {
+		kinds[i] = ev.Kind
+	}
+	want := []string{
+		db.IncidentEventCreated,
+		db.IncidentEventAlert, db.IncidentEventSeverity,
+		db.IncidentEventAlert, db.IncidentEventSeverity,
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("timeline %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("timeline %v, want %v", kinds, want)
+		}
+	}
+	alerts, err := store.GetIncidentAlerts(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 3 {
+		t.Errorf("expected 3 linked alerts, got %d", len(alerts))
+	}
+}
+
+func TestCorrelateGrouping(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCorrelator(loader.IncidentConfig{Window: 600}, store)
+	now := time.Now()
+
+	first, err := c.Correlate(newAlert(t, store, "198.51.100.1", "10.0.0.9", "DoS", now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一受害主机上的同类攻击归入同一事件
+	inc, _ := c.Correlate(newAlert(t, store, "198.51.100.2", "10.0.0.9", "DoS", now.Add(time.Minute)))
+	if inc.ID != first.ID || len(inc.Attackers) != 2 {
+		t.Errorf("distributed attack not grouped: %+v", inc)
+	}
+
+	// 不同类型的攻击不按受害主机归并
+	if inc, _ := c.Correlate(newAlert(t, store, "198.51.100.3", "10.0.0.9", "PortScan", now.Add(time.Minute))); inc.ID == first.ID {
+		t.Error("unrelated attacker grouped by victim alone")
+	}
+
+	// 超出关联窗口另起新事件
+	if inc, _ := c.Correlate(newAlert(t, store, "198.51.100.1", "10.0.0.9", "DoS", now.Add(time.Hour))); inc.ID == first.ID {
+		t.Error("expected a new incident after the window")
+	}
+
+	// 已关闭的事件不再接收告警
+	first.Status = db.IncidentClosed
+	if err := store.SaveIncident(first); err != nil {
+		t.Fatal(err)
+	}
+	if inc, _ := c.Correlate(newAlert(t, store, "198.51.100.2", "10.0.0.9", "DoS", now.Add(2*time.Minute))); inc.ID == first.ID {
+		t.Error("closed incident received a new alert")
+	}
+}
@@ -1,8 +1,14 @@
 package loader
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 
 	"gopkg.in/yaml.v3"
@@ -22,14 +28,118 @@ type Config struct {
 	Detection   DetectionConfig   `yaml:"detection"`
 	Response    ResponseConfig    `yaml:"response"`
 	Logging     LoggingConfig     `yaml:"logging"`
+	Eve         EveConfig         `yaml:"eve"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Server      ServerConfig      `yaml:"server"`
+	Retention   RetentionConfig   `yaml:"retention"`
 	Performance PerformanceConfig `yaml:"performance"`
 }
 
 // CaptureConfig 数据包捕获配置
 type CaptureConfig struct {
-	Interface   string `yaml:"interface"`
-	Snaplen     int    `yaml:"snaplen"`
-	Promiscuous bool   `yaml:"promiscuous"`
+	Backend     string         `yaml:"backend,omitempty"` // 抓包后端: pcap (默认)、afpacket 或 nfqueue (后两者仅 Linux)
+	Interface   InterfaceList  `yaml:"interface"`
+	Snaplen     int            `yaml:"snaplen"`
+	Promiscuous bool           `yaml:"promiscuous"`
+	BPFFilter   string         `yaml:"bpf_filter,omitempty"` // 默认 BPF 过滤表达式，接口未单独配置时使用
+	AFPacket    AFPacketConfig `yaml:"afpacket"`
+	NFQueue     NFQueueConfig  `yaml:"nfqueue"`
+}
+
+// AFPacketConfig AF_PACKET (TPACKET_V3) 抓包后端配置
+type AFPacketConfig struct {
+	BlockSize    int    `yaml:"block_size"`    // 环形缓冲区每块大小（字节），需为页大小整数倍
+	NumBlocks    int    `yaml:"num_blocks"`    // 环形缓冲区块数
+	BlockTimeout int    `yaml:"block_timeout"` // 块超时（毫秒）
+	Fanout       bool   `yaml:"fanout"`        // 启用 PACKET_FANOUT 哈希分流到多个处理协程
+	FanoutGroup  uint16 `yaml:"fanout_group"`  // fanout 组 ID
+}
+
+// NFQueueConfig NFQUEUE 内联 (IPS) 模式配置
+// 需要配合防火墙规则把流量送入队列，例如:
+// iptables -I FORWARD -j NFQUEUE --queue-num 0 --queue-bypass
+type NFQueueConfig struct {
+	QueueNum    uint16 `yaml:"queue_num"`     // 队列编号
+	MaxQueueLen uint32 `yaml:"max_queue_len"` // 内核队列最大长度
+	FailOpen    bool   `yaml:"fail_open"`     // 过载时放行 (true) 还是丢弃 (false) 未检测的数据包
+}
+
+// 抓包后端
+const (
+	CaptureBackendPcap     = "pcap"
+	CaptureBackendAFPacket = "afpacket"
+	CaptureBackendNFQueue  = "nfqueue"
+)
+
+// InterfaceConfig 单个抓包接口配置
+type InterfaceConfig struct {
+	Name      string `yaml:"name"`
+	BPFFilter string `yaml:"bpf_filter,omitempty"`
+}
+
+// InterfaceList 抓包接口列表
+// 兼容单个接口名字符串、接口名列表以及带 bpf_filter 的接口列表三种写法
+type InterfaceList []InterfaceConfig
+
+// UnmarshalYAML 解析 capture.interface
+func (l *InterfaceList) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*l = nil
+		if value.Value != "" {
+			*l = InterfaceList{{Name: value.Value}}
+		}
+	case yaml.MappingNode:
+		var ic InterfaceConfig
+		if err := value.Decode(&ic); err != nil {
+			return err
+		}
+		*l = InterfaceList{ic}
+	case yaml.SequenceNode:
+		items := make(InterfaceList, 0, len(value.Content))
+		for _, item := range value.Content {
+			var ic InterfaceConfig
+			if item.Kind == yaml.ScalarNode {
+				ic.Name = item.Value
+			} else if err := item.Decode(&ic); err != nil {
+				return err
+			}
+			items = append(items, ic)
+		}
+		*l = items
+	default:
+		return fmt.Errorf("capture.interface 必须是字符串或列表")
+	}
+	return nil
+}
+
+// MarshalYAML 单个且无过滤器的接口仍写回为字符串，保持配置文件原有格式
+func (l InterfaceList) MarshalYAML() (interface{}, error) {
+	if len(l) == 1 && l[0].BPFFilter == "" {
+		return l[0].Name, nil
+	}
+	return []InterfaceConfig(l), nil
+}
+
+// Names 返回所有接口名
+func (l InterfaceList) Names() []string {
+	names := make([]string, 0, len(l))
+	for _, ic := range l {
+		names = append(names, ic.Name)
+	}
+	return names
+}
+
+// Interfaces 返回抓包接口列表，未单独配置过滤器的接口使用默认 bpf_filter
+func (c CaptureConfig) Interfaces() []InterfaceConfig {
+	ifaces := make([]InterfaceConfig, 0, len(c.Interface))
+	for _, ic := range c.Interface {
+		if ic.BPFFilter == "" {
+			ic.BPFFilter = c.BPFFilter
+		}
+		ifaces = append(ifaces, ic)
+	}
+	return ifaces
 }
 
 // NetworksConfig 网络定义配置
@@ -43,6 +153,23 @@ type FlowConfig struct {
 	UDPTimeout      int `yaml:"udp_timeout"`
 	MaxFlows        int `yaml:"max_flows"`
 	CleanupInterval int `yaml:"cleanup_interval"`
+	ActiveTimeout   int `yaml:"active_timeout"` // 活跃超时检查点（秒），长连接每隔该时间检测一次，0 表示只在流结束时检测
+
+	Records FlowRecordsConfig `yaml:"records"`
+}
+
+// 良性流的存储策略
+const (
+	FlowRecordsNone   = "none"
+	FlowRecordsSample = "sample"
+	FlowRecordsAll    = "all"
+)
+
+// FlowRecordsConfig 流记录存储配置
+// 产生告警的流总是保存，良性流按 Benign 策略保存，为空等同于 none
+type FlowRecordsConfig struct {
+	Benign     string  `yaml:"benign"`      // none, sample, all
+	SampleRate float64 `yaml:"sample_rate"` // sample 策略下良性流的保存比例 (0-1)
 }
 
 // DetectionConfig 检测配置
@@ -58,11 +185,139 @@ type DetectionConfig struct {
 
 // ResponseConfig 响应配置
 type ResponseConfig struct {
-	EnableBlock   bool     `yaml:"enable_block"`
-	BlockDuration int      `yaml:"block_duration"`
-	Whitelist     []string `yaml:"whitelist"`
+	EnableBlock    bool             `yaml:"enable_block"`
+	EnableTCPReset bool             `yaml:"enable_tcp_reset"` // 向恶意 TCP 流两端注入 RST (需要 root 或 CAP_NET_RAW)
+	BlockDuration  int              `yaml:"block_duration"`
+	Whitelist      WhitelistList    `yaml:"whitelist"`
+	Firewall       FirewallConfig   `yaml:"firewall"`
+	Alerts         AlertsConfig     `yaml:"alerts"`
+	Incidents      IncidentConfig   `yaml:"incidents"`
+	Notifiers      []NotifierConfig `yaml:"notifiers"`
+	Syslog         SyslogConfig     `yaml:"syslog"`
+}
+
+// WhitelistConfig 单个白名单条目
+// Entry 可以是 IP、CIDR 前缀或主机名 (加载时解析)
+type WhitelistConfig struct {
+	Entry   string `yaml:"entry"`
+	TTL     int    `yaml:"ttl,omitempty"` // 有效期（秒），从加载时开始计算，0 表示永久
+	Comment string `yaml:"comment,omitempty"`
+}
+
+// WhitelistList 白名单列表，每项可以写成字符串或带 ttl/comment 的映射
+type WhitelistList []WhitelistConfig
+
+// UnmarshalYAML 解析 response.whitelist
+func (l *WhitelistList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.SequenceNode {
+		return fmt.Errorf("response.whitelist 必须是列表")
+	}
+	items := make(WhitelistList, 0, len(value.Content))
+	for _, item := range value.Content {
+		var wc WhitelistConfig
+		if item.Kind == yaml.ScalarNode {
+			wc.Entry = item.Value
+		} else if err := item.Decode(&wc); err != nil {
+			return err
+		}
+		items = append(items, wc)
+	}
+	*l = items
+	return nil
+}
+
+// MarshalYAML 只有条目本身的项写回为字符串，保持配置文件原有格式
+func (l WhitelistList) MarshalYAML() (interface{}, error) {
+	items := make([]interface{}, 0, len(l))
+	for _, wc := range l {
+		if wc.TTL == 0 && wc.Comment == "" {
+			items = append(items, wc.Entry)
+		} else {
+			items = append(items, wc)
+		}
+	}
+	return items, nil
+}
+
+// FirewallConfig 自动封禁使用的防火墙后端配置
+type FirewallConfig struct {
+	Backend string   `yaml:"backend"` // auto、nftables、ipset、iptables、netsh 或 dryrun
+	Table   string   `yaml:"table"`   // nftables 表名 (inet 族)
+	Chains  []string `yaml:"chains"`  // 挂载封禁规则的链，nftables 后端按同名钩子建链
+	Set     string   `yaml:"set"`     // nftables/ipset 集合名，IPv6 集合名自动追加 "6"
+}
+
+// AlertsConfig 告警聚合与限速配置
+type AlertsConfig struct {
+	DedupWindow int `yaml:"dedup_window"` // 聚合窗口（秒），窗口内相同 (源, 目的, 类型) 的事件合并为一条告警，0 表示不聚合
+	RateLimit   int `yaml:"rate_limit"`   // 每分钟最多新建的告警数，0 表示不限制
 }
 
+// IncidentConfig 告警关联配置
+type IncidentConfig struct {
+	Window int `yaml:"window"` // 关联窗口（秒），距事件最后活动超过该时间的告警另起新事件，0 表示不关联
+}
+
+// NotifierConfig 告警通知目标 (Webhook)
+type NotifierConfig struct {
+	Name          string            `yaml:"name"` // 目标名称，发件箱按名称投递
+	URL           string            `yaml:"url"`
+	Format        string            `yaml:"format"`         // json (通用 JSON) 或 slack (Slack 兼容)
+	Labels        []string          `yaml:"labels"`         // 只通知这些攻击类型，为空表示全部
+	MinConfidence float32           `yaml:"min_confidence"` // 低于该置信度的告警不通知
+	Headers       map[string]string `yaml:"headers"`        // 附加的请求头，如鉴权 Token
+	Timeout       int               `yaml:"timeout"`        // 单次请求超时（秒），默认 10
+}
+
+// 通知格式
+const (
+	NotifierFormatJSON  = "json"
+	NotifierFormatSlack = "slack"
+)
+
+// SyslogConfig 通过 RFC 5424 syslog 向 SIEM 转发告警
+type SyslogConfig struct {
+	Enabled      bool            `yaml:"enabled"`
+	Network      string          `yaml:"network"`       // udp、tcp 或 tls
+	Address      string          `yaml:"address"`       // host:port
+	Format       string          `yaml:"format"`        // 消息体格式: cef、leef 或 json
+	Facility     string          `yaml:"facility"`      // 如 local0、daemon、auth
+	AppName      string          `yaml:"app_name"`      // RFC 5424 APP-NAME
+	Hostname     string          `yaml:"hostname"`      // RFC 5424 HOSTNAME，为空时使用本机主机名
+	PayloadLimit int             `yaml:"payload_limit"` // 载荷摘录的最大字节数，0 表示不发送载荷
+	TLS          SyslogTLSConfig `yaml:"tls"`
+}
+
+// SyslogTLSConfig syslog over TLS (RFC 5425) 的证书配置
+type SyslogTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`   // 校验服务端证书的 CA，为空时使用系统根证书
+	CertFile           string `yaml:"cert_file"` // 客户端证书 (可选，用于双向认证)
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"` // 为空时取 address 中的主机名
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// syslog 传输方式与消息体格式
+const (
+	SyslogNetworkUDP = "udp"
+	SyslogNetworkTCP = "tcp"
+	SyslogNetworkTLS = "tls"
+
+	SyslogFormatCEF  = "cef"
+	SyslogFormatLEEF = "leef"
+	SyslogFormatJSON = "json"
+)
+
+// 防火墙后端
+const (
+	FirewallBackendAuto     = "auto"
+	FirewallBackendNftables = "nftables"
+	FirewallBackendIpset    = "ipset"
+	FirewallBackendIptables = "iptables"
+	FirewallBackendNetsh    = "netsh"
+	FirewallBackendDryRun   = "dryrun"
+)
+
 // LoggingConfig 日志配置
 type LoggingConfig struct {
 	Level      string `yaml:"level"`
@@ -74,11 +329,167 @@ type LoggingConfig struct {
 	MaxAge     int    `yaml:"max_age"`
 }
 
+// EveConfig Suricata EVE 兼容的 NDJSON 事件日志配置
+type EveConfig struct {
+	Enabled         bool     `yaml:"enabled"`
+	FilePath        string   `yaml:"file_path"`
+	MaxSize         int      `yaml:"max_size"`          // 单个文件最大大小（MB）
+	MaxBackups      int      `yaml:"max_backups"`       // 保留的文件数量
+	MaxAge          int      `yaml:"max_age"`           // 保留天数
+	Types           []string `yaml:"types"`             // 输出的事件类型: alert, flow, stats
+	StatsInterval   int      `yaml:"stats_interval"`    // stats 事件的输出间隔（秒）
+	CommunityIDSeed uint16   `yaml:"community_id_seed"` // Community ID 种子，需与其他传感器一致
+}
+
+// EVE 事件类型
+const (
+	EveTypeAlert = "alert"
+	EveTypeFlow  = "flow"
+	EveTypeStats = "stats"
+)
+
+// DatabaseConfig 告警数据库配置，多个传感器可以共用同一个 PostgreSQL 数据库
+type DatabaseConfig struct {
+	Driver string `yaml:"driver"` // sqlite 或 postgres，为空时使用 sqlite
+	DSN    string `yaml:"dsn"`    // SQLite 为数据库文件路径，PostgreSQL 为连接串
+}
+
+// 支持的数据库驱动
+const (
+	DatabaseDriverSQLite   = "sqlite"
+	DatabaseDriverPostgres = "postgres"
+)
+
+// DefaultSQLitePath 未配置 database 时使用的 SQLite 数据库文件
+const DefaultSQLitePath = "config/ids.db"
+
+// Source 返回数据库驱动与连接串，未配置时使用默认的 SQLite 文件
+func (c DatabaseConfig) Source() (string, string) {
+	driver, dsn := c.Driver, c.DSN
+	if driver == "" {
+		driver = DatabaseDriverSQLite
+	}
+	if driver == DatabaseDriverSQLite && dsn == "" {
+		dsn = DefaultSQLitePath
+	}
+	return driver, dsn
+}
+
+// ServerConfig Web 服务 (HTTP API 与控制台) 配置
+type ServerConfig struct {
+	Listen      string          `yaml:"listen"`       // 监听地址，为空表示所有地址
+	Port        int             `yaml:"port"`         // 监听端口，0 表示默认的 8080
+	CORSOrigins []string        `yaml:"cors_origins"` // 允许跨域访问 API 的来源，如 http://localhost:5173，"*" 表示任意来源 (此时不允许携带 Cookie)
+	TLS         ServerTLSConfig `yaml:"tls"`
+	Auth        AuthConfig      `yaml:"auth"`
+}
+
+// DefaultServerPort 未配置 server.port 时的监听端口
+const DefaultServerPort = 8080
+
+// Address 返回 host:port 形式的监听地址
+func (c ServerConfig) Address() string {
+	port := c.Port
+	if port == 0 {
+		port = DefaultServerPort
+	}
+	return net.JoinHostPort(c.Listen, strconv.Itoa(port))
+}
+
+// Loopback 判断是否只监听本机地址
+func (c ServerConfig) Loopback() bool {
+	if c.Listen == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(c.Listen)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ServerTLSConfig Web 服务的 HTTPS 配置
+// 证书、私钥与客户端 CA 文件更新后自动重新加载 (也可以发送 SIGHUP)，不需要重启传感器
+type ServerTLSConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientCAFile   string `yaml:"client_ca_file"`   // 校验客户端证书的 CA，配置后开启双向认证 (mTLS)
+	ClientAuth     string `yaml:"client_auth"`      // optional (校验提供的客户端证书，默认) 或 require (必须提供)
+	ClientCertRole string `yaml:"client_cert_role"` // 客户端证书通过校验的调用方获得的角色，为空时仍需 API Key 或登录
+	ReloadInterval int    `yaml:"reload_interval"`  // 检查证书文件更新的间隔（秒），0 表示只在收到 SIGHUP 时重新加载
+}
+
+// 客户端证书校验方式
+const (
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// AuthConfig API 认证配置
+// 用户名密码登录后签发令牌 (JWT)，脚本与其他系统使用 API Key
+type AuthConfig struct {
+	Enabled     bool           `yaml:"enabled"`      // 关闭时所有请求都具有 admin 权限，只允许监听本机地址
+	TokenSecret string         `yaml:"token_secret"` // 令牌签名密钥，为空时每次启动随机生成 (重启后需要重新登录)
+	TokenTTL    int            `yaml:"token_ttl"`    // 令牌有效期（秒），0 表示默认的 12 小时
+	Users       []AuthUser     `yaml:"users"`
+	APIKeys     []APIKeyConfig `yaml:"api_keys"`
+}
+
+// AuthUser 控制台用户，密码以 bcrypt 哈希保存 (ids auth hash-password 生成)
+type AuthUser struct {
+	Username     string `yaml:"username"`
+	PasswordHash string `yaml:"password_hash"`
+	Role         string `yaml:"role"`
+}
+
+// APIKeyConfig API Key，只保存 SHA-256 摘要 (ids auth new-key 生成)
+type APIKeyConfig struct {
+	Name      string `yaml:"name"` // 审计日志中的操作者名称
+	KeySHA256 string `yaml:"key_sha256"`
+	Role      string `yaml:"role"`
+}
+
+// 角色，权限从低到高: viewer 只读，analyst 可处置告警、封禁和导出，admin 可修改引擎配置并查看审计日志
+const (
+	RoleViewer  = "viewer"
+	RoleAnalyst = "analyst"
+	RoleAdmin   = "admin"
+)
+
+// validRole 检查角色名
+func validRole(role string) bool {
+	return role == RoleViewer || role == RoleAnalyst || role == RoleAdmin
+}
+
+// validOrigin 检查 CORS 来源，必须是 "*" 或不带路径的 http(s)://host[:port]
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		(u.Path == "" || u.Path == "/") && u.RawQuery == "" && u.User == nil
+}
+
+// RetentionConfig 数据保留配置，后台定期硬删除过期数据并回收数据库空间
+type RetentionConfig struct {
+	Interval    int             `yaml:"interval"` // 清理间隔（秒），0 表示不清理
+	Alerts      RetentionPolicy `yaml:"alerts"`
+	FlowRecords RetentionPolicy `yaml:"flow_records"`
+	Payloads    RetentionPolicy `yaml:"payloads"`     // 过期告警只清空载荷，告警本身保留
+	PruneJudged bool            `yaml:"prune_judged"` // 是否同时删除已判定的告警，默认保留以免丢失反馈样本
+}
+
+// RetentionPolicy 单类数据的保留策略，两个条件任一满足即删除，0 表示不按该条件清理
+type RetentionPolicy struct {
+	MaxAgeDays int `yaml:"max_age_days"` // 最长保留天数
+	MaxRows    int `yaml:"max_rows"`     // 最多保留的 (最新) 行数
+}
+
 // PerformanceConfig 性能配置
 type PerformanceConfig struct {
 	DecoderWorkers  int `yaml:"decoder_workers"`
 	FeatureWorkers  int `yaml:"feature_workers"`
 	PacketQueueSize int `yaml:"packet_queue_size"`
+	StatsInterval   int `yaml:"stats_interval"` // 抓包与流水线统计采集间隔（秒）
 }
 
 // Load 从YAML文件加载配置并初始化全局变量
@@ -144,13 +555,36 @@ func UpdateDetectionThreshold(newThreshold float64) error {
 
 // Validate 验证配置的有效性
 func (c *Config) Validate() error {
-	// 验证捕获配置
-	if c.Capture.Interface == "" {
+	// 验证捕获配置 (nfqueue 后端从队列取包，不需要接口)
+	if len(c.Capture.Interface) == 0 && c.Capture.Backend != CaptureBackendNFQueue {
 		return fmt.Errorf("capture.interface 不能为空")
 	}
+	seen := make(map[string]bool)
+	for _, ic := range c.Capture.Interface {
+		if ic.Name == "" {
+			return fmt.Errorf("capture.interface 不能包含空接口名")
+		}
+		if seen[ic.Name] {
+			return fmt.Errorf("capture.interface 重复配置接口 %s", ic.Name)
+		}
+		seen[ic.Name] = true
+	}
 	if c.Capture.Snaplen <= 0 {
 		return fmt.Errorf("capture.snaplen 必须大于0")
 	}
+	switch c.Capture.Backend {
+	case "", CaptureBackendPcap:
+	case CaptureBackendAFPacket:
+		if c.Capture.AFPacket.BlockSize < 0 || c.Capture.AFPacket.NumBlocks < 0 || c.Capture.AFPacket.BlockTimeout < 0 {
+			return fmt.Errorf("capture.afpacket 参数不能为负数")
+		}
+		if c.Capture.AFPacket.BlockSize > 0 && c.Capture.AFPacket.BlockSize < c.Capture.Snaplen {
+			return fmt.Errorf("capture.afpacket.block_size 不能小于 capture.snaplen")
+		}
+	case CaptureBackendNFQueue:
+	default:
+		return fmt.Errorf("capture.backend 只能是 pcap、afpacket 或 nfqueue")
+	}
 
 	// 验证流配置
 	if c.Flow.TCPTimeout <= 0 {
@@ -162,6 +596,17 @@ func (c *Config) Validate() error {
 	if c.Flow.MaxFlows <= 0 {
 		return fmt.Errorf("flow.max_flows 必须大于0")
 	}
+	if c.Flow.ActiveTimeout < 0 {
+		return fmt.Errorf("flow.active_timeout 不能为负数")
+	}
+	switch c.Flow.Records.Benign {
+	case "", FlowRecordsNone, FlowRecordsSample, FlowRecordsAll:
+	default:
+		return fmt.Errorf("flow.records.benign 只能是 none、sample 或 all")
+	}
+	if c.Flow.Records.SampleRate < 0 || c.Flow.Records.SampleRate > 1 {
+		return fmt.Errorf("flow.records.sample_rate 必须在0-1之间")
+	}
 
 	// 验证检测配置
 	if c.Detection.ModelPath == "" {
@@ -177,6 +622,136 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("detection.threshold 必须在0-1之间")
 	}
 
+	// 验证响应配置
+	for _, wc := range c.Response.Whitelist {
+		if wc.Entry == "" {
+			return fmt.Errorf("response.whitelist 不能包含空条目")
+		}
+		if wc.TTL < 0 {
+			return fmt.Errorf("response.whitelist 条目 %s 的 ttl 不能为负数", wc.Entry)
+		}
+	}
+	fw := c.Response.Firewall
+	switch fw.Backend {
+	case "", FirewallBackendAuto, FirewallBackendIptables, FirewallBackendNetsh, FirewallBackendDryRun:
+	case FirewallBackendNftables:
+		if fw.Table == "" {
+			return fmt.Errorf("response.firewall.table 不能为空")
+		}
+		fallthrough
+	case FirewallBackendIpset:
+		if fw.Set == "" {
+			return fmt.Errorf("response.firewall.set 不能为空")
+		}
+	default:
+		return fmt.Errorf("response.firewall.backend 只能是 auto、nftables、ipset、iptables、netsh 或 dryrun")
+	}
+	switch fw.Backend {
+	case FirewallBackendNftables, FirewallBackendIpset, FirewallBackendIptables:
+		if len(fw.Chains) == 0 {
+			return fmt.Errorf("response.firewall.chains 不能为空")
+		}
+	}
+
+	if c.Response.Alerts.DedupWindow < 0 {
+		return fmt.Errorf("response.alerts.dedup_window 不能为负数")
+	}
+	if c.Response.Alerts.RateLimit < 0 {
+		return fmt.Errorf("response.alerts.rate_limit 不能为负数")
+	}
+	if c.Response.Incidents.Window < 0 {
+		return fmt.Errorf("response.incidents.window 不能为负数")
+	}
+	if sl := c.Response.Syslog; sl.Enabled {
+		switch sl.Network {
+		case SyslogNetworkUDP, SyslogNetworkTCP, SyslogNetworkTLS:
+		default:
+			return fmt.Errorf("response.syslog.network 只能是 udp、tcp 或 tls")
+		}
+		switch sl.Format {
+		case SyslogFormatCEF, SyslogFormatLEEF, SyslogFormatJSON:
+		default:
+			return fmt.Errorf("response.syslog.format 只能是 cef、leef 或 json")
+		}
+		if sl.Address == "" {
+			return fmt.Errorf("response.syslog.address 不能为空")
+		}
+		if sl.PayloadLimit < 0 {
+			return fmt.Errorf("response.syslog.payload_limit 不能为负数")
+		}
+		if (sl.TLS.CertFile == "") != (sl.TLS.KeyFile == "") {
+			return fmt.Errorf("response.syslog.tls.cert_file 和 key_file 必须同时设置")
+		}
+	}
+	names := make(map[string]bool)
+	for _, n := range c.Response.Notifiers {
+		if n.Name == "" || n.URL == "" {
+			return fmt.Errorf("response.notifiers 每项必须设置 name 和 url")
+		}
+		if names[n.Name] {
+			return fmt.Errorf("response.notifiers 名称重复: %s", n.Name)
+		}
+		names[n.Name] = true
+		switch n.Format {
+		case "", NotifierFormatJSON, NotifierFormatSlack:
+		default:
+			return fmt.Errorf("response.notifiers.%s.format 只能是 json 或 slack", n.Name)
+		}
+		if n.MinConfidence < 0 || n.MinConfidence > 1 {
+			return fmt.Errorf("response.notifiers.%s.min_confidence 必须在0-1之间", n.Name)
+		}
+		if n.Timeout < 0 {
+			return fmt.Errorf("response.notifiers.%s 的 timeout 不能为负数", n.Name)
+		}
+	}
+
+	// 验证 EVE 日志配置
+	if c.Eve.Enabled {
+		if c.Eve.FilePath == "" {
+			return fmt.Errorf("eve.file_path 不能为空")
+		}
+		for _, t := range c.Eve.Types {
+			switch t {
+			case EveTypeAlert, EveTypeFlow, EveTypeStats:
+			default:
+				return fmt.Errorf("eve.types 只能包含 alert、flow 或 stats")
+			}
+		}
+		if c.Eve.StatsInterval < 0 {
+			return fmt.Errorf("eve.stats_interval 不能为负数")
+		}
+	}
+
+	// 验证数据库配置
+	switch c.Database.Driver {
+	case "", DatabaseDriverSQLite:
+	case DatabaseDriverPostgres:
+		if c.Database.DSN == "" {
+			return fmt.Errorf("database.driver 为 %s 时必须配置 database.dsn", DatabaseDriverPostgres)
+		}
+	default:
+		return fmt.Errorf("database.driver 必须是 %s 或 %s", DatabaseDriverSQLite, DatabaseDriverPostgres)
+	}
+
+	// 验证 Web 服务配置
+	if err := c.Server.validate(); err != nil {
+		return err
+	}
+
+	// 验证数据保留配置
+	if c.Retention.Interval < 0 {
+		return fmt.Errorf("retention.interval 不能为负数")
+	}
+	for name, p := range map[string]RetentionPolicy{
+		"alerts":       c.Retention.Alerts,
+		"flow_records": c.Retention.FlowRecords,
+		"payloads":     c.Retention.Payloads,
+	} {
+		if p.MaxAgeDays < 0 || p.MaxRows < 0 {
+			return fmt.Errorf("retention.%s 的 max_age_days 和 max_rows 不能为负数", name)
+		}
+	}
+
 	// 验证性能配置
 	if c.Performance.DecoderWorkers <= 0 {
 		return fmt.Errorf("performance.decoder_workers 必须大于0")
@@ -188,19 +763,114 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validate 验证 Web 服务配置
+func (c *ServerConfig) validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("server.port 必须在 0-65535 之间")
+	}
+	if c.Listen != "" && net.ParseIP(c.Listen) == nil && strings.ContainsAny(c.Listen, ":/ ") {
+		return fmt.Errorf("server.listen 必须是 IP 地址或主机名，端口使用 server.port 配置")
+	}
+
+	t := c.TLS
+	if t.Enabled && (t.CertFile == "" || t.KeyFile == "") {
+		return fmt.Errorf("server.tls.enabled 为 true 时必须配置 cert_file 和 key_file")
+	}
+	switch t.ClientAuth {
+	case "", ClientAuthOptional, ClientAuthRequire:
+	default:
+		return fmt.Errorf("server.tls.client_auth 必须是 %s 或 %s", ClientAuthOptional, ClientAuthRequire)
+	}
+	if (t.ClientAuth != "" || t.ClientCertRole != "") && t.ClientCAFile == "" {
+		return fmt.Errorf("server.tls 配置 client_auth 或 client_cert_role 时必须配置 client_ca_file")
+	}
+	if t.ClientCertRole != "" && !validRole(t.ClientCertRole) {
+		return fmt.Errorf("server.tls.client_cert_role 必须是 viewer、analyst 或 admin")
+	}
+	if t.ReloadInterval < 0 {
+		return fmt.Errorf("server.tls.reload_interval 不能为负数")
+	}
+
+	for _, origin := range c.CORSOrigins {
+		if !validOrigin(origin) {
+			return fmt.Errorf("server.cors_origins 中的 %q 必须是 \"*\" 或 http(s)://主机[:端口]", origin)
+		}
+	}
+
+	a := c.Auth
+	if a.TokenTTL < 0 {
+		return fmt.Errorf("server.auth.token_ttl 不能为负数")
+	}
+	if a.TokenSecret != "" && len(a.TokenSecret) < 32 {
+		return fmt.Errorf("server.auth.token_secret 至少需要 32 个字符")
+	}
+	// 未开启认证时任何人都是 admin，只允许本机访问；开启认证但没有用户或 API Key 时启动时生成临时管理员
+	if !a.Enabled && !c.Loopback() {
+		return fmt.Errorf("server.auth.enabled 为 false 时所有请求都具有 admin 权限，server.listen 只能是本机地址 (如 127.0.0.1)")
+	}
+	names := make(map[string]bool)
+	for _, u := range a.Users {
+		if u.Username == "" {
+			return fmt.Errorf("server.auth.users 的 username 不能为空")
+		}
+		if names[u.Username] {
+			return fmt.Errorf("server.auth.users 中的用户 %s 重复", u.Username)
+		}
+		names[u.Username] = true
+		if !strings.HasPrefix(u.PasswordHash, "$2") {
+			return fmt.Errorf("server.auth.users.%s.password_hash 必须是 bcrypt 哈希", u.Username)
+		}
+		if !validRole(u.Role) {
+			return fmt.Errorf("server.auth.users.%s.role 必须是 viewer、analyst 或 admin", u.Username)
+		}
+	}
+	for _, k := range a.APIKeys {
+		if k.Name == "" {
+			return fmt.Errorf("server.auth.api_keys 的 name 不能为空")
+		}
+		if names[k.Name] {
+			return fmt.Errorf("server.auth.api_keys 的名称 %s 与其他用户或 API Key 重复", k.Name)
+		}
+		names[k.Name] = true
+		if b, err := hex.DecodeString(k.KeySHA256); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("server.auth.api_keys.%s.key_sha256 必须是 64 位十六进制 SHA-256 摘要", k.Name)
+		}
+		if !validRole(k.Role) {
+			return fmt.Errorf("server.auth.api_keys.%s.role 必须是 viewer、analyst 或 admin", k.Name)
+		}
+	}
+	return nil
+}
+
 // GetDefaultConfig 返回默认配置
 func GetDefaultConfig() *Config {
 	return &Config{
 		Capture: CaptureConfig{
-			Interface:   "eth0",
+			Backend:     CaptureBackendPcap,
+			Interface:   InterfaceList{{Name: "eth0"}},
 			Snaplen:     65535,
 			Promiscuous: false,
+			AFPacket: AFPacketConfig{
+				BlockSize:    1 << 20,
+				NumBlocks:    64,
+				BlockTimeout: 64,
+				FanoutGroup:  42,
+			},
+			NFQueue: NFQueueConfig{
+				MaxQueueLen: 4096,
+				FailOpen:    true,
+			},
 		},
 		Flow: FlowConfig{
 			TCPTimeout:      60,
 			UDPTimeout:      30,
 			MaxFlows:        100000,
 			CleanupInterval: 10,
+			ActiveTimeout:   120,
+			Records: FlowRecordsConfig{
+				Benign:     FlowRecordsSample,
+				SampleRate: 0.01,
+			},
 		},
 		Detection: DetectionConfig{
 			ModelPath:            "config/model.onnx",
@@ -214,7 +884,28 @@ func GetDefaultConfig() *Config {
 		Response: ResponseConfig{
 			EnableBlock:   true,
 			BlockDuration: 3600,
-			Whitelist:     []string{},
+			Whitelist:     WhitelistList{},
+			Firewall: FirewallConfig{
+				Backend: FirewallBackendAuto,
+				Table:   "go_ids",
+				Chains:  []string{"INPUT", "FORWARD"},
+				Set:     "ids_blocklist",
+			},
+			Alerts: AlertsConfig{
+				DedupWindow: 300,
+				RateLimit:   600,
+			},
+			Incidents: IncidentConfig{
+				Window: 3600,
+			},
+			Syslog: SyslogConfig{
+				Network:      SyslogNetworkUDP,
+				Address:      "127.0.0.1:514",
+				Format:       SyslogFormatCEF,
+				Facility:     "local0",
+				AppName:      "go-ids",
+				PayloadLimit: 256,
+			},
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
@@ -225,10 +916,40 @@ func GetDefaultConfig() *Config {
 			MaxBackups: 5,
 			MaxAge:     30,
 		},
+		Eve: EveConfig{
+			FilePath:      "logs/eve.json",
+			MaxSize:       100,
+			MaxBackups:    5,
+			MaxAge:        30,
+			Types:         []string{EveTypeAlert, EveTypeFlow, EveTypeStats},
+			StatsInterval: 30,
+		},
+		Database: DatabaseConfig{
+			Driver: DatabaseDriverSQLite,
+			DSN:    DefaultSQLitePath,
+		},
+		Server: ServerConfig{
+			Port:        DefaultServerPort,
+			CORSOrigins: []string{"http://localhost:5173"},
+			TLS: ServerTLSConfig{
+				ReloadInterval: 60,
+			},
+			Auth: AuthConfig{
+				Enabled:  true,
+				TokenTTL: 43200,
+			},
+		},
+		Retention: RetentionConfig{
+			Interval:    3600,
+			Alerts:      RetentionPolicy{MaxAgeDays: 90, MaxRows: 1000000},
+			FlowRecords: RetentionPolicy{MaxAgeDays: 7, MaxRows: 1000000},
+			Payloads:    RetentionPolicy{MaxAgeDays: 30},
+		},
 		Performance: PerformanceConfig{
 			DecoderWorkers:  4,
 			FeatureWorkers:  2,
 			PacketQueueSize: 10000,
+			StatsInterval:   10,
 		},
 	}
 }
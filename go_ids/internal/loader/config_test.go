@@ -3,6 +3,8 @@ package loader
 import (
 	"os"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -13,7 +15,7 @@ func TestLoadConfig(t *testing.T) {
 	}
 
 	// 验证配置值
-	if config.Capture.Interface == "" {
+	if len(config.Capture.Interface) == 0 {
 		t.Error("capture.interface 不能为空")
 	}
 	if config.Detection.Threshold <= 0 {
@@ -25,8 +27,8 @@ func TestDefaultConfig(t *testing.T) {
 	config := GetDefaultConfig()
 
 	// 验证默认值
-	if config.Capture.Interface != "eth0" {
-		t.Errorf("期望默认interface为eth0，实际为: %s", config.Capture.Interface)
+	if names := config.Capture.Interface.Names(); len(names) != 1 || names[0] != "eth0" {
+		t.Errorf("期望默认interface为eth0，实际为: %v", names)
 	}
 	if config.Flow.TCPTimeout != 60 {
 		t.Errorf("期望默认TCP超时为60，实际为: %d", config.Flow.TCPTimeout)
@@ -42,7 +44,7 @@ func TestValidateConfig(t *testing.T) {
 	}
 
 	// 测试无效配置
-	config.Capture.Interface = ""
+	config.Capture.Interface = nil
 	if err := config.Validate(); err == nil {
 		t.Error("空interface应该验证失败")
 	}
@@ -72,3 +74,200 @@ func TestLoadInvalidYAML(t *testing.T) {
 		t.Error("加载无效YAML应该返回错误")
 	}
 }
+
+func TestInterfaceList(t *testing.T) {
+	data := []byte(`
+capture:
+  interface:
+    - "eth1"
+    - name: "eth2"
+      bpf_filter: "not port 873"
+  bpf_filter: "ip or ip6"
+`)
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("解析接口列表失败: %v", err)
+	}
+
+	ifaces := config.Capture.Interfaces()
+	if len(ifaces) != 2 {
+		t.Fatalf("期望2个接口，实际为: %d", len(ifaces))
+	}
+	if ifaces[0].Name != "eth1" || ifaces[0].BPFFilter != "ip or ip6" {
+		t.Errorf("eth1 应使用默认过滤器，实际为: %+v", ifaces[0])
+	}
+	if ifaces[1].Name != "eth2" || ifaces[1].BPFFilter != "not port 873" {
+		t.Errorf("eth2 应使用自身过滤器，实际为: %+v", ifaces[1])
+	}
+
+	// 单个接口写回时保持字符串格式
+	out, err := yaml.Marshal(InterfaceList{{Name: "eth0"}})
+	if err != nil {
+		t.Fatalf("序列化接口列表失败: %v", err)
+	}
+	if string(out) != "eth0\n" {
+		t.Errorf("单个接口应序列化为字符串，实际为: %q", out)
+	}
+
+	config = *GetDefaultConfig()
+	config.Capture.Interface = InterfaceList{{Name: "eth0"}, {Name: "eth0"}}
+	if err := config.Validate(); err == nil {
+		t.Error("重复接口应该验证失败")
+	}
+}
+
+func TestWhitelistList(t *testing.T) {
+	data := []byte(`
+response:
+  whitelist:
+    - "127.0.0.1"
+    - "10.0.0.0/8"
+    - entry: "scanner.example.com"
+      ttl: 3600
+      comment: "季度扫描"
+`)
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatalf("解析白名单失败: %v", err)
+	}
+
+	wl := config.Response.Whitelist
+	if len(wl) != 3 {
+		t.Fatalf("期望3个白名单条目，实际为: %d", len(wl))
+	}
+	if wl[1].Entry != "10.0.0.0/8" || wl[2].Entry != "scanner.example.com" || wl[2].TTL != 3600 {
+		t.Errorf("白名单解析结果不符: %+v", wl)
+	}
+
+	// 只有条目本身的项写回为字符串
+	out, err := yaml.Marshal(WhitelistList{{Entry: "::1"}, {Entry: "10.0.0.0/8", TTL: 60}})
+	if err != nil {
+		t.Fatalf("序列化白名单失败: %v", err)
+	}
+	if want := "- ::1\n- entry: 10.0.0.0/8\n  ttl: 60\n"; string(out) != want {
+		t.Errorf("白名单序列化结果为 %q，期望 %q", out, want)
+	}
+}
+
+func TestDatabaseConfig(t *testing.T) {
+	// 未配置 database 时使用默认的 SQLite 文件
+	if driver, dsn := (DatabaseConfig{}).Source(); driver != DatabaseDriverSQLite || dsn != DefaultSQLitePath {
+		t.Errorf("默认数据库为 %s %s", driver, dsn)
+	}
+
+	config := GetDefaultConfig()
+	config.Database = DatabaseConfig{Driver: DatabaseDriverPostgres}
+	if err := config.Validate(); err == nil {
+		t.Error("postgres 未配置 dsn 应该验证失败")
+	}
+	config.Database.DSN = "postgres://ids@db/ids"
+	if err := config.Validate(); err != nil {
+		t.Errorf("postgres 配置验证失败: %v", err)
+	}
+	config.Database.Driver = "mysql"
+	if err := config.Validate(); err == nil {
+		t.Error("不支持的驱动应该验证失败")
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	const hash = "$2a$10$7EqJtq98hPqEX7fNZaFWoOa0HHvTTRQVgtwvvO5XGXSHFC.qNVu6e"
+	const keyHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	config := GetDefaultConfig()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("默认配置验证失败: %v", err)
+	}
+
+	config.Server.CORSOrigins = []string{"*", "https://ids.example.com:8443"}
+	if err := config.Validate(); err != nil {
+		t.Errorf("CORS 来源验证失败: %v", err)
+	}
+	for _, origin := range []string{"ids.example.com", "ftp://ids.example.com", "https://ids.example.com/console"} {
+		config.Server.CORSOrigins = []string{origin}
+		if err := config.Validate(); err == nil {
+			t.Errorf("无效的 CORS 来源 %q 应该验证失败", origin)
+		}
+	}
+	config.Server.CORSOrigins = nil
+
+	// 未开启认证时只能监听本机地址
+	config.Server.Auth.Enabled = false
+	if err := config.Validate(); err == nil {
+		t.Error("未开启认证且监听所有地址应该验证失败")
+	}
+	for _, listen := range []string{"127.0.0.1", "::1", "localhost"} {
+		config.Server.Listen = listen
+		if err := config.Validate(); err != nil {
+			t.Errorf("未开启认证时监听 %s 验证失败: %v", listen, err)
+		}
+	}
+	config.Server.Listen = ""
+	config.Server.Auth.Enabled = true
+	if err := config.Validate(); err != nil {
+		t.Errorf("开启认证但没有用户时应在启动时生成临时管理员: %v", err)
+	}
+	config.Server.Auth.Users = []AuthUser{{Username: "alice", PasswordHash: hash, Role: RoleAnalyst}}
+	config.Server.Auth.APIKeys = []APIKeyConfig{{Name: "siem", KeySHA256: keyHash, Role: RoleViewer}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("认证配置验证失败: %v", err)
+	}
+
+	cases := map[string]func(a *AuthConfig){
+		"未知角色":     func(a *AuthConfig) { a.Users[0].Role = "root" },
+		"明文密码":     func(a *AuthConfig) { a.Users[0].PasswordHash = "secret" },
+		"重复名称":     func(a *AuthConfig) { a.APIKeys[0].Name = "alice" },
+		"摘要长度错误":   func(a *AuthConfig) { a.APIKeys[0].KeySHA256 = "abcd" },
+		"密钥过短":     func(a *AuthConfig) { a.TokenSecret = "short" },
+		"负数的令牌有效期": func(a *AuthConfig) { a.TokenTTL = -1 },
+	}
+	for name, mutate := range cases {
+		c := *config
+		c.Server.Auth.Users = append([]AuthUser(nil), config.Server.Auth.Users...)
+		c.Server.Auth.APIKeys = append([]APIKeyConfig(nil), config.Server.Auth.APIKeys...)
+		mutate(&c.Server.Auth)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s应该验证失败", name)
+		}
+	}
+}
+
+func TestServerListenAndTLS(t *testing.T) {
+	config := GetDefaultConfig()
+	if addr := config.Server.Address(); addr != ":8080" {
+		t.Errorf("默认监听地址 = %q", addr)
+	}
+	config.Server.Listen, config.Server.Port = "::1", 0
+	if addr := config.Server.Address(); addr != "[::1]:8080" {
+		t.Errorf("IPv6 监听地址 = %q", addr)
+	}
+
+	config.Server.TLS = ServerTLSConfig{
+		Enabled:        true,
+		CertFile:       "server.crt",
+		KeyFile:        "server.key",
+		ClientCAFile:   "ca.crt",
+		ClientAuth:     ClientAuthRequire,
+		ClientCertRole: RoleViewer,
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("TLS 配置验证失败: %v", err)
+	}
+
+	cases := map[string]func(s *ServerConfig){
+		"端口超出范围":   func(s *ServerConfig) { s.Port = 70000 },
+		"监听地址包含端口": func(s *ServerConfig) { s.Listen = "0.0.0.0:8080" },
+		"缺少证书":     func(s *ServerConfig) { s.TLS.CertFile = "" },
+		"未知客户端认证":  func(s *ServerConfig) { s.TLS.ClientAuth = "always" },
+		"缺少客户端 CA": func(s *ServerConfig) { s.TLS.ClientCAFile = "" },
+		"未知证书角色":   func(s *ServerConfig) { s.TLS.ClientCertRole = "root" },
+		"负数的重载间隔":  func(s *ServerConfig) { s.TLS.ReloadInterval = -1 },
+	}
+	for name, mutate := range cases {
+		c := *config
+		mutate(&c.Server)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s应该验证失败", name)
+		}
+	}
+}
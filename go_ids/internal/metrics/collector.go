@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CaptureStats 单个接口的抓包统计 (累计值)
+type CaptureStats struct {
+	Interface string `json:"interface"`
+	Received  int64  `json:"received"`
+	Dropped   int64  `json:"dropped"`    // 内核缓冲区已满导致的丢包
+	IfDropped int64  `json:"if_dropped"` // 网卡/驱动层丢包
+}
+
+// CaptureStatsFunc 读取各抓包源的统计
+// 由 main 注入，避免本包依赖 capture (需要 cgo/libpcap)
+type CaptureStatsFunc func() []CaptureStats
+
+// Snapshot 某一时刻的统计快照
+type Snapshot struct {
+	Timestamp        time.Time         `json:"timestamp"`
+	Capture          []CaptureStats    `json:"capture"`
+	CaptureDropRate  float64           `json:"capture_drop_rate"` // 上一采集周期内的丢包率
+	PacketsProcessed uint64            `json:"packets_processed"`
+	DecodeFailures   map[string]uint64 `json:"decode_failures"`
+	FlowsCreated     uint64            `json:"flows_created"`
+	FlowsExpired     uint64            `json:"flows_expired"`
+	Inferences       uint64            `json:"inferences"`
+	InferenceErrors  uint64            `json:"inference_errors"`
+	AlertsRaised     uint64            `json:"alerts_raised"`
+	AlertsDeduped    uint64            `json:"alerts_deduped"`
+	AlertsThrottled  uint64            `json:"alerts_throttled"`
+	VerdictsAccepted uint64            `json:"verdicts_accepted"`
+	VerdictsDropped  uint64            `json:"verdicts_dropped"`
+	SyslogDropped    uint64            `json:"syslog_dropped"`
+}
+
+// CaptureTotals 汇总所有接口的抓包统计
+func (s Snapshot) CaptureTotals() CaptureStats {
+	var total CaptureStats
+	for _, cs := range s.Capture {
+		total.Received += cs.Received
+		total.Dropped += cs.Dropped
+		total.IfDropped += cs.IfDropped
+	}
+	return total
+}
+
+// Collector 周期性采集抓包与流水线统计
+type Collector struct {
+	counters     *Counters
+	captureStats CaptureStatsFunc
+	interval     time.Duration
+
+	mu     sync.RWMutex
+	latest Snapshot
+}
+
+// NewCollector 创建统计采集器，captureStats 可以为 nil (仅 Web 模式)
+func NewCollector(counters *Counters, captureStats CaptureStatsFunc, interval time.Duration) *Collector {
+	return &Collector{
+		counters:     counters,
+		captureStats: captureStats,
+		interval:     interval,
+	}
+}
+
+// Run 按采集周期运行，直到 stop 被关闭
+func (c *Collector) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.Collect()
+	for {
+		select {
+		case <-ticker.C:
+			c.Collect()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Collect 立即采集一次统计并保存为最新快照
+func (c *Collector) Collect() Snapshot {
+	snap := Snapshot{
+		Timestamp:        time.Now(),
+		PacketsProcessed: c.counters.PacketsProcessed.Load(),
+		DecodeFailures:   c.counters.DecodeFailures(),
+		FlowsCreated:     c.counters.FlowsCreated.Load(),
+		FlowsExpired:     c.counters.FlowsExpired.Load(),
+		Inferences:       c.counters.Inferences.Load(),
+		InferenceErrors:  c.counters.InferenceErrors.Load(),
+		AlertsRaised:     c.counters.AlertsRaised.Load(),
+		AlertsDeduped:    c.counters.AlertsDeduped.Load(),
+		AlertsThrottled:  c.counters.AlertsThrottled.Load(),
+		VerdictsAccepted: c.counters.VerdictsAccepted.Load(),
+		VerdictsDropped:  c.counters.VerdictsDropped.Load(),
+		SyslogDropped:    c.counters.SyslogDropped.Load(),
+	}
+	if c.captureStats != nil {
+		snap.Capture = c.captureStats()
+	}
+
+	c.mu.Lock()
+	prev := c.latest.CaptureTotals()
+	curr := snap.CaptureTotals()
+	received := curr.Received - prev.Received
+	dropped := (curr.Dropped - prev.Dropped) + (curr.IfDropped - prev.IfDropped)
+	if received > 0 && dropped > 0 {
+		snap.CaptureDropRate = float64(dropped) / float64(received)
+	}
+	c.latest = snap
+	c.mu.Unlock()
+
+	if dropped > 0 {
+		logrus.WithFields(logrus.Fields{
+			"received":  received,
+			"dropped":   dropped,
+			"drop_rate": snap.CaptureDropRate,
+		}).Warn("抓包源出现丢包，传感器可能漏检流量")
+	}
+
+	return snap
+}
+
+// Latest 返回最近一次采集的快照
+func (c *Collector) Latest() Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.latest
+}
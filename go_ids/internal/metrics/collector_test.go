@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectorDropRate(t *testing.T) {
+	counters := &Counters{}
+	capture := []CaptureStats{{Interface: "eth0", Received: 1000, Dropped: 0}}
+	c := NewCollector(counters, func() []CaptureStats { return capture }, time.Second)
+
+	c.Collect()
+
+	// 下一个周期收到 100 个包，其中 10 个被内核丢弃
+	capture = []CaptureStats{{Interface: "eth0", Received: 1100, Dropped: 10}}
+	counters.Inferences.Add(3)
+	counters.DecodeFailure(FailureNonIP)
+	counters.DecodeFailure(FailureNonIP)
+	counters.DecodeFailure("truncated")
+
+	snap := c.Collect()
+	if snap.CaptureDropRate != 0.1 {
+		t.Errorf("Expected drop rate 0.1, got %f", snap.CaptureDropRate)
+	}
+	if snap.Inferences != 3 {
+		t.Errorf("Expected 3 inferences, got %d", snap.Inferences)
+	}
+	if snap.DecodeFailures[FailureNonIP] != 2 || snap.DecodeFailures["truncated"] != 1 {
+		t.Errorf("Unexpected decode failures: %v", snap.DecodeFailures)
+	}
+	if counters.DecodeFailuresTotal() != 3 {
+		t.Errorf("Expected 3 decode failures in total, got %d", counters.DecodeFailuresTotal())
+	}
+	if latest := c.Latest(); latest.Timestamp != snap.Timestamp {
+		t.Error("Latest should return the most recent snapshot")
+	}
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Counters 记录数据包处理流水线各阶段的累计计数
+type Counters struct {
+	PacketsProcessed atomic.Uint64
+	FlowsCreated     atomic.Uint64
+	FlowsExpired     atomic.Uint64
+	Inferences       atomic.Uint64
+	InferenceErrors  atomic.Uint64
+	AlertsRaised     atomic.Uint64
+	AlertsDeduped    atomic.Uint64 // 合并到已有告警的重复事件
+	AlertsThrottled  atomic.Uint64 // 超出告警速率限制被丢弃的事件
+	VerdictsAccepted atomic.Uint64 // 内联模式放行的数据包
+	VerdictsDropped  atomic.Uint64 // 内联模式丢弃的数据包
+	SyslogDropped    atomic.Uint64 // 未能转发给 SIEM 的告警
+
+	decodeFailures sync.Map // reason -> *atomic.Uint64
+}
+
+// Pipeline 是全局流水线计数器
+var Pipeline = &Counters{}
+
+// 不属于 decoder.DecodeError 的解码失败原因
+const (
+	FailureNonIP            = "non_ip"            // 非 IP 数据包
+	FailureUnknownInterface = "unknown_interface" // 找不到入口接口对应的解码器
+)
+
+// DecodeFailure 按原因累加一次解码失败
+func (c *Counters) DecodeFailure(reason string) {
+	v, ok := c.decodeFailures.Load(reason)
+	if !ok {
+		v, _ = c.decodeFailures.LoadOrStore(reason, new(atomic.Uint64))
+	}
+	v.(*atomic.Uint64).Add(1)
+}
+
+// DecodeFailures 返回按原因分组的解码失败次数
+func (c *Counters) DecodeFailures() map[string]uint64 {
+	failures := make(map[string]uint64)
+	c.decodeFailures.Range(func(key, value any) bool {
+		failures[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return failures
+}
+
+// DecodeFailuresTotal 返回解码失败总数
+func (c *Counters) DecodeFailuresTotal() uint64 {
+	var total uint64
+	for _, n := range c.DecodeFailures() {
+		total += n
+	}
+	return total
+}
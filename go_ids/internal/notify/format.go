@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+)
+
+// jsonMessage 通用 JSON 格式
+type jsonMessage struct {
+	Event     string    `json:"event"`
+	Sensor    string    `json:"sensor"`
+	Timestamp time.Time `json:"timestamp"`
+	Alert     db.Alert  `json:"alert"`
+}
+
+// slackMessage Slack Incoming Webhook 兼容格式，Mattermost、Rocket.Chat 等同样接受
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Fields []slackField `json:"fields"`
+	Footer string       `json:"footer"`
+	Ts     int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// render 按格式渲染告警通知的请求体
+func render(format, sensor string, alert db.Alert) ([]byte, error) {
+	if format == loader.NotifierFormatSlack {
+		return json.Marshal(slackPayload(sensor, alert))
+	}
+	return json.Marshal(jsonMessage{
+		Event:     "alert",
+		Sensor:    sensor,
+		Timestamp: alert.CreatedAt,
+		Alert:     alert,
+	})
+}
+
+func slackPayload(sensor string, alert db.Alert) slackMessage {
+	color := "warning"
+	if alert.Confidence >= 0.9 {
+		color = "danger"
+	}
+	fields := []slackField{
+		{Title: "Source", Value: alert.SourceIP, Short: true},
+		{Title: "Destination", Value: alert.DestIP, Short: true},
+		{Title: "Confidence", Value: fmt.Sprintf("%.2f", alert.Confidence), Short: true},
+	}
+	if alert.Interface != "" {
+		fields = append(fields, slackField{Title: "Interface", Value: alert.Interface, Short: true})
+	}
+	if alert.IncidentID != nil {
+		fields = append(fields, slackField{Title: "Incident", Value: fmt.Sprintf("#%d", *alert.IncidentID), Short: true})
+	}
+	return slackMessage{
+		Text: fmt.Sprintf(":rotating_light: *%s* detected from %s to %s", alert.Type, alert.SourceIP, alert.DestIP),
+		Attachments: []slackAttachment{{
+			Color:  color,
+			Fields: fields,
+			Footer: "go-ids " + sensor,
+			Ts:     alert.CreatedAt.Unix(),
+		}},
+	}
+}
@@ -0,0 +1,239 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	retryBase      = time.Second     // 第一次重试的等待时间，之后每次翻倍
+	maxBackoff     = 5 * time.Minute // 重试间隔上限
+	pollInterval   = time.Second     // 发件箱轮询间隔
+	batchSize      = 100             // 每轮每个目标最多投递的消息数
+)
+
+// target 一个通知目标
+type target struct {
+	cfg    loader.NotifierConfig
+	labels map[string]bool // 为空表示全部类型
+
+	// 连续投递失败的次数与退避结束时间，由 Notifier.mu 保护
+	failures int
+	retryAt  time.Time
+}
+
+// accepts 判断告警是否满足目标的类型与置信度过滤条件
+func (t *target) accepts(alert db.Alert) bool {
+	if alert.Confidence < t.cfg.MinConfidence {
+		return false
+	}
+	return len(t.labels) == 0 || t.labels[alert.Type]
+}
+
+// Notifier 将告警渲染后写入持久化发件箱，再由 Run 投递到各 Webhook
+// 投递失败的目标按指数退避重试，消息保留在发件箱中直到接收方恢复，程序重启后继续投递
+type Notifier struct {
+	store   db.OutboxRepository
+	targets map[string]*target
+	client  *http.Client
+	sensor  string // 本机主机名，写入通知内容
+	wake    chan struct{}
+	mu      sync.Mutex // 保证同一时间只有一轮投递
+}
+
+// New 按配置创建通知器
+func New(cfgs []loader.NotifierConfig, store db.OutboxRepository) *Notifier {
+	sensor, _ := os.Hostname()
+	n := &Notifier{
+		store:   store,
+		targets: make(map[string]*target),
+		client:  &http.Client{},
+		sensor:  sensor,
+		wake:    make(chan struct{}, 1),
+	}
+	for _, cfg := range cfgs {
+		if cfg.Format == "" {
+			cfg.Format = loader.NotifierFormatJSON
+		}
+		t := &target{cfg: cfg, labels: make(map[string]bool)}
+		for _, label := range cfg.Labels {
+			t.labels[label] = true
+		}
+		n.targets[cfg.Name] = t
+	}
+	return n
+}
+
+// Notify 将告警放入所有匹配目标的发件箱，不等待投递
+func (n *Notifier) Notify(alert db.Alert) {
+	queued := false
+	for _, t := range n.targets {
+		if !t.accepts(alert) {
+			continue
+		}
+		payload, err := render(t.cfg.Format, n.sensor, alert)
+		if err != nil {
+			logrus.Errorf("渲染通知 %s 失败: %v", t.cfg.Name, err)
+			continue
+		}
+		msg := &db.OutboxMessage{
+			CreatedAt:   time.Now(),
+			Notifier:    t.cfg.Name,
+			Payload:     string(payload),
+			NextAttempt: time.Now(),
+		}
+		if err := n.store.EnqueueOutbox(msg); err != nil {
+			logrus.Errorf("写入通知发件箱失败: %v", err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run 周期性投递发件箱中的消息，直到 stop 被关闭
+func (n *Notifier) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	n.prune()
+	n.Flush(time.Now())
+	for {
+		select {
+		case <-ticker.C:
+		case <-n.wake:
+		case <-stop:
+			return
+		}
+		n.Flush(time.Now())
+	}
+}
+
+// prune 丢弃已从配置中移除的目标的消息
+func (n *Notifier) prune() {
+	names := make([]string, 0, len(n.targets))
+	for name := range n.targets {
+		names = append(names, name)
+	}
+	removed, err := n.store.DeleteOutboxExcept(names)
+	if err != nil {
+		logrus.Errorf("清理通知发件箱失败: %v", err)
+		return
+	}
+	if removed > 0 {
+		logrus.Warnf("通知目标已从配置中移除，丢弃 %d 条消息", removed)
+	}
+}
+
+// Flush 投递一轮消息，返回成功投递的数量
+// 各目标独立投递，处于退避中的目标本轮跳过，不影响其他目标
+func (n *Notifier) Flush(now time.Time) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delivered := 0
+	for _, t := range n.targets {
+		if now.Before(t.retryAt) {
+			continue
+		}
+		delivered += n.flushTarget(t, now)
+	}
+	return delivered
+}
+
+// flushTarget 按入队顺序投递目标的消息，一旦失败整个目标进入退避
+func (n *Notifier) flushTarget(t *target, now time.Time) int {
+	msgs, err := n.store.PendingOutbox(t.cfg.Name, batchSize)
+	if err != nil {
+		logrus.Errorf("读取通知发件箱失败: %v", err)
+		return 0
+	}
+
+	delivered := 0
+	for i := range msgs {
+		msg := &msgs[i]
+		if err := n.post(t, []byte(msg.Payload)); err != nil {
+			n.retry(t, msg, now, err)
+			return delivered
+		}
+		if t.failures > 0 {
+			logrus.Infof("通知目标 %s 已恢复，连续失败 %d 次", t.cfg.Name, t.failures)
+			t.failures = 0
+		}
+		if err := n.store.DeleteOutbox(msg.ID); err != nil {
+			logrus.Errorf("删除已投递通知失败: %v", err)
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// retry 记录一次失败的投递，目标在退避结束前不再投递，消息继续保留
+func (n *Notifier) retry(t *target, msg *db.OutboxMessage, now time.Time, cause error) {
+	t.failures++
+	t.retryAt = now.Add(backoff(t.failures))
+
+	msg.Attempts++
+	msg.LastError = cause.Error()
+	msg.NextAttempt = t.retryAt
+	logrus.Warnf("通知 %s 投递失败 (连续 %d 次)，%s 后重试: %v", t.cfg.Name, t.failures, t.retryAt.Sub(now), cause)
+	if err := n.store.SaveOutbox(msg); err != nil {
+		logrus.Errorf("更新通知发件箱失败: %v", err)
+	}
+}
+
+// backoff 返回第 attempts 次失败后的等待时间
+func backoff(attempts int) time.Duration {
+	d := retryBase
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// post 发送一次请求，2xx 视为成功
+func (n *Notifier) post(t *target, payload []byte) error {
+	timeout := time.Duration(t.cfg.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
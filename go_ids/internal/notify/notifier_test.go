@@ -0,0 +1,239 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+)
+
+// receiver 记录收到的请求，down 为 true 时返回 503
+type receiver struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	header http.Header
+	down   bool
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	r.bodies = append(r.bodies, body)
+	r.header = req.Header.Clone()
+}
+
+func (r *receiver) setDown(down bool) {
+	r.mu.Lock()
+	r.down = down
+	r.mu.Unlock()
+}
+
+func (r *receiver) received() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bodies
+}
+
+func initTestDB(t *testing.T) *db.Store {
+	t.Helper()
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func outboxLen(t *testing.T, store *db.Store, notifiers ...string) int {
+	t.Helper()
+	total := 0
+	for _, name := range notifiers {
+		msgs, err := store.PendingOutbox(name, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(msgs)
+	}
+	return total
+}
+
+func testAlert(label string, confidence float32) db.Alert {
+	return db.Alert{
+		ID:         7,
+		CreatedAt:  time.Now(),
+		SourceIP:   "192.0.2.1",
+		DestIP:     "10.0.0.1",
+		Type:       label,
+		Confidence: confidence,
+	}
+}
+
+func TestNotifyFilterAndFormats(t *testing.T) {
+	store := initTestDB(t)
+	generic, slack := &receiver{}, &receiver{}
+	genericSrv, slackSrv := httptest.NewServer(generic), httptest.NewServer(slack)
+	defer genericSrv.Close()
+	defer slackSrv.Close()
+
+	n := New([]loader.NotifierConfig{
+		{Name: "siem", URL: genericSrv.URL, Headers: map[string]string{"Authorization": "Bearer t"}},
+		{Name: "chat", URL: slackSrv.URL, Format: loader.NotifierFormatSlack, Labels: []string{"Bot"}, MinConfidence: 0.9},
+	}, store)
+	n.Notify(testAlert("PortScan", 0.95)) // 只发往 siem
+	n.Notify(testAlert("Bot", 0.8))       // 置信度不足，只发往 siem
+	n.Notify(testAlert("Bot", 0.97))      // 两个目标都发送
+
+	if got := n.Flush(time.Now()); got != 4 {
+		t.Fatalf("delivered %d notifications, want 4", got)
+	}
+	if outboxLen(t, store, "siem", "chat") != 0 {
+		t.Error("outbox not drained")
+	}
+
+	var msg jsonMessage
+	if err := json.Unmarshal(generic.received()[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Event != "alert" || msg.Alert.Type != "PortScan" || msg.Alert.SourceIP != "192.0.2.1" {
+		t.Errorf("unexpected generic payload: %+v", msg)
+	}
+	if generic.header.Get("Authorization") != "Bearer t" || generic.header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", generic.header)
+	}
+
+	if len(slack.received()) != 1 {
+		t.Fatalf("slack target received %d messages, want 1", len(slack.received()))
+	}
+	var sm slackMessage
+	if err := json.Unmarshal(slack.received()[0], &sm); err != nil {
+		t.Fatal(err)
+	}
+	if sm.Text == "" || len(sm.Attachments) != 1 || sm.Attachments[0].Color != "danger" {
+		t.Errorf("unexpected slack payload: %+v", sm)
+	}
+}
+
+func TestNotifyRetryAndOutbox(t *testing.T) {
+	store := initTestDB(t)
+	recv := &receiver{down: true}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	cfg := []loader.NotifierConfig{{Name: "hook", URL: srv.URL}}
+	n := New(cfg, store)
+	n.Notify(testAlert("DoS", 0.99))
+	n.Notify(testAlert("DoS", 0.98))
+
+	now := time.Now()
+	if got := n.Flush(now); got != 0 {
+		t.Fatalf("delivered %d while receiver is down", got)
+	}
+	msgs, err := store.PendingOutbox("hook", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 首条失败后本轮跳过同一目标的其余消息
+	if len(msgs) != 2 || msgs[0].Attempts != 1 || msgs[1].Attempts != 0 {
+		t.Fatalf("unexpected outbox: %+v", msgs)
+	}
+	if msgs[0].LastError == "" || !msgs[0].NextAttempt.Equal(now.Add(retryBase)) {
+		t.Errorf("retry not scheduled: %+v", msgs[0])
+	}
+
+	// 退避结束前不再投递该目标
+	recv.setDown(false)
+	if got := n.Flush(now); got != 0 || len(recv.received()) != 0 {
+		t.Fatalf("delivered %d during backoff", got)
+	}
+
+	// 接收方恢复后由新的通知器 (模拟重启) 投递发件箱中的消息
+	n2 := New(cfg, store)
+	if got := n2.Flush(now.Add(time.Minute)); got != 2 {
+		t.Fatalf("delivered %d after recovery, want 2", got)
+	}
+	if outboxLen(t, store, "hook") != 0 {
+		t.Error("outbox not drained after recovery")
+	}
+}
+
+func TestNotifyTargetBackoff(t *testing.T) {
+	store := initTestDB(t)
+	down, up := &receiver{down: true}, &receiver{}
+	downSrv, upSrv := httptest.NewServer(down), httptest.NewServer(up)
+	defer downSrv.Close()
+	defer upSrv.Close()
+
+	n := New([]loader.NotifierConfig{
+		{Name: "down", URL: downSrv.URL, Labels: []string{"Bot"}},
+		{Name: "up", URL: upSrv.URL, Labels: []string{"DoS"}},
+	}, store)
+	// 故障目标的消息更早入队，也不能阻塞正常目标
+	for i := 0; i < batchSize+1; i++ {
+		n.Notify(testAlert("Bot", 0.99))
+	}
+	n.Notify(testAlert("DoS", 0.99))
+
+	// 长时间故障期间消息一直保留，退避间隔按目标增长
+	now := time.Now()
+	for i := 0; i < 30; i++ {
+		n.Flush(now)
+		now = now.Add(maxBackoff)
+	}
+	if len(up.received()) != 1 {
+		t.Errorf("healthy target received %d messages, want 1", len(up.received()))
+	}
+	if got := outboxLen(t, store, "down"); got != batchSize+1 {
+		t.Fatalf("outbox kept %d messages during the outage, want %d", got, batchSize+1)
+	}
+	if n.targets["down"].failures != 30 || !n.targets["down"].retryAt.Equal(now) {
+		t.Errorf("unexpected backoff: %d failures, retry at %v", n.targets["down"].failures, n.targets["down"].retryAt)
+	}
+
+	down.setDown(false)
+	if got := n.Flush(now); got != batchSize {
+		t.Errorf("delivered %d after recovery, want %d", got, batchSize)
+	}
+	if got := n.Flush(now); got != 1 || n.targets["down"].failures != 0 {
+		t.Errorf("delivered %d in the second round, want 1", got)
+	}
+}
+
+func TestNotifyPruneRemovedTarget(t *testing.T) {
+	store := initTestDB(t)
+	srv := httptest.NewServer(&receiver{})
+	defer srv.Close()
+
+	if err := store.EnqueueOutbox(&db.OutboxMessage{Notifier: "removed", Payload: "{}", NextAttempt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	n := New([]loader.NotifierConfig{{Name: "hook", URL: srv.URL}}, store)
+	n.Notify(testAlert("Bot", 0.99))
+
+	stop := make(chan struct{})
+	close(stop)
+	n.Run(stop)
+	if outboxLen(t, store, "removed", "hook") != 0 {
+		t.Error("expected removed target messages to be dropped and the rest delivered")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 5: 16 * time.Second, 30: maxBackoff}
+	for attempts, want := range cases {
+		if got := backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+)
+
+// 写入 CEF/LEEF 头部的产品信息
+const (
+	deviceVendor  = "go-ids"
+	deviceProduct = "go-ids"
+	deviceVersion = "1.0"
+)
+
+// formatSIEM 按 syslog.format 渲染告警消息体，载荷最多保留 payloadLimit 字节
+func formatSIEM(format string, alert db.Alert, payloadLimit int) ([]byte, error) {
+	payload := excerpt(alert.Payload, payloadLimit)
+	switch format {
+	case loader.SyslogFormatCEF:
+		return []byte(formatCEF(alert, payload)), nil
+	case loader.SyslogFormatLEEF:
+		return []byte(formatLEEF(alert, payload)), nil
+	case loader.SyslogFormatJSON:
+		return json.Marshal(siemJSON{
+			Timestamp:  alert.CreatedAt,
+			ID:         alert.ID,
+			SourceIP:   alert.SourceIP,
+			DestIP:     alert.DestIP,
+			Type:       alert.Type,
+			Confidence: alert.Confidence,
+			Count:      alert.Count,
+			Interface:  alert.Interface,
+			IncidentID: alert.IncidentID,
+			Payload:    payload,
+		})
+	}
+	return nil, fmt.Errorf("不支持的 syslog 消息格式: %s", format)
+}
+
+// siemJSON 是 json 格式的消息体，载荷为截断后的摘录
+type siemJSON struct {
+	Timestamp  time.Time `json:"timestamp"`
+	ID         uint      `json:"id"`
+	SourceIP   string    `json:"source_ip"`
+	DestIP     string    `json:"dest_ip"`
+	Type       string    `json:"type"`
+	Confidence float32   `json:"confidence"`
+	Count      int       `json:"count"`
+	Interface  string    `json:"interface,omitempty"`
+	IncidentID *uint     `json:"incident_id,omitempty"`
+	Payload    string    `json:"payload,omitempty"`
+}
+
+// excerpt 截取载荷前 limit 字节，不拆分 UTF-8 字符，控制字符替换为 '.'
+func excerpt(payload string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	payload = strings.ToValidUTF8(payload, ".")
+	if len(payload) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(payload[cut]) {
+			cut--
+		}
+		payload = payload[:cut]
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '.'
+		}
+		return r
+	}, payload)
+}
+
+// severity10 将置信度映射为 CEF/LEEF 的 0-10 严重程度
+func severity10(confidence float32) int {
+	return int(math.Round(math.Max(0, math.Min(1, float64(confidence))) * 10))
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// formatCEF 渲染 ArcSight CEF:0 消息
+func formatCEF(alert db.Alert, payload string) string {
+	ext := [][2]string{
+		{"rt", strconv.FormatInt(alert.CreatedAt.UnixMilli(), 10)},
+		{"src", alert.SourceIP},
+		{"dst", alert.DestIP},
+		{"cat", alert.Type},
+		{"cnt", strconv.Itoa(max(alert.Count, 1))},
+		{"cfp1", strconv.FormatFloat(float64(alert.Confidence), 'f', 4, 32)},
+		{"cfp1Label", "Confidence"},
+		{"externalId", strconv.FormatUint(uint64(alert.ID), 10)},
+	}
+	if alert.Interface != "" {
+		ext = append(ext, [2]string{"deviceInboundInterface", alert.Interface})
+	}
+	if alert.IncidentID != nil {
+		ext = append(ext,
+			[2]string{"cs1", strconv.FormatUint(uint64(*alert.IncidentID), 10)},
+			[2]string{"cs1Label", "IncidentID"})
+	}
+	if payload != "" {
+		ext = append(ext, [2]string{"msg", payload})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeaderEscaper.Replace(deviceVendor),
+		cefHeaderEscaper.Replace(deviceProduct),
+		cefHeaderEscaper.Replace(deviceVersion),
+		cefHeaderEscaper.Replace(alert.Type),
+		cefHeaderEscaper.Replace(alert.Type+" detected"),
+		severity10(alert.Confidence))
+	for i, kv := range ext {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(kv[0])
+		b.WriteByte('=')
+		b.WriteString(cefExtensionEscaper.Replace(kv[1]))
+	}
+	return b.String()
+}
+
+// leefDelimiter LEEF 2.0 属性分隔符，在头部声明
+const leefDelimiter = "^"
+
+// leefTimeFormat devTime 的格式，同时以 Java SimpleDateFormat 形式写入 devTimeFormat
+const (
+	leefTimeLayout = "Jan 02 2006 15:04:05.000 MST"
+	leefTimeFormat = "MMM dd yyyy HH:mm:ss.SSS z"
+)
+
+var (
+	leefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	leefValueEscaper  = strings.NewReplacer(`\`, `\\`, leefDelimiter, `\`+leefDelimiter, "\n", " ", "\r", " ", "\t", " ")
+)
+
+// formatLEEF 渲染 IBM QRadar LEEF:2.0 消息
+func formatLEEF(alert db.Alert, payload string) string {
+	attrs := [][2]string{
+		{"devTime", alert.CreatedAt.UTC().Format(leefTimeLayout)},
+		{"devTimeFormat", leefTimeFormat},
+		{"cat", alert.Type},
+		{"src", alert.SourceIP},
+		{"dst", alert.DestIP},
+		{"sev", strconv.Itoa(max(severity10(alert.Confidence), 1))},
+		{"confidence", strconv.FormatFloat(float64(alert.Confidence), 'f', 4, 32)},
+		{"eventCount", strconv.Itoa(max(alert.Count, 1))},
+		{"alertId", strconv.FormatUint(uint64(alert.ID), 10)},
+	}
+	if alert.Interface != "" {
+		attrs = append(attrs, [2]string{"iface", alert.Interface})
+	}
+	if alert.IncidentID != nil {
+		attrs = append(attrs, [2]string{"incidentId", strconv.FormatUint(uint64(*alert.IncidentID), 10)})
+	}
+	if payload != "" {
+		attrs = append(attrs, [2]string{"payload", payload})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:2.0|%s|%s|%s|%s|%s|",
+		leefHeaderEscaper.Replace(deviceVendor),
+		leefHeaderEscaper.Replace(deviceProduct),
+		leefHeaderEscaper.Replace(deviceVersion),
+		leefHeaderEscaper.Replace(alert.Type),
+		leefDelimiter)
+	for i, kv := range attrs {
+		if i > 0 {
+			b.WriteString(leefDelimiter)
+		}
+		b.WriteString(kv[0])
+		b.WriteByte('=')
+		b.WriteString(leefValueEscaper.Replace(kv[1]))
+	}
+	return b.String()
+}
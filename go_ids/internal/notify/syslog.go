@@ -0,0 +1,265 @@
+package notify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+	"go-ids/internal/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	syslogQueueSize    = 1024
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+	syslogTimeLayout   = "2006-01-02T15:04:05.000000Z07:00" // RFC 5424 最多 6 位小数
+)
+
+// 告警对应的 syslog 严重级别
+const (
+	syslogSevCritical = 2
+	syslogSevWarning  = 4
+)
+
+// syslogFacilities facility 名称到编号的映射 (RFC 5424 表 1)
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14, "solaris-cron": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogForwarder 以 RFC 5424 syslog 向 SIEM 转发告警
+// 发送在独立协程中进行，接收方不可用时消息留在队列中等待重连，队列满后丢弃并计数，不阻塞检测流程
+type SyslogForwarder struct {
+	cfg       loader.SyslogConfig
+	facility  int
+	hostname  string
+	procID    string
+	tlsConfig *tls.Config
+	queue     chan []byte
+
+	conn     net.Conn
+	failures int       // 连续连接失败次数
+	retryAt  time.Time // 在此之前不再尝试连接
+	dropped  atomic.Uint64
+}
+
+// NewSyslogForwarder 按配置创建转发器，TLS 证书在此时加载
+func NewSyslogForwarder(cfg loader.SyslogConfig) (*SyslogForwarder, error) {
+	facility, ok := syslogFacilities[strings.ToLower(cfg.Facility)]
+	if !ok {
+		return nil, fmt.Errorf("未知的 syslog facility: %s", cfg.Facility)
+	}
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	f := &SyslogForwarder{
+		cfg:      cfg,
+		facility: facility,
+		hostname: headerField(hostname, 255),
+		procID:   fmt.Sprint(os.Getpid()),
+		queue:    make(chan []byte, syslogQueueSize),
+	}
+
+	if cfg.Network == loader.SyslogNetworkTLS {
+		tlsConfig, err := syslogTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		f.tlsConfig = tlsConfig
+	}
+	return f, nil
+}
+
+func syslogTLSConfig(cfg loader.SyslogConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLS.ServerName,
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+	}
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(cfg.Address)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 syslog 地址 %s: %v", cfg.Address, err)
+		}
+		tlsConfig.ServerName = host
+	}
+	if cfg.TLS.CAFile != "" {
+		pem, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 syslog CA 证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("syslog CA 文件 %s 中没有有效证书", cfg.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载 syslog 客户端证书失败: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Forward 渲染告警并放入发送队列，队列已满时丢弃
+func (f *SyslogForwarder) Forward(alert db.Alert) {
+	body, err := formatSIEM(f.cfg.Format, alert, f.cfg.PayloadLimit)
+	if err != nil {
+		logrus.Errorf("渲染 syslog 消息失败: %v", err)
+		return
+	}
+	select {
+	case f.queue <- f.message(alert, body):
+	default:
+		f.drop("发送队列已满")
+	}
+}
+
+// Dropped 返回被丢弃的消息数
+func (f *SyslogForwarder) Dropped() uint64 {
+	return f.dropped.Load()
+}
+
+func (f *SyslogForwarder) drop(reason string) {
+	metrics.Pipeline.SyslogDropped.Add(1)
+	// 接收方长时间不可用时只周期性记录日志
+	if n := f.dropped.Add(1); n%100 == 1 {
+		logrus.Warnf("syslog 消息被丢弃 (%s)，累计 %d 条", reason, n)
+	}
+}
+
+// message 生成 RFC 5424 消息: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func (f *SyslogForwarder) message(alert db.Alert, body []byte) []byte {
+	severity := syslogSevWarning
+	if alert.Confidence >= 0.9 {
+		severity = syslogSevCritical
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %s alert - ",
+		f.facility*8+severity,
+		alert.CreatedAt.Format(syslogTimeLayout),
+		f.hostname,
+		headerField(f.cfg.AppName, 48),
+		f.procID)
+	return append([]byte(header), body...)
+}
+
+// headerField 将字段限制为可打印 ASCII 且不含空格，空值写为 "-"
+func headerField(s string, maxLen int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Run 发送队列中的消息，直到 stop 被关闭
+// 等待重连期间不再从队列取消息，发送失败的消息在退避结束后重发
+func (f *SyslogForwarder) Run(stop <-chan struct{}) {
+	defer f.close()
+	var held []byte
+	for {
+		if held == nil {
+			select {
+			case held = <-f.queue:
+			case <-stop:
+				return
+			}
+		} else {
+			select {
+			case <-time.After(time.Until(f.retryAt)):
+			case <-stop:
+				return
+			}
+		}
+
+		now := time.Now()
+		err := f.send(held, now)
+		switch {
+		case err == nil:
+			held = nil
+		case f.conn == nil && now.Before(f.retryAt):
+			// 连接失败，保留消息等待重连
+		default:
+			f.drop(err.Error())
+			held = nil
+		}
+	}
+}
+
+// send 发送一条消息，连接断开时重连一次；连接失败后按退避等待
+func (f *SyslogForwarder) send(msg []byte, now time.Time) error {
+	if f.cfg.Network != loader.SyslogNetworkUDP {
+		// RFC 6587/5425 octet-counting 分帧
+		msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	}
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if f.conn == nil {
+			if now.Before(f.retryAt) {
+				return fmt.Errorf("等待重连 %s", f.cfg.Address)
+			}
+			if err = f.connect(); err != nil {
+				f.failures++
+				f.retryAt = now.Add(backoff(f.failures))
+				return err
+			}
+		}
+		f.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+		if _, err = f.conn.Write(msg); err == nil {
+			return nil
+		}
+		f.close()
+	}
+	return err
+}
+
+func (f *SyslogForwarder) connect() error {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	var conn net.Conn
+	var err error
+	switch f.cfg.Network {
+	case loader.SyslogNetworkTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", f.cfg.Address, f.tlsConfig)
+	default:
+		conn, err = dialer.Dial(f.cfg.Network, f.cfg.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 syslog 服务器 %s 失败: %v", f.cfg.Address, err)
+	}
+	if f.failures > 0 {
+		logrus.Infof("已重新连接 syslog 服务器 %s", f.cfg.Address)
+	}
+	f.conn = conn
+	f.failures = 0
+	return nil
+}
+
+func (f *SyslogForwarder) close() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+}
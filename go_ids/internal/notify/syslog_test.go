@@ -0,0 +1,327 @@
+package notify
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+)
+
+func siemAlert() db.Alert {
+	incident := uint(3)
+	return db.Alert{
+		ID:         42,
+		CreatedAt:  time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC),
+		SourceIP:   "192.0.2.1",
+		DestIP:     "10.0.0.1",
+		Type:       "Web|Attack",
+		Confidence: 0.93,
+		Count:      2,
+		Interface:  "eth0",
+		IncidentID: &incident,
+		Payload:    "GET /?q=a=b HTTP/1.1\r\nHost: x^y",
+	}
+}
+
+func TestFormatCEF(t *testing.T) {
+	got := formatCEF(siemAlert(), excerpt(siemAlert().Payload, 256))
+	want := `CEF:0|go-ids|go-ids|1.0|Web\|Attack|Web\|Attack detected|9|` +
+		`rt=1714566600123 src=192.0.2.1 dst=10.0.0.1 cat=Web|Attack cnt=2 cfp1=0.9300 cfp1Label=Confidence ` +
+		`externalId=42 deviceInboundInterface=eth0 cs1=3 cs1Label=IncidentID msg=GET /?q\=a\=b HTTP/1.1..Host: x^y`
+	if got != want {
+		t.Errorf("CEF:\n got  %s\n want %s", got, want)
+	}
+}
+
+func TestFormatLEEF(t *testing.T) {
+	got := formatLEEF(siemAlert(), excerpt(siemAlert().Payload, 256))
+	want := `LEEF:2.0|go-ids|go-ids|1.0|Web\|Attack|^|` +
+		`devTime=May 01 2024 12:30:00.123 UTC^devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z^cat=Web|Attack^` +
+		`src=192.0.2.1^dst=10.0.0.1^sev=9^confidence=0.9300^eventCount=2^alertId=42^iface=eth0^incidentId=3^` +
+		`payload=GET /?q=a=b HTTP/1.1..Host: x\^y`
+	if got != want {
+		t.Errorf("LEEF:\n got  %s\n want %s", got, want)
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	body, err := formatSIEM(loader.SyslogFormatJSON, siemAlert(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg siemJSON
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID != 42 || msg.Payload != "GET " || *msg.IncidentID != 3 {
+		t.Errorf("unexpected JSON body: %s", body)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	cases := []struct {
+		in    string
+		limit int
+		want  string
+	}{
+		{"abc", 0, ""},
+		{"abcdef", 3, "abc"},
+		{"a\x00b\tc", 10, "a.b.c"},
+		{"攻击载荷", 4, "攻"}, // 不拆分多字节字符
+		{"\xff\xfeok", 10, ".ok"},
+	}
+	for _, tc := range cases {
+		if got := excerpt(tc.in, tc.limit); got != tc.want {
+			t.Errorf("excerpt(%q, %d) = %q, want %q", tc.in, tc.limit, got, tc.want)
+		}
+	}
+}
+
+// rfc5424 匹配转发器生成的头部
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 2024-05-01T12:30:00\.123456Z sensor-1 go-ids \d+ alert - (.*)$`)
+
+func newTestForwarder(t *testing.T, cfg loader.SyslogConfig) *SyslogForwarder {
+	t.Helper()
+	cfg.Facility = "local4"
+	cfg.AppName = "go-ids"
+	cfg.Hostname = "sensor-1"
+	cfg.PayloadLimit = 16
+	if cfg.Format == "" {
+		cfg.Format = loader.SyslogFormatCEF
+	}
+	f, err := NewSyslogForwarder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	f := newTestForwarder(t, loader.SyslogConfig{Network: loader.SyslogNetworkUDP, Address: pc.LocalAddr().String()})
+	defer f.close()
+	alert := siemAlert()
+	body, _ := formatSIEM(f.cfg.Format, alert, f.cfg.PayloadLimit)
+	if err := f.send(f.message(alert, body), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := rfc5424.FindStringSubmatch(string(buf[:n]))
+	if m == nil {
+		t.Fatalf("not an RFC 5424 message: %q", buf[:n])
+	}
+	// local4 (20) * 8 + critical (2)
+	if m[1] != "162" || !strings.HasPrefix(m[2], "CEF:0|") {
+		t.Errorf("unexpected message: %q", buf[:n])
+	}
+}
+
+// readFramed 读取一条 octet-counting 分帧的消息
+func readFramed(r *bufio.Reader) (string, error) {
+	size, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(size))
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// serveOne 接受一个连接并读取最多两条消息
+func serveOne(ln net.Listener) <-chan string {
+	msgs := make(chan string, 2)
+	go func() {
+		defer close(msgs)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		r := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			msg, err := readFramed(r)
+			if err != nil {
+				return
+			}
+			msgs <- msg
+		}
+	}()
+	return msgs
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := serveOne(ln)
+
+	f := newTestForwarder(t, loader.SyslogConfig{
+		Network: loader.SyslogNetworkTCP,
+		Address: ln.Addr().String(),
+		Format:  loader.SyslogFormatLEEF,
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go f.Run(stop)
+
+	f.Forward(siemAlert())
+	low := siemAlert()
+	low.Confidence = 0.7
+	f.Forward(low)
+
+	for _, pri := range []string{"162", "164"} {
+		select {
+		case msg := <-msgs:
+			m := rfc5424.FindStringSubmatch(msg)
+			if m == nil || m[1] != pri || !strings.HasPrefix(m[2], "LEEF:2.0|") {
+				t.Errorf("unexpected message: %q", msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for syslog message")
+		}
+	}
+}
+
+func TestSyslogTLS(t *testing.T) {
+	// 借用 httptest 的自签名证书 (对 example.com 和 127.0.0.1 有效)
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := serveOne(ln)
+
+	f := newTestForwarder(t, loader.SyslogConfig{
+		Network: loader.SyslogNetworkTLS,
+		Address: ln.Addr().String(),
+		Format:  loader.SyslogFormatJSON,
+		TLS:     loader.SyslogTLSConfig{CAFile: caFile, ServerName: "example.com"},
+	})
+	defer f.close()
+	alert := siemAlert()
+	body, _ := formatSIEM(f.cfg.Format, alert, f.cfg.PayloadLimit)
+	if err := f.send(f.message(alert, body), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-msgs:
+		if m := rfc5424.FindStringSubmatch(msg); m == nil || !strings.HasPrefix(m[2], `{"timestamp"`) {
+			t.Errorf("unexpected message: %q", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for syslog message")
+	}
+}
+
+func TestSyslogUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	f := newTestForwarder(t, loader.SyslogConfig{Network: loader.SyslogNetworkTCP, Address: addr})
+	now := time.Now()
+	if err := f.send([]byte("x"), now); err == nil {
+		t.Fatal("expected connection error")
+	}
+	// 退避期间不再尝试连接
+	if err := f.send([]byte("x"), now.Add(100*time.Millisecond)); err == nil || f.failures != 1 {
+		t.Errorf("expected send to wait for backoff, failures=%d err=%v", f.failures, err)
+	}
+
+	if _, err := NewSyslogForwarder(loader.SyslogConfig{Facility: "local9"}); err == nil {
+		t.Error("expected error for unknown facility")
+	}
+}
+
+func TestSyslogHoldsQueueDuringBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	f := newTestForwarder(t, loader.SyslogConfig{Network: loader.SyslogNetworkTCP, Address: addr})
+	// 队列满后才丢弃
+	for i := 0; i < syslogQueueSize+1; i++ {
+		f.Forward(siemAlert())
+	}
+	if f.Dropped() != 1 {
+		t.Fatalf("dropped %d messages, want 1", f.Dropped())
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go f.Run(stop)
+	// 等待重连期间消息留在队列中
+	time.Sleep(200 * time.Millisecond)
+	if f.Dropped() != 1 {
+		t.Fatalf("dropped %d messages while waiting to reconnect, want 1", f.Dropped())
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s again: %v", addr, err)
+	}
+	defer ln.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for i := 0; i < syslogQueueSize; i++ {
+		if _, err := readFramed(r); err != nil {
+			t.Fatalf("received %d messages after reconnect, want %d: %v", i, syslogQueueSize, err)
+		}
+	}
+	if f.Dropped() != 1 {
+		t.Errorf("dropped %d messages, want 1", f.Dropped())
+	}
+}
@@ -0,0 +1,125 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"go-ids/internal/loader"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Blocker 是防火墙封禁后端
+type Blocker interface {
+	// Name 返回后端名称
+	Name() string
+	// Init 创建后端需要的表、集合与规则，可重复调用
+	Init() error
+	// Block 封禁源 IP
+	Block(ip netip.Addr) error
+	// Unblock 解除源 IP 封禁
+	Unblock(ip netip.Addr) error
+}
+
+// CommandRunner 执行外部防火墙命令，测试中可替换为假实现
+type CommandRunner interface {
+	Run(name string, args ...string) error
+}
+
+// ExecRunner 通过 os/exec 执行命令
+type ExecRunner struct{}
+
+// Run 执行命令，失败时错误中附带命令输出
+func (ExecRunner) Run(name string, args ...string) error {
+	var out bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// NewBlocker 按配置创建防火墙后端
+// backend 为 auto 时 Linux 使用 iptables、Windows 使用 netsh，其他系统退化为 dry-run
+func NewBlocker(cfg loader.FirewallConfig, runner CommandRunner) (Blocker, error) {
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	if len(cfg.Chains) == 0 {
+		// 旧配置没有 firewall 段，保持原来只封禁 INPUT 的行为
+		cfg.Chains = []string{"INPUT"}
+	}
+
+	backend := cfg.Backend
+	if backend == "" || backend == loader.FirewallBackendAuto {
+		switch runtime.GOOS {
+		case "linux":
+			backend = loader.FirewallBackendIptables
+		case "windows":
+			backend = loader.FirewallBackendNetsh
+		default:
+			logrus.Warnf("当前系统 %s 不支持自动封禁功能，仅记录日志", runtime.GOOS)
+			backend = loader.FirewallBackendDryRun
+		}
+	}
+
+	switch backend {
+	case loader.FirewallBackendNftables:
+		return newNftablesBlocker(cfg, runner)
+	case loader.FirewallBackendIpset:
+		return &ipsetBlocker{set: cfg.Set, chains: cfg.Chains, runner: runner}, nil
+	case loader.FirewallBackendIptables:
+		return &iptablesBlocker{chains: cfg.Chains, runner: runner}, nil
+	case loader.FirewallBackendNetsh:
+		return &netshBlocker{runner: runner}, nil
+	case loader.FirewallBackendDryRun:
+		return dryRunBlocker{}, nil
+	}
+	return nil, fmt.Errorf("不支持的防火墙后端: %s", backend)
+}
+
+// set6 返回 IPv6 集合名，IPv4 与 IPv6 地址分别存放在两个集合中
+func set6(set string) string {
+	return set + "6"
+}
+
+// dryRunBlocker 只记录日志，不修改防火墙
+type dryRunBlocker struct{}
+
+func (dryRunBlocker) Name() string { return loader.FirewallBackendDryRun }
+
+func (dryRunBlocker) Init() error { return nil }
+
+func (dryRunBlocker) Block(ip netip.Addr) error {
+	logrus.Warnf("[dry-run] 封禁 IP: %s", ip)
+	return nil
+}
+
+func (dryRunBlocker) Unblock(ip netip.Addr) error {
+	logrus.Infof("[dry-run] 解除封禁 IP: %s", ip)
+	return nil
+}
+
+// netshBlocker 通过 Windows 防火墙为每个 IP 添加一条入站阻止规则
+type netshBlocker struct {
+	runner CommandRunner
+}
+
+func (b *netshBlocker) Name() string { return loader.FirewallBackendNetsh }
+
+func (b *netshBlocker) Init() error { return nil }
+
+func (b *netshBlocker) Block(ip netip.Addr) error {
+	return b.runner.Run("netsh", "advfirewall", "firewall", "add", "rule",
+		"name=IDS_BLOCK_"+ip.String(), "dir=in", "action=block", "remoteip="+ip.String())
+}
+
+func (b *netshBlocker) Unblock(ip netip.Addr) error {
+	return b.runner.Run("netsh", "advfirewall", "firewall", "delete", "rule", "name=IDS_BLOCK_"+ip.String())
+}
@@ -0,0 +1,187 @@
+package response
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-ids/internal/loader"
+)
+
+// fakeRunner 记录执行的命令，fail 中的命令前缀返回错误
+type fakeRunner struct {
+	cmds []string
+	fail map[string]bool
+}
+
+func (f *fakeRunner) Run(name string, args ...string) error {
+	cmd := name + " " + strings.Join(args, " ")
+	f.cmds = append(f.cmds, cmd)
+	for prefix := range f.fail {
+		if strings.HasPrefix(cmd, prefix) {
+			return errors.New("exit status 1")
+		}
+	}
+	return nil
+}
+
+func newTestBlocker(t *testing.T, cfg loader.FirewallConfig, runner CommandRunner) Blocker {
+	t.Helper()
+	b, err := NewBlocker(cfg, runner)
+	if err != nil {
+		t.Fatalf("NewBlocker(%s): %v", cfg.Backend, err)
+	}
+	return b
+}
+
+func checkCmds(t *testing.T, step string, got, want []string) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s commands:\n got  %q\n want %q", step, got, want)
+	}
+}
+
+func TestNftablesBlocker(t *testing.T) {
+	runner := &fakeRunner{}
+	b := newTestBlocker(t, loader.FirewallConfig{
+		Backend: loader.FirewallBackendNftables,
+		Table:   "go_ids",
+		Chains:  []string{"INPUT", "forward"},
+		Set:     "bl",
+	}, runner)
+
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	checkCmds(t, "init", runner.cmds, []string{
+		"nft add table inet go_ids",
+		"nft add set inet go_ids bl { type ipv4_addr; }",
+		"nft add set inet go_ids bl6 { type ipv6_addr; }",
+		"nft add chain inet go_ids input { type filter hook input priority -10; policy accept; }",
+		"nft flush chain inet go_ids input",
+		"nft add rule inet go_ids input ip saddr @bl drop",
+		"nft add rule inet go_ids input ip6 saddr @bl6 drop",
+		"nft add chain inet go_ids forward { type filter hook forward priority -10; policy accept; }",
+		"nft flush chain inet go_ids forward",
+		"nft add rule inet go_ids forward ip saddr @bl drop",
+		"nft add rule inet go_ids forward ip6 saddr @bl6 drop",
+	})
+
+	runner.cmds = nil
+	b.Block(netip.MustParseAddr("203.0.113.7"))
+	b.Block(netip.MustParseAddr("2001:db8::1"))
+	b.Unblock(netip.MustParseAddr("203.0.113.7"))
+	checkCmds(t, "block", runner.cmds, []string{
+		"nft add element inet go_ids bl { 203.0.113.7 }",
+		"nft add element inet go_ids bl6 { 2001:db8::1 }",
+		"nft delete element inet go_ids bl { 203.0.113.7 }",
+	})
+
+	if _, err := NewBlocker(loader.FirewallConfig{
+		Backend: loader.FirewallBackendNftables, Table: "t", Set: "s", Chains: []string{"DOCKER-USER"},
+	}, runner); err == nil {
+		t.Error("expected error for unsupported nftables chain")
+	}
+}
+
+func TestIpsetBlocker(t *testing.T) {
+	// -C 失败表示规则不存在，需要插入
+	runner := &fakeRunner{fail: map[string]bool{"ip6tables -C": true}}
+	b := newTestBlocker(t, loader.FirewallConfig{
+		Backend: loader.FirewallBackendIpset,
+		Chains:  []string{"FORWARD"},
+		Set:     "bl",
+	}, runner)
+
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	checkCmds(t, "init", runner.cmds, []string{
+		"ipset create bl hash:ip family inet -exist",
+		"ipset create bl6 hash:ip family inet6 -exist",
+		"iptables -C FORWARD -m set --match-set bl src -j DROP",
+		"ip6tables -C FORWARD -m set --match-set bl6 src -j DROP",
+		"ip6tables -I FORWARD -m set --match-set bl6 src -j DROP",
+	})
+
+	runner.cmds = nil
+	b.Block(netip.MustParseAddr("198.51.100.1"))
+	b.Unblock(netip.MustParseAddr("2001:db8::2"))
+	checkCmds(t, "block", runner.cmds, []string{
+		"ipset add bl 198.51.100.1 -exist",
+		"ipset del bl6 2001:db8::2 -exist",
+	})
+}
+
+func TestIptablesBlocker(t *testing.T) {
+	runner := &fakeRunner{fail: map[string]bool{"iptables -C INPUT": true, "ip6tables -C": true}}
+	b := newTestBlocker(t, loader.FirewallConfig{
+		Backend: loader.FirewallBackendIptables,
+		Chains:  []string{"INPUT", "FORWARD"},
+	}, runner)
+
+	if err := b.Block(netip.MustParseAddr("192.0.2.9")); err != nil {
+		t.Fatal(err)
+	}
+	// FORWARD 中规则已存在，不重复插入
+	checkCmds(t, "block", runner.cmds, []string{
+		"iptables -C INPUT -s 192.0.2.9 -j DROP",
+		"iptables -I INPUT -s 192.0.2.9 -j DROP",
+		"iptables -C FORWARD -s 192.0.2.9 -j DROP",
+	})
+
+	runner.cmds = nil
+	runner.fail = map[string]bool{"ip6tables -D FORWARD": true}
+	err := b.Unblock(netip.MustParseAddr("2001:db8::3"))
+	if err == nil {
+		t.Error("expected unblock error to be reported")
+	}
+	checkCmds(t, "unblock", runner.cmds, []string{
+		"ip6tables -D INPUT -s 2001:db8::3 -j DROP",
+		"ip6tables -D FORWARD -s 2001:db8::3 -j DROP",
+	})
+}
+
+func TestIptablesBlockerDefaultChain(t *testing.T) {
+	runner := &fakeRunner{}
+	b := newTestBlocker(t, loader.FirewallConfig{Backend: loader.FirewallBackendIptables}, runner)
+	b.Unblock(netip.MustParseAddr("192.0.2.1"))
+	checkCmds(t, "unblock", runner.cmds, []string{"iptables -D INPUT -s 192.0.2.1 -j DROP"})
+}
+
+func TestDryRunBlocker(t *testing.T) {
+	runner := &fakeRunner{}
+	b := newTestBlocker(t, loader.FirewallConfig{Backend: loader.FirewallBackendDryRun}, runner)
+	if err := b.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Block(netip.MustParseAddr("192.0.2.1")); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.cmds) != 0 {
+		t.Errorf("dry-run executed commands: %q", runner.cmds)
+	}
+}
+
+func TestResponderUsesBlocker(t *testing.T) {
+	store := initTestDB(t)
+	runner := &fakeRunner{}
+	b := newTestBlocker(t, loader.FirewallConfig{Backend: loader.FirewallBackendIpset, Set: "bl"}, runner)
+	r := NewResponder(true, 0, nil, b, store)
+
+	r.blockIP("::ffff:192.0.2.5", "test", nil, 0)
+	if !r.IsBlocked("::ffff:192.0.2.5") {
+		t.Error("expected IP to be blocked")
+	}
+	r.unblockIP("::ffff:192.0.2.5")
+	if r.IsBlocked("::ffff:192.0.2.5") {
+		t.Error("expected IP to be unblocked")
+	}
+	// IPv4 映射地址按 IPv4 处理
+	checkCmds(t, "responder", runner.cmds, []string{
+		"ipset add bl 192.0.2.5 -exist",
+		"ipset del bl 192.0.2.5 -exist",
+	})
+}
@@ -0,0 +1,263 @@
+package response
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/server"
+
+	"github.com/sirupsen/logrus"
+)
+
+// blockSweepInterval 到期封禁的检查间隔
+const blockSweepInterval = 5 * time.Second
+
+// 手动封禁/解封的错误
+var (
+	ErrNotBlocked   = fmt.Errorf("该 IP 未被封禁: %w", server.ErrNotFound)
+	ErrWhitelisted  = fmt.Errorf("该 IP 在白名单中: %w", server.ErrConflict)
+	ErrBlockPending = fmt.Errorf("该 IP 的封禁状态正在变更: %w", server.ErrConflict)
+)
+
+// IsBlocked 检查源 IP 当前是否处于封禁状态
+func (r *Responder) IsBlocked(ip string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, blocked := r.blocks[ip]
+	return blocked && !b.Expired(time.Now())
+}
+
+// Blocks 返回当前生效的封禁
+func (r *Responder) Blocks() []db.Block {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	blocks := make([]db.Block, 0, len(r.blocks))
+	for _, b := range r.blocks {
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// Block 手动封禁 IP，duration 为 0 表示永久封禁
+func (r *Responder) Block(ip, reason string, duration time.Duration) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("无效的 IP 地址 %q: %w", ip, server.ErrInvalid)
+	}
+	ip = addr.Unmap().String()
+	if r.IsWhitelisted(ip) {
+		return ErrWhitelisted
+	}
+	return r.blockIP(ip, reason, nil, duration)
+}
+
+// Unblock 手动解除封禁
+func (r *Responder) Unblock(ip string) error {
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		ip = addr.Unmap().String()
+	}
+	return r.unblockIP(ip)
+}
+
+// blockIP 封禁源 IP 并持久化，已封禁的 IP 只刷新到期时间与原因
+// 防火墙命令与数据库写入在锁外执行，以免阻塞数据包路径上的 IsBlocked
+func (r *Responder) blockIP(ip, reason string, alertID *uint, duration time.Duration) error {
+	now := time.Now()
+	r.mu.Lock()
+	if r.pending[ip] {
+		r.mu.Unlock()
+		return ErrBlockPending
+	}
+	old, exists := r.blocks[ip]
+	block := old
+	if !exists {
+		block = db.Block{IP: ip, StartedAt: now}
+	}
+	block.Reason = reason
+	block.AlertID = alertID
+	block.ExpiresAt = nil
+	if duration > 0 {
+		expiresAt := now.Add(duration)
+		block.ExpiresAt = &expiresAt
+	}
+	if exists && sameBlock(old, block) {
+		r.mu.Unlock()
+		return nil
+	}
+	r.pending[ip] = true
+	r.mu.Unlock()
+
+	if !exists {
+		logrus.Errorf("正在封禁恶意源 IP: %s", ip)
+		if err := r.applyBlock(ip); err != nil {
+			logrus.Errorf("执行封禁命令失败 (%s): %v", r.blocker.Name(), err)
+			r.mu.Lock()
+			delete(r.pending, ip)
+			r.mu.Unlock()
+			return err
+		}
+		logrus.Infof("成功封禁 IP: %s", ip)
+	}
+	// 防火墙规则已生效，持久化失败只记录日志
+	if err := r.store.SaveBlock(&block); err != nil {
+		logrus.Errorf("保存封禁记录失败: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, ip)
+	r.blocks[ip] = block
+	return nil
+}
+
+// sameBlock 判断刷新后的封禁与原记录是否一致，到期时间按清理周期的精度比较
+func sameBlock(old, b db.Block) bool {
+	if old.Reason != b.Reason || (old.AlertID == nil) != (b.AlertID == nil) {
+		return false
+	}
+	if old.AlertID != nil && *old.AlertID != *b.AlertID {
+		return false
+	}
+	if old.ExpiresAt == nil || b.ExpiresAt == nil {
+		return old.ExpiresAt == nil && b.ExpiresAt == nil
+	}
+	return b.ExpiresAt.Sub(*old.ExpiresAt).Abs() < blockSweepInterval
+}
+
+// unblockIP 解除封禁并删除记录，防火墙命令失败时保留记录等待下次重试
+func (r *Responder) unblockIP(ip string) error {
+	r.mu.Lock()
+	if _, exists := r.blocks[ip]; !exists {
+		r.mu.Unlock()
+		return ErrNotBlocked
+	}
+	if r.pending[ip] {
+		r.mu.Unlock()
+		return ErrBlockPending
+	}
+	r.pending[ip] = true
+	r.mu.Unlock()
+
+	logrus.Infof("正在解除封禁 IP: %s", ip)
+	err := r.applyUnblock(ip)
+	if err != nil {
+		logrus.Errorf("解除封禁命令执行失败 (%s): %v", r.blocker.Name(), err)
+	} else if err := r.store.DeleteBlock(ip); err != nil {
+		logrus.Errorf("删除封禁记录失败: %v", err)
+	}
+
+	r.mu.Lock()
+	delete(r.pending, ip)
+	if err == nil {
+		delete(r.blocks, ip)
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	logrus.Infof("成功解除 IP 封禁: %s", ip)
+	return nil
+}
+
+// applyBlock 下发防火墙封禁，内联模式下由数据包裁决执行，无需下发
+func (r *Responder) applyBlock(ip string) error {
+	if r.isInline() {
+		return nil
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return err
+	}
+	return r.blocker.Block(addr.Unmap())
+}
+
+func (r *Responder) applyUnblock(ip string) error {
+	if r.isInline() {
+		return nil
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return err
+	}
+	return r.blocker.Unblock(addr.Unmap())
+}
+
+func (r *Responder) isInline() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.inline
+}
+
+// Reconcile 在启动时根据数据库中的封禁记录校正防火墙状态:
+// 已到期的封禁从防火墙和数据库中移除，仍然有效的重新下发 (后端的 Block 是幂等的)
+// 下发失败的封禁不记入内存，保留数据库记录，下次告警或重启时重试
+func (r *Responder) Reconcile() error {
+	blocks, err := r.store.ListBlocks()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	restored, removed, failed := 0, 0, 0
+	for _, b := range blocks {
+		if b.Expired(now) {
+			// 规则可能已随重启或手动操作消失，失败只记录
+			if err := r.applyUnblock(b.IP); err != nil {
+				logrus.Debugf("移除过期封禁 %s: %v", b.IP, err)
+			}
+			if err := r.store.DeleteBlock(b.IP); err != nil {
+				logrus.Errorf("删除封禁记录失败: %v", err)
+			}
+			removed++
+			continue
+		}
+
+		if err := r.applyBlock(b.IP); err != nil {
+			logrus.Errorf("恢复封禁 %s 失败 (%s): %v", b.IP, r.blocker.Name(), err)
+			failed++
+			continue
+		}
+		r.mu.Lock()
+		r.blocks[b.IP] = b
+		r.mu.Unlock()
+		restored++
+	}
+
+	logrus.Infof("封禁状态已同步: 恢复 %d 条，移除过期 %d 条，恢复失败 %d 条", restored, removed, failed)
+	return nil
+}
+
+// ExpireBlocks 解除所有在 now 之前到期的封禁
+func (r *Responder) ExpireBlocks(now time.Time) {
+	r.mu.RLock()
+	var expired []string
+	for ip, b := range r.blocks {
+		if b.Expired(now) {
+			expired = append(expired, ip)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, ip := range expired {
+		r.unblockIP(ip)
+	}
+}
+
+// Run 周期性解除到期的封禁与白名单、结束告警聚合窗口，直到 stop 被关闭
+func (r *Responder) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(blockSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			r.ExpireBlocks(now)
+			r.ExpireWhitelist(now)
+			r.alerts.expire(now)
+		case <-stop:
+			return
+		}
+	}
+}
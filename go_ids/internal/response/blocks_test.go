@@ -0,0 +1,193 @@
+package response
+
+import (
+	"errors"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+)
+
+func initTestDB(t *testing.T) *db.Store {
+	t.Helper()
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to init DB: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestReconcileBlocks(t *testing.T) {
+	store := initTestDB(t)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	for _, b := range []db.Block{
+		{IP: "192.0.2.1", Reason: "DDoS", StartedAt: past.Add(-2 * time.Hour), ExpiresAt: &past},
+		{IP: "192.0.2.2", Reason: "PortScan", StartedAt: past.Add(-time.Hour), ExpiresAt: &future},
+		{IP: "2001:db8::1", Reason: "manual", StartedAt: past},
+	} {
+		b := b
+		if err := store.SaveBlock(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := &fakeRunner{}
+	b := newTestBlocker(t, loader.FirewallConfig{Backend: loader.FirewallBackendIpset, Set: "bl"}, runner)
+	r := NewResponder(true, 3600, nil, b, store)
+	if err := r.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkCmds(t, "reconcile", runner.cmds, []string{
+		"ipset add bl6 2001:db8::1 -exist",
+		"ipset add bl 192.0.2.2 -exist",
+		"ipset del bl 192.0.2.1 -exist",
+	})
+	if r.IsBlocked("192.0.2.1") || !r.IsBlocked("192.0.2.2") || !r.IsBlocked("2001:db8::1") {
+		t.Errorf("unexpected in-memory blocks: %+v", r.Blocks())
+	}
+	blocks, err := store.ListBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 {
+		t.Errorf("Expected 2 stored blocks after reconcile, got %d", len(blocks))
+	}
+}
+
+func TestBlockPersistAndExpire(t *testing.T) {
+	store := initTestDB(t)
+
+	runner := &fakeRunner{}
+	b := newTestBlocker(t, loader.FirewallConfig{Backend: loader.FirewallBackendIpset, Set: "bl"}, runner)
+	r := NewResponder(true, 60, nil, b, store)
+
+	alertID := uint(42)
+	r.blockIP("198.51.100.7", "Bot (0.91)", &alertID, time.Minute)
+	// 重复封禁只刷新记录，不重复下发
+	r.blockIP("198.51.100.7", "Bot (0.95)", &alertID, time.Minute)
+
+	blocks, err := store.ListBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 stored block, got %d", len(blocks))
+	}
+	got := blocks[0]
+	if got.IP != "198.51.100.7" || got.Reason != "Bot (0.95)" || got.AlertID == nil || *got.AlertID != 42 || got.ExpiresAt == nil {
+		t.Errorf("unexpected stored block: %+v", got)
+	}
+	// 记录没有变化时不写数据库
+	r.blockIP("198.51.100.7", "Bot (0.95)", &alertID, time.Minute)
+	if blocks, _ := store.ListBlocks(); len(blocks) != 1 || !blocks[0].UpdatedAt.Equal(got.UpdatedAt) {
+		t.Errorf("unchanged block was rewritten: %+v", blocks)
+	}
+
+	r.ExpireBlocks(time.Now())
+	if !r.IsBlocked("198.51.100.7") {
+		t.Error("block expired too early")
+	}
+	r.ExpireBlocks(got.ExpiresAt.Add(time.Second))
+	if r.IsBlocked("198.51.100.7") {
+		t.Error("expected block to expire")
+	}
+	if blocks, _ := store.ListBlocks(); len(blocks) != 0 {
+		t.Errorf("Expected expired block to be deleted, got %d", len(blocks))
+	}
+
+	checkCmds(t, "block", runner.cmds, []string{
+		"ipset add bl 198.51.100.7 -exist",
+		"ipset del bl 198.51.100.7 -exist",
+	})
+}
+
+func TestReconcileRetriesFailedBlock(t *testing.T) {
+	store := initTestDB(t)
+	future := time.Now().Add(time.Hour)
+	if err := store.SaveBlock(&db.Block{IP: "192.0.2.2", Reason: "PortScan", StartedAt: time.Now(), ExpiresAt: &future}); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &fakeRunner{fail: map[string]bool{"ipset add": true}}
+	b := newTestBlocker(t, loader.FirewallConfig{Backend: loader.FirewallBackendIpset, Set: "bl"}, runner)
+	r := NewResponder(true, 3600, nil, b, store)
+	if err := r.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	// 下发失败的封禁不算生效，记录保留在数据库中
+	if r.IsBlocked("192.0.2.2") {
+		t.Error("failed block recorded as active")
+	}
+	if blocks, _ := store.ListBlocks(); len(blocks) != 1 {
+		t.Errorf("Expected the stored block to be kept, got %d", len(blocks))
+	}
+
+	// 防火墙恢复后，下一次封禁重新下发规则
+	runner.fail = nil
+	if err := r.blockIP("192.0.2.2", "PortScan", nil, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsBlocked("192.0.2.2") {
+		t.Error("expected the block to be retried")
+	}
+	checkCmds(t, "retry", runner.cmds, []string{
+		"ipset add bl 192.0.2.2 -exist",
+		"ipset add bl 192.0.2.2 -exist",
+	})
+}
+
+// slowBlocker 的 Block 在 release 关闭前不返回
+type slowBlocker struct {
+	dryRunBlocker
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *slowBlocker) Block(ip netip.Addr) error {
+	close(b.started)
+	<-b.release
+	return nil
+}
+
+func TestBlockOutsideLock(t *testing.T) {
+	store := initTestDB(t)
+	b := &slowBlocker{started: make(chan struct{}), release: make(chan struct{})}
+	r := NewResponder(true, 60, nil, b, store)
+
+	done := make(chan error, 1)
+	go func() { done <- r.blockIP("198.51.100.7", "Bot (0.91)", nil, time.Minute) }()
+	<-b.started
+
+	// 防火墙命令执行期间，数据包路径的查询不被阻塞，同一 IP 不重复下发
+	checked := make(chan bool, 1)
+	go func() { checked <- r.IsBlocked("198.51.100.7") }()
+	select {
+	case blocked := <-checked:
+		if blocked {
+			t.Error("IP reported blocked before the firewall command finished")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("IsBlocked waited for the firewall command")
+	}
+	if err := r.blockIP("198.51.100.7", "Bot (0.95)", nil, time.Minute); !errors.Is(err, ErrBlockPending) {
+		t.Errorf("concurrent blockIP = %v, want ErrBlockPending", err)
+	}
+
+	close(b.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsBlocked("198.51.100.7") {
+		t.Error("expected the block to be published")
+	}
+	if blocks, _ := store.ListBlocks(); len(blocks) != 1 {
+		t.Errorf("Expected 1 stored block, got %d", len(blocks))
+	}
+}
@@ -0,0 +1,156 @@
+package response
+
+import (
+	"sync"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+	"go-ids/internal/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// alertKey 告警聚合键
+type alertKey struct {
+	src, dst, label string
+}
+
+// alertGroup 聚合窗口内的一条告警
+type alertGroup struct {
+	alert db.Alert
+	ready chan struct{} // 告警写入数据库前不为 nil，写入完成后关闭
+}
+
+// alertAggregator 在聚合窗口内合并重复事件，并用令牌桶限制新建告警的速率
+// 一次端口扫描会产生大量到期流，不合并会刷屏并撑大数据库
+type alertAggregator struct {
+	store  Store
+	window time.Duration
+	rate   int // 每分钟最多新建的告警数，0 表示不限制
+
+	mu     sync.Mutex
+	groups map[alertKey]*alertGroup // 窗口内的告警，窗口从告警创建时开始计算
+	tokens float64
+	last   time.Time // 上次补充令牌的时间
+}
+
+func newAlertAggregator(cfg loader.AlertsConfig, store Store) *alertAggregator {
+	return &alertAggregator{
+		store:  store,
+		window: time.Duration(cfg.DedupWindow) * time.Second,
+		rate:   cfg.RateLimit,
+		groups: make(map[alertKey]*alertGroup),
+		tokens: float64(cfg.RateLimit),
+	}
+}
+
+// record 记录一次威胁事件，返回对应告警的副本以及它是否为新建告警
+// 被限速丢弃或保存失败的事件返回 nil
+// 在锁内决定合并还是新建，数据库写入在锁外进行，检测流程不必等待其他事件的写入
+func (a *alertAggregator) record(event Event, now time.Time) (*db.Alert, bool) {
+	key := alertKey{src: event.SourceIP, dst: event.DestIP, label: event.Label}
+
+	a.mu.Lock()
+	group, ok := a.groups[key]
+	// 同组告警正在写入时等待它分配 ID，否则重复事件会合并到尚未入库的告警
+	for ok && group.ready != nil {
+		ready := group.ready
+		a.mu.Unlock()
+		<-ready
+		a.mu.Lock()
+		group, ok = a.groups[key]
+	}
+
+	if ok && now.Sub(group.alert.CreatedAt) < a.window {
+		group.alert.Count++
+		group.alert.LastSeen = now
+		if event.Confidence > group.alert.Confidence {
+			group.alert.Confidence = event.Confidence
+		}
+		merged := group.alert
+		a.mu.Unlock()
+
+		if err := a.store.UpdateAlertAggregate(&merged); err != nil {
+			logrus.Errorf("更新聚合告警失败: %v", err)
+		}
+		metrics.Pipeline.AlertsDeduped.Add(1)
+		return &merged, false
+	}
+
+	if !a.allow(now) {
+		a.mu.Unlock()
+		metrics.Pipeline.AlertsThrottled.Add(1)
+		return nil, false
+	}
+
+	alert := db.Alert{
+		CreatedAt:  now,
+		SourceIP:   event.SourceIP,
+		DestIP:     event.DestIP,
+		Type:       event.Label,
+		Confidence: event.Confidence,
+		Payload:    event.Payload, // 存入载荷
+		Interface:  event.Interface,
+		Features:   event.Features,
+		Count:      1,
+		LastSeen:   now,
+	}
+	var pending *alertGroup
+	if a.window > 0 {
+		pending = &alertGroup{alert: alert, ready: make(chan struct{})}
+		a.groups[key] = pending
+	}
+	a.mu.Unlock()
+
+	err := a.store.CreateAlert(&alert)
+	if pending != nil {
+		a.mu.Lock()
+		if err != nil {
+			delete(a.groups, key)
+		} else {
+			pending.alert.ID = alert.ID
+		}
+		ready := pending.ready
+		pending.ready = nil
+		a.mu.Unlock()
+		close(ready)
+	}
+	if err != nil {
+		logrus.Errorf("保存报警信息失败: %v", err)
+		return nil, false
+	}
+	metrics.Pipeline.AlertsRaised.Add(1)
+	return &alert, true
+}
+
+// allow 从令牌桶取出一个令牌，桶容量为一分钟的配额
+func (a *alertAggregator) allow(now time.Time) bool {
+	if a.rate <= 0 {
+		return true
+	}
+	if !a.last.IsZero() {
+		a.tokens += now.Sub(a.last).Minutes() * float64(a.rate)
+		if a.tokens > float64(a.rate) {
+			a.tokens = float64(a.rate)
+		}
+	}
+	a.last = now
+	if a.tokens < 1 {
+		return false
+	}
+	a.tokens--
+	return true
+}
+
+// expire 丢弃聚合窗口已结束的告警，之后的同类事件会新建告警
+func (a *alertAggregator) expire(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, group := range a.groups {
+		if group.ready == nil && now.Sub(group.alert.CreatedAt) >= a.window {
+			delete(a.groups, key)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package response
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+	"go-ids/internal/metrics"
+)
+
+// alertsFrom 返回指定源的告警
+func alertsFrom(t *testing.T, store *db.Store, src string) []db.Alert {
+	t.Helper()
+	var alerts []db.Alert
+	if err := store.DB().Where("source_ip = ?", src).Order("id").Find(&alerts).Error; err != nil {
+		t.Fatal(err)
+	}
+	return alerts
+}
+
+func TestAlertDedup(t *testing.T) {
+	store := initTestDB(t)
+
+	a := newAlertAggregator(loader.AlertsConfig{DedupWindow: 60}, store)
+	now := time.Now()
+	scan := Event{SourceIP: "192.0.2.1", DestIP: "10.0.0.1", Label: "PortScan", Confidence: 0.9}
+	deduped := metrics.Pipeline.AlertsDeduped.Load()
+
+	first, created := a.record(scan, now)
+	if first == nil || !created {
+		t.Fatal("expected first event to create an alert")
+	}
+	for i := 1; i <= 3; i++ {
+		scan.Confidence = 0.9 + float32(i)/100
+		alert, created := a.record(scan, now.Add(time.Duration(i)*time.Second))
+		if created || alert.ID != first.ID || alert.Count != i+1 {
+			t.Fatalf("event %d: created=%v alert=%+v", i, created, alert)
+		}
+	}
+	if got := metrics.Pipeline.AlertsDeduped.Load() - deduped; got != 3 {
+		t.Errorf("AlertsDeduped = %d, want 3", got)
+	}
+
+	// 不同目的地址单独成组
+	if _, created := a.record(Event{SourceIP: "192.0.2.1", DestIP: "10.0.0.2", Label: "PortScan"}, now); !created {
+		t.Error("expected a separate alert for another destination")
+	}
+
+	alerts := alertsFrom(t, store, "192.0.2.1")
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 stored alerts, got %d", len(alerts))
+	}
+	stored := alerts[0]
+	if stored.Count != 4 || stored.Confidence < 0.929 || !stored.LastSeen.Equal(now.Add(3*time.Second)) {
+		t.Errorf("aggregate not persisted: %+v", stored)
+	}
+
+	// 窗口结束后新建告警
+	a.expire(now.Add(time.Minute))
+	if _, created := a.record(scan, now.Add(time.Minute)); !created {
+		t.Error("expected a new alert after the window")
+	}
+}
+
+// failingStore 模拟数据库写入失败
+type failingStore struct {
+	*db.Store
+}
+
+func (failingStore) CreateAlert(*db.Alert) error {
+	return errors.New("database is locked")
+}
+
+func TestAlertCreateFailure(t *testing.T) {
+	a := newAlertAggregator(loader.AlertsConfig{DedupWindow: 60}, failingStore{initTestDB(t)})
+	raised := metrics.Pipeline.AlertsRaised.Load()
+	scan := Event{SourceIP: "192.0.2.1", DestIP: "10.0.0.1", Label: "PortScan"}
+
+	// 保存失败的告警不能返回给调用方，也不能作为后续重复事件的聚合目标
+	for i := 0; i < 2; i++ {
+		if alert, created := a.record(scan, time.Now()); alert != nil || created {
+			t.Fatalf("event %d: alert=%+v created=%v", i, alert, created)
+		}
+	}
+	if got := metrics.Pipeline.AlertsRaised.Load() - raised; got != 0 {
+		t.Errorf("AlertsRaised = %d, want 0", got)
+	}
+}
+
+func TestAlertDedupConcurrent(t *testing.T) {
+	store := initTestDB(t)
+	a := newAlertAggregator(loader.AlertsConfig{DedupWindow: 60}, store)
+	now := time.Now()
+
+	const events = 20
+	var wg sync.WaitGroup
+	for i := 0; i < events; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			alert, _ := a.record(Event{SourceIP: "192.0.2.9", DestIP: "10.0.0.1", Label: "DoS"}, now)
+			if alert == nil || alert.ID == 0 {
+				t.Errorf("record() = %+v", alert)
+			}
+		}()
+	}
+	wg.Wait()
+
+	alerts := alertsFrom(t, store, "192.0.2.9")
+	if len(alerts) != 1 || alerts[0].Count != events {
+		t.Fatalf("expected one alert with count %d, got %+v", events, alerts)
+	}
+}
+
+func TestAlertRateLimit(t *testing.T) {
+	store := initTestDB(t)
+
+	a := newAlertAggregator(loader.AlertsConfig{RateLimit: 2}, store)
+	now := time.Now()
+	throttled := metrics.Pipeline.AlertsThrottled.Load()
+
+	event := func(src string) Event {
+		return Event{SourceIP: src, DestIP: "10.0.0.1", Label: "DDoS"}
+	}
+	for i, src := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		alert, _ := a.record(event(src), now)
+		if (alert != nil) != (i < 2) {
+			t.Errorf("event %d: alert=%v", i, alert)
+		}
+	}
+	if got := metrics.Pipeline.AlertsThrottled.Load() - throttled; got != 1 {
+		t.Errorf("AlertsThrottled = %d, want 1", got)
+	}
+
+	// 每分钟 2 个令牌，30 秒后补充一个
+	if alert, _ := a.record(event("192.0.2.4"), now.Add(30*time.Second)); alert == nil {
+		t.Error("expected a token after refill")
+	}
+	if alert, _ := a.record(event("192.0.2.5"), now.Add(30*time.Second)); alert != nil {
+		t.Error("expected bucket to be empty")
+	}
+}
+
+func TestHandleDedup(t *testing.T) {
+	store := initTestDB(t)
+
+	r := NewResponder(true, 60, nil, nil, store)
+	r.SetAlertPolicy(loader.AlertsConfig{DedupWindow: 300})
+	for i := 0; i < 5; i++ {
+		r.Handle(Event{SourceIP: "198.51.100.1", DestIP: "10.0.0.1", Label: "PortScan", Confidence: 0.95})
+	}
+
+	alerts := alertsFrom(t, store, "198.51.100.1")
+	if len(alerts) != 1 || alerts[0].Count != 5 {
+		t.Fatalf("expected one alert with count 5, got %+v", alerts)
+	}
+	blocks := r.Blocks()
+	if len(blocks) != 1 || blocks[0].AlertID == nil || *blocks[0].AlertID != alerts[0].ID {
+		t.Errorf("expected block linked to the aggregated alert, got %+v", blocks)
+	}
+}
@@ -0,0 +1,50 @@
+package response
+
+import (
+	"net/netip"
+
+	"go-ids/internal/loader"
+)
+
+// ipsetBlocker 把封禁 IP 放入 hash:ip 集合，每条链只需一条 iptables 规则
+// IPv4 与 IPv6 分别使用 <set> 与 <set>6 两个集合
+type ipsetBlocker struct {
+	set    string
+	chains []string
+	runner CommandRunner
+}
+
+func (b *ipsetBlocker) Name() string { return loader.FirewallBackendIpset }
+
+func (b *ipsetBlocker) Init() error {
+	if err := b.runner.Run("ipset", "create", b.set, "hash:ip", "family", "inet", "-exist"); err != nil {
+		return err
+	}
+	if err := b.runner.Run("ipset", "create", set6(b.set), "hash:ip", "family", "inet6", "-exist"); err != nil {
+		return err
+	}
+	for _, chain := range b.chains {
+		if err := ensureRule(b.runner, "iptables", chain, "-m", "set", "--match-set", b.set, "src", "-j", "DROP"); err != nil {
+			return err
+		}
+		if err := ensureRule(b.runner, "ip6tables", chain, "-m", "set", "--match-set", set6(b.set), "src", "-j", "DROP"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *ipsetBlocker) setFor(ip netip.Addr) string {
+	if ip.Is4() {
+		return b.set
+	}
+	return set6(b.set)
+}
+
+func (b *ipsetBlocker) Block(ip netip.Addr) error {
+	return b.runner.Run("ipset", "add", b.setFor(ip), ip.String(), "-exist")
+}
+
+func (b *ipsetBlocker) Unblock(ip netip.Addr) error {
+	return b.runner.Run("ipset", "del", b.setFor(ip), ip.String(), "-exist")
+}
@@ -0,0 +1,56 @@
+package response
+
+import (
+	"errors"
+	"net/netip"
+
+	"go-ids/internal/loader"
+)
+
+// iptablesCmd 返回地址族对应的 iptables 命令
+func iptablesCmd(ip netip.Addr) string {
+	if ip.Is4() {
+		return "iptables"
+	}
+	return "ip6tables"
+}
+
+// ensureRule 规则不存在时插入到链首，避免重复添加
+func ensureRule(runner CommandRunner, cmd, chain string, rule ...string) error {
+	check := append([]string{"-C", chain}, rule...)
+	if runner.Run(cmd, check...) == nil {
+		return nil
+	}
+	return runner.Run(cmd, append([]string{"-I", chain}, rule...)...)
+}
+
+// iptablesBlocker 在每条配置的链中为每个 IP 插入一条 DROP 规则
+// 规则数量随封禁 IP 线性增长，大量封禁时应使用 ipset 或 nftables 后端
+type iptablesBlocker struct {
+	chains []string
+	runner CommandRunner
+}
+
+func (b *iptablesBlocker) Name() string { return loader.FirewallBackendIptables }
+
+func (b *iptablesBlocker) Init() error { return nil }
+
+func (b *iptablesBlocker) Block(ip netip.Addr) error {
+	var errs []error
+	for _, chain := range b.chains {
+		if err := ensureRule(b.runner, iptablesCmd(ip), chain, "-s", ip.String(), "-j", "DROP"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *iptablesBlocker) Unblock(ip netip.Addr) error {
+	var errs []error
+	for _, chain := range b.chains {
+		if err := b.runner.Run(iptablesCmd(ip), "-D", chain, "-s", ip.String(), "-j", "DROP"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
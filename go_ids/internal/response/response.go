@@ -2,12 +2,13 @@ package response
 
 import (
 	"fmt"
-	"os/exec"
-	"runtime"
 	"sync"
 	"time"
 
 	"go-ids/internal/db"
+	"go-ids/internal/incident"
+	"go-ids/internal/loader"
+	"go-ids/internal/notify"
 	"go-ids/internal/server"
 
 	"github.com/sirupsen/logrus"
@@ -20,154 +21,150 @@ type Event struct {
 	Label      string
 	Confidence float32
 	Timestamp  time.Time
-	Payload    string // 新增: 攻击报文 Hex 或明文
+	Payload    string    // 新增: 攻击报文 Hex 或明文
+	Interface  string    // 入口接口
+	Features   []float32 // 原始特征向量，随告警保存以便导出误报样本
+}
+
+// Store 响应器保存告警、封禁与运行时白名单所用的持久化接口
+type Store interface {
+	db.AlertRepository
+	db.ResponseRepository
 }
 
 // Responder 负责处理威胁事件
 type Responder struct {
+	store         Store
 	enableBlock   bool
 	blockDuration time.Duration
-	whitelist     map[string]bool
-	blockedIPs    map[string]time.Time
-	mu            sync.Mutex
+	whitelist     *whitelist
+	blocks        map[string]db.Block // 当前生效的封禁，与数据库中的记录一致
+	pending       map[string]bool     // 正在执行防火墙命令的 IP，同一 IP 的封禁与解封不并发执行
+	blocker       Blocker
+	inline        bool // 内联模式: 封禁只记录在内存中，由抓包源的裁决丢弃数据包
+	alerts        *alertAggregator
+	correlator    *incident.Correlator // 为 nil 时不做告警关联
+	notifier      *notify.Notifier     // 为 nil 时不发送 Webhook 通知
+	syslog        *notify.SyslogForwarder
+	resetter      *TCPResetter // 为 nil 时不注入 RST
+	mu            sync.RWMutex
 }
 
-// NewResponder 创建一个新的响应器
-func NewResponder(enableBlock bool, blockDurationSeconds int, whitelist []string) *Responder {
-	wlMap := make(map[string]bool)
-	for _, ip := range whitelist {
-		wlMap[ip] = true
+// NewResponder 创建一个新的响应器，blocker 为 nil 时封禁只记录日志
+func NewResponder(enableBlock bool, blockDurationSeconds int, whitelist loader.WhitelistList, blocker Blocker, store Store) *Responder {
+	if blocker == nil {
+		blocker = dryRunBlocker{}
 	}
 
 	return &Responder{
+		store:         store,
 		enableBlock:   enableBlock,
 		blockDuration: time.Duration(blockDurationSeconds) * time.Second,
-		whitelist:     wlMap,
-		blockedIPs:    make(map[string]time.Time),
+		whitelist:     loadWhitelist(whitelist),
+		blocks:        make(map[string]db.Block),
+		pending:       make(map[string]bool),
+		blocker:       blocker,
+		alerts:        newAlertAggregator(loader.AlertsConfig{}, store),
 	}
 }
 
-// Handle 处理威胁事件
-func (r *Responder) Handle(event Event) {
-	// 1. 记录日志
-	logrus.WithFields(logrus.Fields{
-		"src":        event.SourceIP,
-		"dst":        event.DestIP,
-		"type":       event.Label,
-		"confidence": fmt.Sprintf("%.2f", event.Confidence),
-	}).Warn("检测到入侵威胁!")
-
-	// 2. 如果是合法流量，直接跳过
-	if event.Label == "Benign" {
-		return
-	}
+// SetCorrelator 设置告警关联器，新建的告警会被归入事件 (Incident)
+func (r *Responder) SetCorrelator(c *incident.Correlator) {
+	r.correlator = c
+}
 
-	// 3. 检查白名单
-	if r.whitelist[event.SourceIP] {
-		logrus.Infof("IP %s 在白名单中，忽略封禁操作", event.SourceIP)
-		return
-	}
+// SetNotifier 设置 Webhook 通知器，新建的告警会放入其发件箱
+func (r *Responder) SetNotifier(n *notify.Notifier) {
+	r.notifier = n
+}
 
-	// 4. 保存到数据库
-	alert := &db.Alert{
-		CreatedAt:  time.Now(),
-		SourceIP:   event.SourceIP,
-		DestIP:     event.DestIP,
-		Type:       event.Label,
-		Confidence: event.Confidence,
-		Payload:    event.Payload, // 存入载荷
-	}
-	if err := db.CreateAlert(alert); err != nil {
-		logrus.Errorf("保存报警信息失败: %v", err)
-	}
+// SetSyslog 设置 syslog 转发器，新建的告警会转发给 SIEM
+func (r *Responder) SetSyslog(f *notify.SyslogForwarder) {
+	r.syslog = f
+}
 
-	// 5. 通过 SSE 推送给前端
-	select {
-	case server.Manager.Message <- *alert:
-	default:
-		// 防止阻塞
-	}
+// SetTCPResetter 设置 RST 注入器，ResetFlow 通过它中断恶意 TCP 流
+func (r *Responder) SetTCPResetter(t *TCPResetter) {
+	r.resetter = t
+}
 
-	// 6. 执行封禁逻辑
-	if r.enableBlock {
-		r.blockIP(event.SourceIP)
-	}
+// SetAlertPolicy 设置告警聚合窗口与速率限制，需在处理事件前调用
+func (r *Responder) SetAlertPolicy(cfg loader.AlertsConfig) {
+	r.alerts = newAlertAggregator(cfg, r.store)
 }
 
-func (r *Responder) blockIP(ip string) {
+// SetInline 切换内联 (NFQUEUE) 模式
+// 内联模式下不下发防火墙规则，被封禁源的数据包在裁决时直接丢弃
+func (r *Responder) SetInline(inline bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.inline = inline
+}
 
-	// 如果已经在封禁名单中，更新封禁时间
-	if _, exists := r.blockedIPs[ip]; exists {
-		r.blockedIPs[ip] = time.Now()
-		return
+// Handle 处理威胁事件，返回事件所记入的告警 ID (未入库时为 0)
+func (r *Responder) Handle(event Event) uint {
+	// 1. 如果是合法流量，直接跳过
+	if event.Label == "Benign" {
+		return 0
 	}
 
-	logrus.Errorf("正在封禁恶意源 IP: %s", ip)
-
-	// 根据操作系统执行不同的封禁命令
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "linux":
-		// 使用 iptables 封禁 (需 root 权限)
-		cmd = exec.Command("iptables", "-A", "INPUT", "-s", ip, "-j", "DROP")
-	case "windows":
-		// 使用 netsh 封禁 (需管理员权限)
-		cmd = exec.Command("netsh", "advfirewall", "firewall", "add", "rule",
-			"name=IDS_BLOCK_"+ip, "dir=in", "action=block", "remoteip="+ip)
-	default:
-		logrus.Warnf("当前系统 %s 不支持自动封禁功能", runtime.GOOS)
-		return
+	// 2. 检查白名单
+	if r.IsWhitelisted(event.SourceIP) {
+		logrus.Infof("IP %s 在白名单中，忽略封禁操作", event.SourceIP)
+		return 0
 	}
 
-	if err := cmd.Run(); err != nil {
-		logrus.Errorf("执行封禁命令失败: %v", err)
-	} else {
-		r.blockedIPs[ip] = time.Now()
-		logrus.Infof("成功封禁 IP: %s", ip)
-
-		// 启动定时器，到期自动解封
-		if r.blockDuration > 0 {
-			go r.unblockAfter(ip, r.blockDuration)
+	// 3. 聚合、限速并保存到数据库
+	fields := logrus.Fields{
+		"src":        event.SourceIP,
+		"dst":        event.DestIP,
+		"iface":      event.Interface,
+		"type":       event.Label,
+		"confidence": fmt.Sprintf("%.2f", event.Confidence),
+	}
+	alert, created := r.alerts.record(event, time.Now())
+	var alertID *uint
+	switch {
+	case alert == nil:
+		logrus.WithFields(fields).Debug("告警被限速或保存失败，事件未入库")
+	case created:
+		logrus.WithFields(fields).Warn("检测到入侵威胁!")
+		alertID = &alert.ID
+		r.correlate(alert)
+		// 4. 通过 SSE、Webhook 与 syslog 推送，重复事件只更新计数不推送
+		server.Manager.Publish(server.EventAlert, *alert)
+		if r.notifier != nil {
+			r.notifier.Notify(*alert)
+		}
+		if r.syslog != nil {
+			r.syslog.Forward(*alert)
 		}
+	default:
+		fields["count"] = alert.Count
+		logrus.WithFields(fields).Debug("重复事件已合并到已有告警")
+		alertID = &alert.ID
 	}
-}
 
-func (r *Responder) unblockAfter(ip string, delay time.Duration) {
-	time.Sleep(delay)
-	r.unblockIP(ip)
+	// 5. 执行封禁逻辑，被限速的事件同样封禁
+	if r.enableBlock {
+		reason := fmt.Sprintf("%s (%.2f)", event.Label, event.Confidence)
+		r.blockIP(event.SourceIP, reason, alertID, r.blockDuration)
+	}
+	if alertID == nil {
+		return 0
+	}
+	return *alertID
 }
 
-func (r *Responder) unblockIP(ip string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if _, exists := r.blockedIPs[ip]; !exists {
+// correlate 将新建的告警归入事件，并在告警上记录事件 ID
+func (r *Responder) correlate(alert *db.Alert) {
+	if r.correlator == nil {
 		return
 	}
-
-	logrus.Infof("正在解除封禁 IP: %s", ip)
-
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "linux":
-		cmd = exec.Command("iptables", "-D", "INPUT", "-s", ip, "-j", "DROP")
-	case "windows":
-		cmd = exec.Command("netsh", "advfirewall", "firewall", "delete", "rule", "name=IDS_BLOCK_"+ip)
-	}
-
-	if cmd != nil {
-		if err := cmd.Run(); err != nil {
-			logrus.Errorf("解除封禁命令执行失败: %v", err)
-		} else {
-			delete(r.blockedIPs, ip)
-			logrus.Infof("成功解除 IP 封禁: %s", ip)
-		}
+	inc, err := r.correlator.Correlate(*alert)
+	if err != nil {
+		logrus.Errorf("告警关联失败: %v", err)
+		return
 	}
-}
-
-// IsWhitelisted 检查 IP 是否在白名单
-func (r *Responder) IsWhitelisted(ip string) bool {
-	return r.whitelist[ip]
+	alert.IncidentID = &inc.ID
 }
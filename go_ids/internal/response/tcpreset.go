@@ -0,0 +1,179 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"go-ids/internal/flow"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/sirupsen/logrus"
+)
+
+// resetTTL 伪造 RST 报文的 TTL/跳数限制
+const resetTTL = 64
+
+// packetWriter 发送带完整 IP 首部的数据包，测试中可替换为假实现
+type packetWriter interface {
+	WritePacket(dst netip.Addr, data []byte) error
+	Close() error
+}
+
+// TCPResetter 向恶意 TCP 流的两端注入伪造的 RST 报文以中断连接
+// 报文的序列号取自流跟踪中最近观测到的状态，对端只接受落在接收窗口内的 RST
+type TCPResetter struct {
+	writer packetWriter
+}
+
+// NewTCPResetter 打开原始套接字，需要 root 或 CAP_NET_RAW 权限
+func NewTCPResetter() (*TCPResetter, error) {
+	w, err := newRawWriter()
+	if err != nil {
+		return nil, err
+	}
+	return &TCPResetter{writer: w}, nil
+}
+
+// Close 关闭原始套接字
+func (t *TCPResetter) Close() error {
+	return t.writer.Close()
+}
+
+// Reset 向流的两端发送 RST，返回成功发送的报文数
+func (t *TCPResetter) Reset(f *flow.Flow) (int, error) {
+	resets, err := buildResets(f)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	var errs []error
+	for _, rst := range resets {
+		if err := t.writer.WritePacket(rst.dst, rst.data); err != nil {
+			errs = append(errs, fmt.Errorf("发送 RST 到 %s 失败: %v", rst.dst, err))
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// resetPacket 一个待发送的 RST 报文
+type resetPacket struct {
+	dst  netip.Addr
+	data []byte
+}
+
+// buildResets 为流构造发往服务端 (冒充客户端) 与发往客户端 (冒充服务端) 的 RST
+// 某个方向的序列号未知时跳过该方向
+func buildResets(f *flow.Flow) ([]resetPacket, error) {
+	if f.Key.Proto != layers.IPProtocolTCP {
+		return nil, fmt.Errorf("流 %s 不是 TCP", f.Key)
+	}
+	client, err1 := netip.ParseAddr(f.Key.SrcIP)
+	server, err2 := netip.ParseAddr(f.Key.DstIP)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("流 %s 的地址无效", f.Key)
+	}
+	client, server = client.Unmap(), server.Unmap()
+	if client.Is4() != server.Is4() {
+		return nil, fmt.Errorf("流 %s 的地址族不一致", f.Key)
+	}
+
+	var resets []resetPacket
+	// 发送方的下一个序列号可以直接观测到，没有观测到时用对端的确认号代替
+	if seq, ok := nextSeq(f.FwdTCP, f.BwdTCP); ok {
+		ack, hasAck := nextSeq(f.BwdTCP, f.FwdTCP)
+		data, err := buildReset(client, server, f.Key.SrcPort, f.Key.DstPort, seq, ack, hasAck)
+		if err != nil {
+			return nil, err
+		}
+		resets = append(resets, resetPacket{dst: server, data: data})
+	}
+	if seq, ok := nextSeq(f.BwdTCP, f.FwdTCP); ok {
+		ack, hasAck := nextSeq(f.FwdTCP, f.BwdTCP)
+		data, err := buildReset(server, client, f.Key.DstPort, f.Key.SrcPort, seq, ack, hasAck)
+		if err != nil {
+			return nil, err
+		}
+		resets = append(resets, resetPacket{dst: client, data: data})
+	}
+	if len(resets) == 0 {
+		return nil, fmt.Errorf("流 %s 的序列号未知", f.Key)
+	}
+	return resets, nil
+}
+
+// nextSeq 返回 sender 方向下一个字节的序列号
+func nextSeq(sender, peer flow.TCPSeq) (uint32, bool) {
+	if sender.SeqValid {
+		return sender.NextSeq, true
+	}
+	if peer.AckValid {
+		return peer.Ack, true
+	}
+	return 0, false
+}
+
+// buildReset 构造一个带 IP 首部的 RST 报文，已知对端序列号时同时置 ACK
+func buildReset(src, dst netip.Addr, srcPort, dstPort uint16, seq, ack uint32, hasAck bool) ([]byte, error) {
+	tcp := &layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		Seq:     seq,
+		RST:     true,
+	}
+	if hasAck {
+		tcp.ACK = true
+		tcp.Ack = ack
+	}
+
+	var ip gopacket.SerializableLayer
+	if src.Is4() {
+		ip4 := &layers.IPv4{
+			Version:  4,
+			TTL:      resetTTL,
+			Flags:    layers.IPv4DontFragment,
+			Protocol: layers.IPProtocolTCP,
+			SrcIP:    src.AsSlice(),
+			DstIP:    dst.AsSlice(),
+		}
+		tcp.SetNetworkLayerForChecksum(ip4)
+		ip = ip4
+	} else {
+		ip6 := &layers.IPv6{
+			Version:    6,
+			HopLimit:   resetTTL,
+			NextHeader: layers.IPProtocolTCP,
+			SrcIP:      src.AsSlice(),
+			DstIP:      dst.AsSlice(),
+		}
+		tcp.SetNetworkLayerForChecksum(ip6)
+		ip = ip6
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, tcp); err != nil {
+		return nil, fmt.Errorf("构造 RST 报文失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ResetFlow 在启用 RST 注入时中断恶意 TCP 流，任一端在白名单中时不做处理
+func (r *Responder) ResetFlow(f *flow.Flow) {
+	if r.resetter == nil || f == nil || f.Key.Proto != layers.IPProtocolTCP {
+		return
+	}
+	if r.IsWhitelisted(f.Key.SrcIP) || r.IsWhitelisted(f.Key.DstIP) {
+		logrus.Infof("流 %s 的端点在白名单中，不注入 RST", f.Key)
+		return
+	}
+	sent, err := r.resetter.Reset(f)
+	if err != nil {
+		logrus.Warnf("注入 RST 失败 (已发送 %d 个): %v", sent, err)
+		return
+	}
+	logrus.Infof("已向流 %s 两端注入 %d 个 RST", f.Key, sent)
+}
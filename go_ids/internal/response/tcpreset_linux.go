@@ -0,0 +1,48 @@
+//go:build linux
+
+package response
+
+import (
+	"fmt"
+	"net/netip"
+
+	"golang.org/x/sys/unix"
+)
+
+// rawWriter 通过 IPPROTO_RAW 原始套接字发送自带 IP 首部的数据包
+// 内核按路由表选择出口，不需要知道链路层地址
+type rawWriter struct {
+	fd4 int
+	fd6 int // 系统不支持 IPv6 时为 -1
+}
+
+func newRawWriter() (packetWriter, error) {
+	fd4, err := unix.Socket(unix.AF_INET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_RAW)
+	if err != nil {
+		return nil, fmt.Errorf("打开 IPv4 原始套接字失败 (需要 root 或 CAP_NET_RAW): %v", err)
+	}
+	fd6, err := unix.Socket(unix.AF_INET6, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_RAW)
+	if err != nil {
+		fd6 = -1
+	}
+	return &rawWriter{fd4: fd4, fd6: fd6}, nil
+}
+
+// WritePacket 发送一个完整的 IP 数据包
+func (w *rawWriter) WritePacket(dst netip.Addr, data []byte) error {
+	if dst.Is4() {
+		return unix.Sendto(w.fd4, data, 0, &unix.SockaddrInet4{Addr: dst.As4()})
+	}
+	if w.fd6 < 0 {
+		return fmt.Errorf("系统不支持 IPv6 原始套接字")
+	}
+	return unix.Sendto(w.fd6, data, 0, &unix.SockaddrInet6{Addr: dst.As16()})
+}
+
+// Close 关闭套接字
+func (w *rawWriter) Close() error {
+	if w.fd6 >= 0 {
+		unix.Close(w.fd6)
+	}
+	return unix.Close(w.fd4)
+}
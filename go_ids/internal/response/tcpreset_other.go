@@ -0,0 +1,13 @@
+//go:build !linux
+
+package response
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// newRawWriter 在非 Linux 平台上不可用
+func newRawWriter() (packetWriter, error) {
+	return nil, fmt.Errorf("TCP RST 注入仅支持 Linux，当前系统为 %s", runtime.GOOS)
+}
@@ -0,0 +1,157 @@
+package response
+
+import (
+	"net/netip"
+	"testing"
+
+	"go-ids/internal/flow"
+	"go-ids/internal/loader"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// fakeWriter 记录待发送的数据包
+type fakeWriter struct {
+	dsts    []netip.Addr
+	packets []gopacket.Packet
+}
+
+func (w *fakeWriter) WritePacket(dst netip.Addr, data []byte) error {
+	first := layers.LayerTypeIPv4
+	if !dst.Is4() {
+		first = layers.LayerTypeIPv6
+	}
+	w.dsts = append(w.dsts, dst)
+	w.packets = append(w.packets, gopacket.NewPacket(data, first, gopacket.Default))
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func tcpFlow(src, dst string) *flow.Flow {
+	return &flow.Flow{
+		Key:    flow.FlowKey{SrcIP: src, DstIP: dst, SrcPort: 40000, DstPort: 80, Proto: layers.IPProtocolTCP},
+		FwdTCP: flow.TCPSeq{NextSeq: 1000, Ack: 5000, SeqValid: true, AckValid: true},
+		BwdTCP: flow.TCPSeq{NextSeq: 5000, Ack: 990, SeqValid: true, AckValid: true},
+	}
+}
+
+// checkReset 校验报文的地址、端口、序列号与校验和
+func checkReset(t *testing.T, pkt gopacket.Packet, src, dst string, sport, dport uint16, seq, ack uint32) {
+	t.Helper()
+	if err := pkt.ErrorLayer(); err != nil {
+		t.Fatalf("malformed packet: %v", err.Error())
+	}
+	net := pkt.NetworkLayer().NetworkFlow()
+	if net.Src().String() != src || net.Dst().String() != dst {
+		t.Errorf("addresses: got %s, want %s -> %s", net, src, dst)
+	}
+	tcp := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if !tcp.RST || !tcp.ACK || tcp.SYN || tcp.FIN || tcp.PSH {
+		t.Errorf("flags: got %+v", tcp)
+	}
+	if uint16(tcp.SrcPort) != sport || uint16(tcp.DstPort) != dport || tcp.Seq != seq || tcp.Ack != ack {
+		t.Errorf("got %d->%d seq=%d ack=%d, want %d->%d seq=%d ack=%d",
+			tcp.SrcPort, tcp.DstPort, tcp.Seq, tcp.Ack, sport, dport, seq, ack)
+	}
+	if len(tcp.Payload) != 0 {
+		t.Errorf("unexpected payload: %x", tcp.Payload)
+	}
+
+	// 连同报文中的校验和按伪首部求和，结果为 0 说明校验和正确
+	if err := tcp.SetNetworkLayerForChecksum(pkt.NetworkLayer()); err != nil {
+		t.Fatal(err)
+	}
+	if sum, err := tcp.ComputeChecksum(); err != nil || sum != 0 || tcp.Checksum == 0 {
+		t.Errorf("bad TCP checksum %#04x (residual %#04x, err %v)", tcp.Checksum, sum, err)
+	}
+}
+
+func TestBuildResetsIPv4(t *testing.T) {
+	w := &fakeWriter{}
+	rst := &TCPResetter{writer: w}
+	sent, err := rst.Reset(tcpFlow("192.0.2.10", "198.51.100.20"))
+	if err != nil || sent != 2 {
+		t.Fatalf("Reset: sent=%d err=%v", sent, err)
+	}
+
+	// 冒充客户端发往服务端，冒充服务端发往客户端
+	checkReset(t, w.packets[0], "192.0.2.10", "198.51.100.20", 40000, 80, 1000, 5000)
+	checkReset(t, w.packets[1], "198.51.100.20", "192.0.2.10", 80, 40000, 5000, 1000)
+	if w.dsts[0].String() != "198.51.100.20" || w.dsts[1].String() != "192.0.2.10" {
+		t.Errorf("destinations: %v", w.dsts)
+	}
+
+	ip := w.packets[0].Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if ip.TTL != resetTTL || ip.Length != 40 || ip.Protocol != layers.IPProtocolTCP {
+		t.Errorf("unexpected IPv4 header: %+v", ip)
+	}
+}
+
+func TestBuildResetsIPv6(t *testing.T) {
+	w := &fakeWriter{}
+	rst := &TCPResetter{writer: w}
+	if _, err := rst.Reset(tcpFlow("2001:db8::1", "2001:db8::2")); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.packets) != 2 {
+		t.Fatalf("expected 2 packets, got %d", len(w.packets))
+	}
+	checkReset(t, w.packets[0], "2001:db8::1", "2001:db8::2", 40000, 80, 1000, 5000)
+	checkReset(t, w.packets[1], "2001:db8::2", "2001:db8::1", 80, 40000, 5000, 1000)
+}
+
+func TestBuildResetsPartialState(t *testing.T) {
+	// 只观测到客户端方向: 服务端的序列号取自客户端的确认号
+	f := tcpFlow("192.0.2.10", "198.51.100.20")
+	f.BwdTCP = flow.TCPSeq{}
+	resets, err := buildResets(f)
+	if err != nil || len(resets) != 2 {
+		t.Fatalf("buildResets: %d packets, err=%v", len(resets), err)
+	}
+	pkt := gopacket.NewPacket(resets[1].data, layers.LayerTypeIPv4, gopacket.Default)
+	checkReset(t, pkt, "198.51.100.20", "192.0.2.10", 80, 40000, 5000, 1000)
+
+	// 只有一个 SYN: 不知道服务端序列号，发往客户端的 RST 被跳过，发往服务端的不带 ACK
+	f.FwdTCP = flow.TCPSeq{NextSeq: 1001, SeqValid: true}
+	resets, err = buildResets(f)
+	if err != nil || len(resets) != 1 {
+		t.Fatalf("buildResets: %d packets, err=%v", len(resets), err)
+	}
+	tcp := gopacket.NewPacket(resets[0].data, layers.LayerTypeIPv4, gopacket.Default).Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if !tcp.RST || tcp.ACK || tcp.Seq != 1001 || resets[0].dst.String() != "198.51.100.20" {
+		t.Errorf("unexpected reset: %+v", tcp)
+	}
+
+	f.FwdTCP = flow.TCPSeq{}
+	if _, err := buildResets(f); err == nil {
+		t.Error("expected error without sequence numbers")
+	}
+	f = tcpFlow("192.0.2.10", "198.51.100.20")
+	f.Key.Proto = layers.IPProtocolUDP
+	if _, err := buildResets(f); err == nil {
+		t.Error("expected error for UDP flow")
+	}
+}
+
+func TestResetFlowWhitelist(t *testing.T) {
+	store := initTestDB(t)
+	w := &fakeWriter{}
+	r := NewResponder(false, 0, loader.WhitelistList{{Entry: "10.0.0.0/8"}}, nil, store)
+
+	// 未启用时不发送
+	r.ResetFlow(tcpFlow("192.0.2.10", "198.51.100.20"))
+
+	r.SetTCPResetter(&TCPResetter{writer: w})
+	r.ResetFlow(tcpFlow("10.1.2.3", "198.51.100.20"))
+	r.ResetFlow(tcpFlow("192.0.2.10", "10.1.2.3"))
+	if len(w.packets) != 0 {
+		t.Fatalf("whitelisted endpoints should not be reset, sent %d", len(w.packets))
+	}
+
+	r.ResetFlow(tcpFlow("192.0.2.10", "198.51.100.20"))
+	if len(w.packets) != 2 {
+		t.Errorf("expected 2 resets, got %d", len(w.packets))
+	}
+}
@@ -0,0 +1,339 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+	"go-ids/internal/server"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 白名单条目来源
+const (
+	WhitelistSourceConfig = "config" // config.yaml 中的条目，只能通过修改配置文件删除
+	WhitelistSourceAPI    = "api"    // 运行时通过 API 添加，保存在数据库中
+)
+
+// 白名单管理的错误
+var (
+	ErrWhitelistExists     = fmt.Errorf("白名单条目已存在: %w", server.ErrConflict)
+	ErrWhitelistNotFound   = fmt.Errorf("白名单条目不存在: %w", server.ErrNotFound)
+	ErrWhitelistFromConfig = fmt.Errorf("该条目来自配置文件，无法通过 API 删除: %w", server.ErrConflict)
+)
+
+// lookupTimeout 主机名条目的解析超时
+const lookupTimeout = 5 * time.Second
+
+// lookupHost 解析主机名条目，测试中可替换
+var lookupHost = func(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// whitelistRule 一个白名单条目及其覆盖的前缀
+type whitelistRule struct {
+	entry    db.WhitelistEntry
+	prefixes []netip.Prefix
+}
+
+// whitelist 按前缀索引的白名单，查找时按最长前缀匹配，由 Responder.mu 保护
+// 不同条目可能覆盖同一前缀 (如 10.0.0.1 与 10.0.0.1/32，或主机名解析出的地址)，
+// 这些条目都保留在索引中，任一条目未到期前缀即生效，各自的备注与有效期互不覆盖
+type whitelist struct {
+	rules    map[string]*whitelistRule // 规范化条目 -> 条目
+	prefixes map[netip.Prefix][]*whitelistRule
+}
+
+func newWhitelist() *whitelist {
+	return &whitelist{
+		rules:    make(map[string]*whitelistRule),
+		prefixes: make(map[netip.Prefix][]*whitelistRule),
+	}
+}
+
+// normalizeWhitelistEntry 返回条目的规范文本: 前缀按掩码对齐，IP 去掉 IPv4 映射，主机名转小写
+func normalizeWhitelistEntry(entry string) string {
+	entry = strings.TrimSpace(entry)
+	if p, err := netip.ParsePrefix(entry); err == nil {
+		return p.Masked().String()
+	}
+	if addr, err := netip.ParseAddr(entry); err == nil {
+		return addr.Unmap().WithZone("").String()
+	}
+	return strings.ToLower(strings.TrimSuffix(entry, "."))
+}
+
+// isHostname 粗略检查主机名格式
+func isHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseWhitelistEntry 解析白名单条目，返回覆盖的前缀；主机名在此时解析
+// 主机名解析失败时返回条目本身和错误，调用方决定是否保留未解析的条目
+func parseWhitelistEntry(entry string) (db.WhitelistEntry, []netip.Prefix, error) {
+	normalized := normalizeWhitelistEntry(entry)
+	e := db.WhitelistEntry{Entry: normalized}
+
+	if p, err := netip.ParsePrefix(normalized); err == nil {
+		return e, []netip.Prefix{p}, nil
+	}
+	if addr, err := netip.ParseAddr(normalized); err == nil {
+		return e, []netip.Prefix{netip.PrefixFrom(addr, addr.BitLen())}, nil
+	}
+	if !isHostname(normalized) {
+		return e, nil, fmt.Errorf("无效的白名单条目 %q: %w", entry, server.ErrInvalid)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	addrs, err := lookupHost(ctx, normalized)
+	if err != nil {
+		return e, nil, fmt.Errorf("解析白名单主机名 %s 失败: %v", normalized, err)
+	}
+	var prefixes []netip.Prefix
+	for _, addr := range addrs {
+		addr = addr.Unmap().WithZone("")
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		e.Resolved = append(e.Resolved, addr.String())
+	}
+	return e, prefixes, nil
+}
+
+// add 添加条目，调用方需保证条目尚不存在
+func (w *whitelist) add(entry db.WhitelistEntry, prefixes []netip.Prefix) {
+	rule := &whitelistRule{entry: entry, prefixes: prefixes}
+	w.rules[entry.Entry] = rule
+	w.index(rule)
+}
+
+// index 将条目加入前缀索引
+func (w *whitelist) index(rule *whitelistRule) {
+	for _, p := range rule.prefixes {
+		w.prefixes[p] = append(w.prefixes[p], rule)
+	}
+}
+
+func (w *whitelist) remove(entry string) {
+	delete(w.rules, entry)
+	// 重建索引，保证与被删条目重叠的其他条目仍然生效
+	w.prefixes = make(map[netip.Prefix][]*whitelistRule, len(w.prefixes))
+	for _, rule := range w.rules {
+		w.index(rule)
+	}
+}
+
+// match 返回覆盖 addr 的最长前缀条目
+func (w *whitelist) match(addr netip.Addr, now time.Time) *whitelistRule {
+	addr = addr.Unmap().WithZone("")
+	for bits := addr.BitLen(); bits >= 0; bits-- {
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			return nil
+		}
+		for _, rule := range w.prefixes[p] {
+			if !rule.entry.Expired(now) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// loadWhitelist 加载配置文件中的白名单条目
+func loadWhitelist(entries loader.WhitelistList) *whitelist {
+	w := newWhitelist()
+	now := time.Now()
+	for _, wc := range entries {
+		entry, prefixes, err := parseWhitelistEntry(wc.Entry)
+		if err != nil {
+			if !isHostname(entry.Entry) {
+				logrus.Errorf("忽略白名单条目: %v", err)
+				continue
+			}
+			logrus.Warnf("%v，该条目暂不生效", err)
+		}
+		if _, exists := w.rules[entry.Entry]; exists {
+			logrus.Errorf("忽略重复的白名单条目 %q: 与前面的条目 %s 相同", wc.Entry, entry.Entry)
+			continue
+		}
+		entry.Comment = wc.Comment
+		entry.Source = WhitelistSourceConfig
+		if wc.TTL > 0 {
+			expiresAt := now.Add(time.Duration(wc.TTL) * time.Second)
+			entry.ExpiresAt = &expiresAt
+		}
+		w.add(entry, prefixes)
+	}
+	return w
+}
+
+// IsWhitelisted 检查 IP 是否被白名单覆盖
+func (r *Responder) IsWhitelisted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.whitelist.match(addr, time.Now()) != nil
+}
+
+// LoadWhitelist 从数据库加载运行时添加的白名单条目，主机名在此时解析
+func (r *Responder) LoadWhitelist() error {
+	entries, err := r.store.ListWhitelistEntries()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, stored := range entries {
+		if stored.Expired(now) {
+			if err := r.store.DeleteWhitelistEntry(stored.Entry); err != nil {
+				logrus.Errorf("删除过期白名单条目失败: %v", err)
+			}
+			continue
+		}
+		entry, prefixes, err := parseWhitelistEntry(stored.Entry)
+		if err != nil {
+			logrus.Warnf("%v，该条目暂不生效", err)
+		}
+		stored.Resolved = entry.Resolved
+		stored.Source = WhitelistSourceAPI
+
+		r.mu.Lock()
+		if _, exists := r.whitelist.rules[stored.Entry]; !exists {
+			r.whitelist.add(stored, prefixes)
+		}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// Whitelist 返回所有白名单条目
+func (r *Responder) Whitelist() []db.WhitelistEntry {
+	r.mu.RLock()
+	entries := make([]db.WhitelistEntry, 0, len(r.whitelist.rules))
+	for _, rule := range r.whitelist.rules {
+		entries = append(entries, rule.entry)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Entry < entries[j].Entry })
+	return entries
+}
+
+// AddWhitelist 添加白名单条目并持久化，立即生效；条目覆盖的已封禁 IP 同时解除封禁
+// ttl 为 0 表示永久有效
+func (r *Responder) AddWhitelist(entry, comment, createdBy string, ttl time.Duration) (db.WhitelistEntry, error) {
+	e, prefixes, err := parseWhitelistEntry(entry)
+	if err != nil {
+		if errors.Is(err, server.ErrInvalid) {
+			return db.WhitelistEntry{}, err
+		}
+		// 主机名无法解析时拒绝添加，避免条目静默失效
+		return db.WhitelistEntry{}, fmt.Errorf("%v: %w", err, server.ErrInvalid)
+	}
+	e.CreatedAt = time.Now()
+	e.Comment = comment
+	e.CreatedBy = createdBy
+	if ttl > 0 {
+		expiresAt := e.CreatedAt.Add(ttl)
+		e.ExpiresAt = &expiresAt
+	}
+
+	r.mu.Lock()
+	if _, exists := r.whitelist.rules[e.Entry]; exists {
+		r.mu.Unlock()
+		return db.WhitelistEntry{}, ErrWhitelistExists
+	}
+	if err := r.store.SaveWhitelistEntry(&e); err != nil {
+		r.mu.Unlock()
+		return db.WhitelistEntry{}, err
+	}
+	e.Source = WhitelistSourceAPI
+	r.whitelist.add(e, prefixes)
+
+	var covered []string
+	for ip := range r.blocks {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		for _, p := range prefixes {
+			if p.Contains(addr.Unmap()) {
+				covered = append(covered, ip)
+				break
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	for _, ip := range covered {
+		if err := r.unblockIP(ip); err != nil {
+			logrus.Warnf("白名单 IP %s 解除封禁失败: %v", ip, err)
+		}
+	}
+	return e, nil
+}
+
+// RemoveWhitelist 删除通过 API 添加的白名单条目
+func (r *Responder) RemoveWhitelist(entry string) error {
+	entry = normalizeWhitelistEntry(entry)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule, exists := r.whitelist.rules[entry]
+	if !exists {
+		return ErrWhitelistNotFound
+	}
+	if rule.entry.Source == WhitelistSourceConfig {
+		return ErrWhitelistFromConfig
+	}
+	if err := r.store.DeleteWhitelistEntry(entry); err != nil {
+		return err
+	}
+	r.whitelist.remove(entry)
+	return nil
+}
+
+// ExpireWhitelist 移除在 now 之前到期的白名单条目
+func (r *Responder) ExpireWhitelist(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, rule := range r.whitelist.rules {
+		if !rule.entry.Expired(now) {
+			continue
+		}
+		if rule.entry.Source == WhitelistSourceAPI {
+			if err := r.store.DeleteWhitelistEntry(key); err != nil {
+				logrus.Errorf("删除过期白名单条目失败: %v", err)
+				continue
+			}
+		}
+		r.whitelist.remove(key)
+		logrus.Infof("白名单条目 %s 已到期", key)
+	}
+}
@@ -0,0 +1,221 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"go-ids/internal/loader"
+	"go-ids/internal/server"
+)
+
+func TestWhitelistManagement(t *testing.T) {
+	store := initTestDB(t)
+
+	runner := &fakeRunner{}
+	b := newTestBlocker(t, loader.FirewallConfig{Backend: loader.FirewallBackendIpset, Set: "bl"}, runner)
+	r := NewResponder(true, 0, loader.WhitelistList{{Entry: "127.0.0.1"}, {Entry: "::1"}}, b, store)
+
+	if err := r.Block("203.0.113.9", "manual", 0); err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsBlocked("203.0.113.9") {
+		t.Fatal("expected manual block")
+	}
+
+	// 加入白名单后立即解除封禁
+	if _, err := r.AddWhitelist("203.0.113.9", "scanner", "tester", 0); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsBlocked("203.0.113.9") {
+		t.Error("whitelisted IP should be unblocked")
+	}
+	if err := r.Block("203.0.113.9", "manual", 0); !errors.Is(err, server.ErrConflict) {
+		t.Errorf("blocking a whitelisted IP: got %v", err)
+	}
+	if _, err := r.AddWhitelist("203.0.113.9", "", "tester", 0); !errors.Is(err, server.ErrConflict) {
+		t.Errorf("duplicate whitelist entry: got %v", err)
+	}
+	if _, err := r.AddWhitelist("not an ip", "", "tester", 0); !errors.Is(err, server.ErrInvalid) {
+		t.Errorf("invalid whitelist entry: got %v", err)
+	}
+
+	// 配置文件中的条目不能通过 API 删除，不同文本形式的 IPv6 地址视为同一条目
+	if err := r.RemoveWhitelist("0:0:0:0:0:0:0:1"); !errors.Is(err, server.ErrConflict) {
+		t.Errorf("removing config entry: got %v", err)
+	}
+	if err := r.Unblock("198.51.100.1"); !errors.Is(err, server.ErrNotFound) {
+		t.Errorf("unblocking unknown IP: got %v", err)
+	}
+
+	// 运行时条目持久化，重启后重新加载
+	r2 := NewResponder(true, 0, nil, b, store)
+	if err := r2.LoadWhitelist(); err != nil {
+		t.Fatal(err)
+	}
+	if !r2.IsWhitelisted("203.0.113.9") {
+		t.Error("expected persisted whitelist entry after reload")
+	}
+
+	if err := r.RemoveWhitelist("203.0.113.9"); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsWhitelisted("203.0.113.9") {
+		t.Error("whitelist entry not removed")
+	}
+	if err := r.RemoveWhitelist("203.0.113.9"); !errors.Is(err, server.ErrNotFound) {
+		t.Errorf("removing missing entry: got %v", err)
+	}
+}
+
+func TestWhitelistPrefixes(t *testing.T) {
+	store := initTestDB(t)
+
+	r := NewResponder(true, 0, loader.WhitelistList{
+		{Entry: "10.0.0.0/8"},
+		{Entry: "10.1.2.3/16", Comment: "office"}, // 按掩码对齐为 10.1.0.0/16
+		{Entry: "2001:db8::/32"},
+		{Entry: "bad entry"},
+	}, nil, store)
+
+	cases := []struct {
+		ip    string
+		entry string // 空表示不在白名单中
+	}{
+		{"10.200.0.1", "10.0.0.0/8"},
+		{"10.1.9.9", "10.1.0.0/16"},
+		{"::ffff:10.1.0.1", "10.1.0.0/16"},
+		{"11.0.0.1", ""},
+		{"2001:db8:1::1", "2001:db8::/32"},
+		{"2001:db9::1", ""},
+		{"not-an-ip", ""},
+	}
+	for _, tc := range cases {
+		if got := r.IsWhitelisted(tc.ip); got != (tc.entry != "") {
+			t.Errorf("IsWhitelisted(%s) = %v", tc.ip, got)
+		}
+		if tc.entry == "" {
+			continue
+		}
+		rule := r.whitelist.match(netip.MustParseAddr(tc.ip), time.Now())
+		if rule == nil || rule.entry.Entry != tc.entry {
+			t.Errorf("match(%s) = %+v, want %s", tc.ip, rule, tc.entry)
+		}
+	}
+	if n := len(r.Whitelist()); n != 3 {
+		t.Errorf("expected invalid config entry to be skipped, got %d entries", n)
+	}
+
+	// 添加网段时解除其覆盖的封禁
+	r.Block("192.0.2.10", "manual", 0)
+	r.Block("192.0.3.10", "manual", 0)
+	if _, err := r.AddWhitelist("192.0.2.0/24", "", "tester", 0); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsBlocked("192.0.2.10") || !r.IsBlocked("192.0.3.10") {
+		t.Error("expected only IPs inside the new prefix to be unblocked")
+	}
+
+	// 删除较长前缀后，较短前缀仍然生效
+	if err := r.RemoveWhitelist("192.0.2.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsWhitelisted("192.0.2.10") {
+		t.Error("removed prefix still matches")
+	}
+	if !r.IsWhitelisted("10.1.0.1") {
+		t.Error("overlapping prefix lost")
+	}
+}
+
+func TestWhitelistDuplicates(t *testing.T) {
+	store := initTestDB(t)
+
+	r := NewResponder(true, 0, loader.WhitelistList{
+		{Entry: "10.0.0.0/8", Comment: "lab"},
+		{Entry: "10.9.9.9/8", Comment: "overwrites"}, // 规范化后与上一条相同，忽略
+		{Entry: "192.0.2.1", Comment: "scanner", TTL: 60},
+		{Entry: "192.0.2.1/32", Comment: "gateway"}, // 覆盖相同前缀的不同条目
+	}, nil, store)
+
+	entries := r.Whitelist()
+	if len(entries) != 3 {
+		t.Fatalf("expected the duplicate entry to be rejected, got %+v", entries)
+	}
+	if entries[0].Entry != "10.0.0.0/8" || entries[0].Comment != "lab" {
+		t.Errorf("first entry was overwritten: %+v", entries[0])
+	}
+	if entries[1].Comment != "scanner" || entries[1].ExpiresAt == nil || entries[2].Comment != "gateway" || entries[2].ExpiresAt != nil {
+		t.Errorf("entries sharing a prefix lost their comment or expiry: %+v", entries[1:])
+	}
+
+	// 带 TTL 的条目到期后，同一前缀上的永久条目仍然生效
+	addr := netip.MustParseAddr("192.0.2.1")
+	if rule := r.whitelist.match(addr, time.Now().Add(2*time.Minute)); rule == nil || rule.entry.Entry != "192.0.2.1/32" {
+		t.Errorf("match after expiry = %+v", rule)
+	}
+	r.ExpireWhitelist(time.Now().Add(2 * time.Minute))
+	if !r.IsWhitelisted("192.0.2.1") {
+		t.Error("permanent entry lost when the overlapping entry expired")
+	}
+	if err := r.RemoveWhitelist("192.0.2.1/32"); err != ErrWhitelistFromConfig {
+		t.Errorf("RemoveWhitelist() = %v", err)
+	}
+}
+
+func TestWhitelistTTL(t *testing.T) {
+	store := initTestDB(t)
+
+	r := NewResponder(true, 0, loader.WhitelistList{{Entry: "198.51.100.0/24", TTL: 60}}, nil, store)
+	if _, err := r.AddWhitelist("203.0.113.1", "", "tester", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsWhitelisted("198.51.100.7") || !r.IsWhitelisted("203.0.113.1") {
+		t.Fatal("expected entries to be active before expiry")
+	}
+
+	r.ExpireWhitelist(time.Now().Add(2 * time.Minute))
+	if r.IsWhitelisted("198.51.100.7") || r.IsWhitelisted("203.0.113.1") {
+		t.Error("expected entries to expire")
+	}
+	entries, err := store.ListWhitelistEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expired entry still in database: %+v", entries)
+	}
+}
+
+func TestWhitelistHostname(t *testing.T) {
+	store := initTestDB(t)
+
+	orig := lookupHost
+	t.Cleanup(func() { lookupHost = orig })
+	lookupHost = func(_ context.Context, host string) ([]netip.Addr, error) {
+		if host == "scanner.example.com" {
+			return []netip.Addr{netip.MustParseAddr("192.0.2.50"), netip.MustParseAddr("2001:db8::50")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	r := NewResponder(true, 0, loader.WhitelistList{{Entry: "Scanner.Example.com."}, {Entry: "gone.example.com"}}, nil, store)
+	if !r.IsWhitelisted("192.0.2.50") || !r.IsWhitelisted("2001:db8::50") {
+		t.Error("expected resolved addresses to be whitelisted")
+	}
+	if r.IsWhitelisted("192.0.2.51") {
+		t.Error("unexpected match")
+	}
+
+	// 无法解析的配置条目保留但不生效
+	entries := r.Whitelist()
+	if len(entries) != 2 || entries[1].Entry != "scanner.example.com" || len(entries[1].Resolved) != 2 {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	if _, err := r.AddWhitelist("unknown.example.com", "", "tester", 0); !errors.Is(err, server.ErrInvalid) {
+		t.Errorf("unresolvable hostname: got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package retention
+
+import (
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Result 一轮清理的结果
+type Result struct {
+	Alerts      int64 // 删除的告警数
+	FlowRecords int64 // 删除的流记录数
+	Payloads    int64 // 清空载荷的告警数
+	Reclaimed   int64 // 增量 VACUUM 归还给文件系统的字节数
+}
+
+// Pruner 按保留策略周期性地物理删除过期数据，并通过增量 VACUUM 回收空间
+type Pruner struct {
+	store db.MaintenanceRepository
+	cfg   loader.RetentionConfig
+}
+
+// New 按配置创建清理器
+func New(cfg loader.RetentionConfig, store db.MaintenanceRepository) *Pruner {
+	return &Pruner{store: store, cfg: cfg}
+}
+
+// cutoff 返回保留天数对应的截止时间，0 天表示不按时间清理
+func cutoff(now time.Time, days int) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+// Prune 执行一轮清理，出错时返回已完成部分的结果
+// 先清空载荷再删除告警，保证载荷的行数上限按剩余告警计算
+func (p *Pruner) Prune(now time.Time) (Result, error) {
+	var res Result
+	var err error
+	if res.Payloads, err = p.store.PrunePayloads(cutoff(now, p.cfg.Payloads.MaxAgeDays), p.cfg.Payloads.MaxRows); err != nil {
+		return res, err
+	}
+	if res.Alerts, err = p.store.PruneAlerts(cutoff(now, p.cfg.Alerts.MaxAgeDays), p.cfg.Alerts.MaxRows, p.cfg.PruneJudged); err != nil {
+		return res, err
+	}
+	if res.FlowRecords, err = p.store.PruneFlowRecords(cutoff(now, p.cfg.FlowRecords.MaxAgeDays), p.cfg.FlowRecords.MaxRows); err != nil {
+		return res, err
+	}
+	if res.Reclaimed, err = p.store.IncrementalVacuum(); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// Run 启动时执行一次清理，之后每隔 Interval 秒执行一次，直到 stop 被关闭
+func (p *Pruner) Run(stop <-chan struct{}) {
+	if p.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(p.cfg.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		p.run()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// run 执行一轮清理并记录日志
+func (p *Pruner) run() {
+	start := time.Now()
+	res, err := p.Prune(start)
+	if err != nil {
+		logrus.Errorf("数据清理失败: %v", err)
+		return
+	}
+	fields := logrus.Fields{
+		"alerts":       res.Alerts,
+		"flow_records": res.FlowRecords,
+		"payloads":     res.Payloads,
+		"reclaimed":    res.Reclaimed,
+		"duration":     time.Since(start).Round(time.Millisecond),
+	}
+	if res.Alerts+res.FlowRecords+res.Payloads > 0 {
+		logrus.WithFields(fields).Info("已按保留策略清理数据")
+	} else {
+		logrus.WithFields(fields).Debug("没有需要清理的数据")
+	}
+}
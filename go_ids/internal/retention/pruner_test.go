@@ -0,0 +1,157 @@
+package retention
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+)
+
+func TestPrune(t *testing.T) {
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	now := time.Now()
+	day := 24 * time.Hour
+	payload := strings.Repeat("ab", 4096)
+	alert := func(src string, age time.Duration) *db.Alert {
+		a := &db.Alert{CreatedAt: now.Add(-age), LastSeen: now.Add(-age), SourceIP: src, DestIP: "198.51.100.1", Type: "DDoS", Payload: payload, Count: 1}
+		if err := store.DB().Create(a).Error; err != nil {
+			t.Fatalf("create alert: %v", err)
+		}
+		return a
+	}
+	expired := alert("192.0.2.1", 100*day)
+	oldPayload := alert("192.0.2.2", 40*day)
+	active := alert("192.0.2.3", 100*day)
+	active.LastSeen = now
+	store.DB().Save(active)
+	fresh := alert("192.0.2.4", 0)
+	deleted := alert("192.0.2.5", 0)
+	store.DB().Delete(deleted)
+	judged := alert("192.0.2.6", 100*day)
+	judged.Verdict = "true_positive"
+	store.DB().Save(judged)
+	if err := store.AddAlertComment(&db.AlertComment{AlertID: expired.ID, Author: "test", Body: "old"}); err != nil {
+		t.Fatalf("AddAlertComment: %v", err)
+	}
+
+	records := []db.FlowRecord{
+		{CreatedAt: now.Add(-10 * day), EndTime: now.Add(-10 * day), SrcIP: "192.0.2.1"},
+		{CreatedAt: now, EndTime: now, SrcIP: "192.0.2.2"},
+		{CreatedAt: now, EndTime: now, SrcIP: "192.0.2.1", AlertID: &expired.ID},
+	}
+	if err := store.CreateFlowRecords(records); err != nil {
+		t.Fatalf("CreateFlowRecords: %v", err)
+	}
+	block := db.Block{IP: "192.0.2.1", AlertID: &expired.ID}
+	if err := store.SaveBlock(&block); err != nil {
+		t.Fatalf("SaveBlock: %v", err)
+	}
+	incident := db.Incident{Title: "test"}
+	if err := store.CreateIncident(&incident); err != nil {
+		t.Fatalf("CreateIncident: %v", err)
+	}
+	if err := store.AddIncidentEvent(&db.IncidentEvent{IncidentID: incident.ID, AlertID: &expired.ID}); err != nil {
+		t.Fatalf("AddIncidentEvent: %v", err)
+	}
+	if enabled, err := store.IncrementalVacuumEnabled(); err != nil || !enabled {
+		t.Errorf("IncrementalVacuumEnabled() = %v, %v on a new database", enabled, err)
+	}
+
+	cfg := loader.GetDefaultConfig().Retention
+	cfg.Alerts.MaxRows = 0
+	cfg.FlowRecords.MaxRows = 0
+	res, err := New(cfg, store).Prune(now)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if res.FlowRecords != 1 {
+		t.Errorf("deleted %d flow records, want 1", res.FlowRecords)
+	}
+
+	left := func() map[uint]db.Alert {
+		var alerts []db.Alert
+		store.DB().Unscoped().Where("source_ip LIKE ?", "192.0.2.%").Find(&alerts)
+		m := make(map[uint]db.Alert)
+		for _, a := range alerts {
+			m[a.ID] = a
+		}
+		return m
+	}
+	alerts := left()
+	if _, ok := alerts[expired.ID]; ok {
+		t.Error("expired alert was not deleted")
+	}
+	if _, ok := alerts[deleted.ID]; ok {
+		t.Error("soft-deleted alert was not purged")
+	}
+	if a, ok := alerts[oldPayload.ID]; !ok || a.Payload != "" {
+		t.Errorf("alert with old payload: present %v, payload length %d", ok, len(a.Payload))
+	}
+	if a, ok := alerts[active.ID]; !ok || a.Payload != "" {
+		t.Errorf("recently active alert: present %v, payload length %d", ok, len(a.Payload))
+	}
+	if a, ok := alerts[fresh.ID]; !ok || a.Payload != payload {
+		t.Error("fresh alert lost its payload")
+	}
+	if _, ok := alerts[judged.ID]; !ok {
+		t.Error("judged alert was deleted")
+	}
+	// 引用被删除告警的记录保留，只解除关联
+	var dangling int64
+	store.DB().Model(&db.FlowRecord{}).Where("alert_id = ?", expired.ID).Count(&dangling)
+	if dangling != 0 {
+		t.Errorf("%d flow records still reference the deleted alert", dangling)
+	}
+	var blocks []db.Block
+	store.DB().Unscoped().Find(&blocks)
+	if len(blocks) != 1 || blocks[0].AlertID != nil {
+		t.Errorf("unexpected blocks after prune: %+v", blocks)
+	}
+	var events []db.IncidentEvent
+	store.DB().Find(&events)
+	if len(events) != 1 || events[0].AlertID != nil {
+		t.Errorf("unexpected incident events after prune: %+v", events)
+	}
+	var comments int64
+	store.DB().Model(&db.AlertComment{}).Where("alert_id = ?", expired.ID).Count(&comments)
+	if comments != 0 {
+		t.Errorf("%d comments of deleted alert remain", comments)
+	}
+
+	stats, err := store.GetDBStats()
+	if err != nil {
+		t.Fatalf("GetDBStats: %v", err)
+	}
+	if stats.FreePages != 0 {
+		t.Errorf("%d free pages after incremental vacuum", stats.FreePages)
+	}
+	if stats.Rows["flow_records"] != 2 || stats.FileSize == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// 行数上限只保留最新的告警，已判定的告警不计入上限
+	cfg.Alerts.MaxRows = 1
+	if _, err := New(cfg, store).Prune(now); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	var total int64
+	store.DB().Unscoped().Model(&db.Alert{}).Count(&total)
+	if alerts := left(); total != 2 || len(alerts) != 2 || alerts[fresh.ID].ID != fresh.ID || alerts[judged.ID].ID != judged.ID {
+		t.Errorf("after row limit: %d alerts, %v", total, alerts)
+	}
+
+	// prune_judged 开启后已判定的告警同样按保留策略删除
+	cfg.PruneJudged = true
+	if _, err := New(cfg, store).Prune(now); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if alerts := left(); len(alerts) != 1 || alerts[fresh.ID].ID != fresh.ID {
+		t.Errorf("after pruning judged alerts: %v", alerts)
+	}
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+
+	"go-ids/internal/loader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDBStatsHandler reports the database size, row counts and the retention policy in effect
+func GetDBStatsHandler(c *gin.Context) {
+	stats, err := repo.GetDBStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var retention gin.H
+	if cfg := loader.GetConfig(); cfg != nil {
+		policy := func(p loader.RetentionPolicy) gin.H {
+			return gin.H{"max_age_days": p.MaxAgeDays, "max_rows": p.MaxRows}
+		}
+		retention = gin.H{
+			"interval":     cfg.Retention.Interval,
+			"alerts":       policy(cfg.Retention.Alerts),
+			"flow_records": policy(cfg.Retention.FlowRecords),
+			"payloads":     policy(cfg.Retention.Payloads),
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"database":  stats,
+		"retention": retention,
+	})
+}
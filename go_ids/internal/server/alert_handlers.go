@@ -0,0 +1,315 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ids/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// featureNames are the dataset column names of the stored feature vectors
+var featureNames []string
+
+// SetFeatureNames allows main to inject the feature names used by the model (from the scaler parameters)
+func SetFeatureNames(names []string) {
+	featureNames = names
+}
+
+// AlertFilterParams holds the alert filter query parameters shared by search and export
+// Labels may be repeated (?label=DDoS&label=Bot) or comma separated
+type AlertFilterParams struct {
+	Src           string     `form:"src"`
+	Dst           string     `form:"dst"`
+	Labels        []string   `form:"label"`
+	MinConfidence *float32   `form:"min_confidence" binding:"omitempty,gte=0,lte=1"`
+	MaxConfidence *float32   `form:"max_confidence" binding:"omitempty,gte=0,lte=1"`
+	Since         *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until         *time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Read          *bool      `form:"read"`
+	Q             string     `form:"q"`
+}
+
+// AlertSearchRequest holds the query parameters of GET /api/alerts
+type AlertSearchRequest struct {
+	AlertFilterParams
+	Cursor uint `form:"cursor"`
+	Limit  int  `form:"limit,default=50" binding:"min=1,max=1000"`
+}
+
+// validAddrFilter reports whether s is an IP address or a CIDR prefix
+func validAddrFilter(s string) bool {
+	if strings.Contains(s, "/") {
+		_, err := netip.ParsePrefix(s)
+		return err == nil
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// splitLabels flattens repeated and comma separated label parameters
+func splitLabels(params []string) []string {
+	var labels []string
+	for _, l := range params {
+		for _, label := range strings.Split(l, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				labels = append(labels, label)
+			}
+		}
+	}
+	return labels
+}
+
+// Filter validates the parameters and converts them to a db.AlertFilter
+func (p *AlertFilterParams) Filter() (db.AlertFilter, error) {
+	for _, addr := range []string{p.Src, p.Dst} {
+		if addr != "" && !validAddrFilter(addr) {
+			return db.AlertFilter{}, errors.New("src and dst must be an IP address or CIDR prefix")
+		}
+	}
+	if p.MinConfidence != nil && p.MaxConfidence != nil && *p.MinConfidence > *p.MaxConfidence {
+		return db.AlertFilter{}, errors.New("min_confidence must not exceed max_confidence")
+	}
+	if p.Since != nil && p.Until != nil && !p.Since.Before(*p.Until) {
+		return db.AlertFilter{}, errors.New("since must be before until")
+	}
+	return db.AlertFilter{
+		Source:        p.Src,
+		Dest:          p.Dst,
+		Labels:        splitLabels(p.Labels),
+		MinConfidence: p.MinConfidence,
+		MaxConfidence: p.MaxConfidence,
+		Since:         p.Since,
+		Until:         p.Until,
+		IsRead:        p.Read,
+		Query:         p.Q,
+	}, nil
+}
+
+// GetAlertsHandler searches alerts, newest first, with cursor pagination
+func GetAlertsHandler(c *gin.Context) {
+	var req AlertSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := req.Filter()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.Cursor, filter.Limit = req.Cursor, req.Limit
+
+	page, err := repo.SearchAlerts(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// AlertUpdateRequest is the payload for triaging an alert
+// Omitted fields are left unchanged; an empty verdict clears a previous verdict
+type AlertUpdateRequest struct {
+	Read         *bool   `json:"read"`
+	Acknowledged *bool   `json:"acknowledged"`
+	Verdict      *string `json:"verdict" binding:"omitempty,oneof=true_positive false_positive"`
+	Comment      string  `json:"comment"`
+}
+
+// AlertReadRequest marks several alerts as read or unread
+type AlertReadRequest struct {
+	IDs  []uint `json:"ids" binding:"required,min=1,max=1000"`
+	Read *bool  `json:"read"` // defaults to true
+}
+
+// loadAlert reads the alert named by the :id parameter, writing an error response on failure
+func loadAlert(c *gin.Context) (*db.Alert, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert id"})
+		return nil, false
+	}
+	alert, err := repo.GetAlert(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return alert, true
+}
+
+// AlertDetail is an alert together with the flows that produced it
+type AlertDetail struct {
+	*db.Alert
+	Flows []db.FlowRecord `json:"flows"`
+}
+
+// GetAlertHandler returns an alert with its comments and flow records
+func GetAlertHandler(c *gin.Context) {
+	alert, ok := loadAlert(c)
+	if !ok {
+		return
+	}
+	flows, err := repo.GetAlertFlows(alert.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, AlertDetail{Alert: alert, Flows: flows})
+}
+
+// UpdateAlertHandler marks an alert as read or acknowledged, records a verdict and adds comments
+func UpdateAlertHandler(c *gin.Context) {
+	var req AlertUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	alert, ok := loadAlert(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	who := actor(c)
+	target := strconv.FormatUint(uint64(alert.ID), 10)
+	changed := false
+	var changes [][2]string // 保存成功后写入审计日志的 (action, detail)
+	if req.Read != nil && *req.Read != alert.IsRead {
+		alert.IsRead = *req.Read
+		changed = true
+	}
+	if req.Acknowledged != nil && *req.Acknowledged != (alert.AckedAt != nil) {
+		alert.AckedAt, alert.AckedBy = nil, ""
+		if *req.Acknowledged {
+			alert.AckedAt, alert.AckedBy = &now, who
+			// 确认即视为已读
+			alert.IsRead = true
+		}
+		changed = true
+		changes = append(changes, [2]string{"alert_ack", strconv.FormatBool(*req.Acknowledged)})
+	}
+	if req.Verdict != nil && *req.Verdict != alert.Verdict {
+		changes = append(changes, [2]string{"alert_verdict", fmt.Sprintf("%q -> %q", alert.Verdict, *req.Verdict)})
+		alert.Verdict, alert.VerdictBy, alert.VerdictAt = *req.Verdict, "", nil
+		if alert.Verdict != "" {
+			alert.VerdictBy, alert.VerdictAt = who, &now
+		}
+		changed = true
+	}
+
+	if changed {
+		if err := repo.SaveAlertTriage(alert); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, change := range changes {
+			audit(c, change[0], target, change[1])
+		}
+	}
+	if body := strings.TrimSpace(req.Comment); body != "" {
+		comment := db.AlertComment{AlertID: alert.ID, CreatedAt: now, Author: who, Body: body}
+		if err := repo.AddAlertComment(&comment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		alert.Comments = append(alert.Comments, comment)
+		audit(c, "alert_comment", target, body)
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// MarkAlertsReadHandler sets the read state of several alerts at once
+func MarkAlertsReadHandler(c *gin.Context) {
+	var req AlertReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	read := req.Read == nil || *req.Read
+	updated, err := repo.MarkAlertsRead(req.IDs, read)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// datasetLabel is the training label of a judged alert: false positives were benign traffic
+func datasetLabel(alert db.Alert) string {
+	if alert.Verdict == db.VerdictFalsePositive {
+		return "Benign"
+	}
+	return alert.Type
+}
+
+// ExportFeedbackHandler streams judged alerts as a labeled CSV dataset
+// The columns match the training CSVs in model_training: the raw features followed by Label
+func ExportFeedbackHandler(c *gin.Context) {
+	verdict := c.Query("verdict")
+	switch verdict {
+	case "", db.VerdictTruePositive, db.VerdictFalsePositive:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "verdict must be true_positive or false_positive"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="feedback_%s.csv"`, time.Now().Format("20060102")))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	header := false
+	rows := 0
+	err := repo.EachJudgedAlert(verdict, func(alert db.Alert) error {
+		if !header {
+			w.Write(datasetHeader(len(alert.Features)))
+			header = true
+		}
+		record := make([]string, 0, len(alert.Features)+1)
+		for _, v := range alert.Features {
+			record = append(record, strconv.FormatFloat(float64(v), 'g', -1, 32))
+		}
+		rows++
+		return w.Write(append(record, datasetLabel(alert)))
+	})
+	if !header {
+		w.Write(datasetHeader(len(featureNames)))
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		// 响应头已发送，只能记录日志
+		logrus.Errorf("failed to export feedback dataset after %d rows: %v", rows, err)
+		return
+	}
+	audit(c, "feedback_export", verdict, fmt.Sprintf("%d rows", rows))
+}
+
+// datasetHeader returns the CSV header for feature vectors of length n
+func datasetHeader(n int) []string {
+	header := make([]string, 0, n+1)
+	for i := 0; i < n; i++ {
+		if i < len(featureNames) {
+			header = append(header, featureNames[i])
+		} else {
+			header = append(header, fmt.Sprintf("feature_%d", i))
+		}
+	}
+	return append(header, "Label")
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ids/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// sessionCookie carries the login token for the web console; EventSource cannot set an Authorization header
+const sessionCookie = "ids_session"
+
+// identityKey is the gin context key of the authenticated auth.Identity
+const identityKey = "identity"
+
+// authenticator is nil when authentication is disabled
+var authenticator *auth.Authenticator
+
+// SetAuthenticator allows main to enable authentication; nil disables it
+func SetAuthenticator(a *auth.Authenticator) {
+	authenticator = a
+}
+
+// identity returns the caller authenticated by the authenticate middleware
+func identity(c *gin.Context) auth.Identity {
+	if id, ok := c.Get(identityKey); ok {
+		return id.(auth.Identity)
+	}
+	return auth.Anonymous
+}
+
+// errNoCredentials means the request carried neither an API key nor a token
+var errNoCredentials = errors.New("authentication required")
+
+// credentials resolves the caller from an API key, a bearer token or the session cookie
+func credentials(c *gin.Context) (auth.Identity, error) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		if id, ok := authenticator.APIKey(key); ok {
+			return id, nil
+		}
+		return auth.Identity{}, errors.New("invalid API key")
+	}
+	token, _ := c.Cookie(sessionCookie)
+	if header := c.GetHeader("Authorization"); header != "" {
+		bearer, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return auth.Identity{}, errors.New("unsupported authorization scheme")
+		}
+		// 脚本也可以把 API Key 作为 Bearer 令牌发送
+		if strings.HasPrefix(bearer, auth.APIKeyPrefix) {
+			if id, ok := authenticator.APIKey(bearer); ok {
+				return id, nil
+			}
+			return auth.Identity{}, errors.New("invalid API key")
+		}
+		token = bearer
+	}
+	if token == "" {
+		return auth.Identity{}, errNoCredentials
+	}
+	return authenticator.VerifyToken(token)
+}
+
+// clientCertIdentity returns the caller identified by a verified TLS client certificate
+func clientCertIdentity(c *gin.Context, role string) (auth.Identity, bool) {
+	if role == "" || c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return auth.Identity{}, false
+	}
+	cert := c.Request.TLS.VerifiedChains[0][0]
+	name := cert.Subject.CommonName
+	if name == "" && len(cert.DNSNames) > 0 {
+		name = cert.DNSNames[0]
+	}
+	return auth.Identity{Name: "cert:" + name, Role: role, Method: auth.MethodClientCert}, true
+}
+
+// authenticate rejects requests without valid credentials; with authentication disabled every caller is admin
+// Requests without credentials but with a verified client certificate get certRole, if configured
+func authenticate(certRole string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authenticator == nil {
+			c.Set(identityKey, auth.Anonymous)
+			return
+		}
+		id, err := credentials(c)
+		if errors.Is(err, errNoCredentials) {
+			if certID, ok := clientCertIdentity(c, certRole); ok {
+				id, err = certID, nil
+			}
+		}
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="go-ids"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(identityKey, id)
+	}
+}
+
+// requireRole rejects callers whose role is below role
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !identity(c).Allows(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "requires the " + role + " role"})
+		}
+	}
+}
+
+// LoginRequest is the payload of POST /api/auth/login
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// LoginHandler checks a username and password and issues a token, also set as the session cookie
+// Repeated failures lock out the client address, and the username from that address, for a growing period (429)
+func LoginHandler(c *gin.Context) {
+	if authenticator == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "authentication is disabled"})
+		return
+	}
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The remote address is used rather than ClientIP, which trusts X-Forwarded-For sent by anyone.
+	// Usernames are only throttled per address so that nobody can lock a named account out from elsewhere,
+	// and a successful login to another account from the same address does not reset the guesses
+	remote := c.RemoteIP()
+	keys := []string{"ip:" + remote, "ip:" + remote + "|user:" + req.Username}
+	if wait := logins.retryAfter(keys...); wait > 0 {
+		seconds := int(wait.Round(time.Second) / time.Second)
+		c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed logins, try again later"})
+		return
+	}
+	id, err := authenticator.Login(req.Username, req.Password)
+	if err != nil {
+		logins.fail(keys...)
+		logrus.WithFields(logrus.Fields{"user": req.Username, "client": c.ClientIP()}).Warn("login failed")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	logins.succeed(keys...)
+	token, expires, err := authenticator.IssueToken(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	setSessionCookie(c, token, int(time.Until(expires).Seconds()))
+	c.Set(identityKey, id)
+	audit(c, "login", id.Name, "")
+	c.JSON(http.StatusOK, gin.H{"token": token, "expires_at": expires, "user": id})
+}
+
+// LogoutHandler clears the session cookie
+// Tokens are stateless: a token copied elsewhere stays valid until it expires
+func LogoutHandler(c *gin.Context) {
+	setSessionCookie(c, "", -1)
+	c.Status(http.StatusNoContent)
+}
+
+// MeHandler returns the authenticated caller
+func MeHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"user": identity(c), "auth_enabled": authenticator != nil})
+}
+
+// setSessionCookie writes the session cookie; a negative maxAge deletes it
+func setSessionCookie(c *gin.Context, token string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/api",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -2,26 +2,22 @@ package server
 
 import (
 	"net/http"
-	"strconv"
 	"time"
 
-	"go-ids/internal/db"
+	"go-ids/internal/metrics"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetAlertsHandler retrieves historical alerts
-func GetAlertsHandler(c *gin.Context) {
-	limitStr := c.DefaultQuery("limit", "50")
-	limit, _ := strconv.Atoi(limitStr)
-
-	alerts, err := db.GetRecentAlerts(limit)
+// GetBlocksHandler lists the active firewall blocks
+func GetBlocksHandler(c *gin.Context) {
+	blocks, err := repo.ListBlocks()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, alerts)
+	c.JSON(http.StatusOK, blocks)
 }
 
 // SystemStatusHandler returns simple system stats
@@ -37,6 +33,12 @@ func SystemStatusHandler(c *gin.Context) {
 		flowList = flowCounter.GetRecentFlows(20) // Top 20
 	}
 
+	var pipeline *metrics.Snapshot
+	if statsCollector != nil {
+		snap := statsCollector.Latest()
+		pipeline = &snap
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":       "running",
 		"server_time":  time.Now(),
@@ -47,14 +49,24 @@ func SystemStatusHandler(c *gin.Context) {
 		"traffic_out":  out, // Mbps
 		"active_flows": activeFlows,
 		"flow_list":    flowList,
+		"pipeline":     pipeline,
 	})
 }
 
+// GetPipelineStatsHandler returns capture drops and per-stage pipeline counters
+func GetPipelineStatsHandler(c *gin.Context) {
+	if statsCollector == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "stats collector not initialized"})
+		return
+	}
+	c.JSON(http.StatusOK, statsCollector.Latest())
+}
+
 // GetThreatStatsHandler handles the chart data request
 func GetThreatStatsHandler(c *gin.Context) {
 	rangeType := c.DefaultQuery("range", "Day") // Day, Week, Month
 
-	points, err := db.GetThreatStats(rangeType)
+	points, err := repo.GetThreatStats(rangeType)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
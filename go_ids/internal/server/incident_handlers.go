@@ -0,0 +1,147 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ids/internal/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// IncidentUpdateRequest is the payload for working an incident
+// Omitted fields are left unchanged; an empty assignee unassigns the incident
+type IncidentUpdateRequest struct {
+	Status   *string `json:"status" binding:"omitempty,oneof=open acknowledged closed"`
+	Assignee *string `json:"assignee"`
+	Comment  string  `json:"comment"`
+}
+
+// IncidentDetail is an incident together with its alerts
+type IncidentDetail struct {
+	*db.Incident
+	Alerts []db.Alert `json:"alerts"`
+}
+
+// IncidentListRequest holds the query parameters of GET /api/incidents
+type IncidentListRequest struct {
+	Status string `form:"status"`
+	Limit  int    `form:"limit,default=50" binding:"min=1,max=1000"`
+}
+
+// GetIncidentsHandler lists incidents, most recently active first
+func GetIncidentsHandler(c *gin.Context) {
+	var req IncidentListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch req.Status {
+	case "", db.IncidentOpen, db.IncidentAcknowledged, db.IncidentClosed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, acknowledged or closed"})
+		return
+	}
+
+	incidents, err := repo.ListIncidents(req.Status, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, incidents)
+}
+
+// loadIncident reads the incident named by the :id parameter, writing an error response on failure
+func loadIncident(c *gin.Context) (*db.Incident, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid incident id"})
+		return nil, false
+	}
+	incident, err := repo.GetIncident(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "incident not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return incident, true
+}
+
+// GetIncidentHandler returns an incident with its timeline and alerts
+func GetIncidentHandler(c *gin.Context) {
+	incident, ok := loadIncident(c)
+	if !ok {
+		return
+	}
+	alerts, err := repo.GetIncidentAlerts(incident.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, IncidentDetail{Incident: incident, Alerts: alerts})
+}
+
+// UpdateIncidentHandler changes the status or assignee of an incident and adds comments
+func UpdateIncidentHandler(c *gin.Context) {
+	var req IncidentUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	incident, ok := loadIncident(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	var events []db.IncidentEvent
+	if req.Status != nil && *req.Status != incident.Status {
+		events = append(events, db.IncidentEvent{
+			Kind:   db.IncidentEventStatus,
+			Detail: incident.Status + " -> " + *req.Status,
+		})
+		incident.Status = *req.Status
+		incident.ClosedAt = nil
+		if incident.Status == db.IncidentClosed {
+			incident.ClosedAt = &now
+		}
+	}
+	if req.Assignee != nil && *req.Assignee != incident.Assignee {
+		events = append(events, db.IncidentEvent{Kind: db.IncidentEventAssignee, Detail: *req.Assignee})
+		incident.Assignee = *req.Assignee
+	}
+	if comment := strings.TrimSpace(req.Comment); comment != "" {
+		events = append(events, db.IncidentEvent{Kind: db.IncidentEventComment, Detail: comment})
+	}
+	if len(events) == 0 {
+		c.JSON(http.StatusOK, incident)
+		return
+	}
+
+	if err := repo.SaveIncident(incident); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range events {
+		events[i].IncidentID = incident.ID
+		events[i].CreatedAt = now
+		events[i].Actor = actor(c)
+		if err := repo.AddIncidentEvent(&events[i]); err != nil {
+			logrus.Errorf("failed to write incident timeline: %v", err)
+			continue
+		}
+		incident.Timeline = append(incident.Timeline, events[i])
+		audit(c, "incident_"+events[i].Kind, strconv.FormatUint(uint64(incident.ID), 10), events[i].Detail)
+	}
+
+	Manager.Publish(EventIncident, *incident)
+	c.JSON(http.StatusOK, incident)
+}
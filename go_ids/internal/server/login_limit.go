@@ -0,0 +1,96 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// loginFreeAttempts failed logins are allowed before a client or a username from that client is locked out
+	loginFreeAttempts = 5
+	// loginLockout is the first lockout; every further failure doubles it up to loginMaxLockout
+	loginLockout    = 30 * time.Second
+	loginMaxLockout = 15 * time.Minute
+	// loginMaxEntries bounds the tracked keys; idle entries are dropped beyond it
+	loginMaxEntries = 10000
+)
+
+// loginFailures counts the consecutive failed logins of a key
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// loginLimiter slows down password guessing by locking out the keys of a login after repeated failures
+// Every key passed to retryAfter must be clear, and a failure counts against all of them
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	now      func() time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{failures: make(map[string]*loginFailures), now: time.Now}
+}
+
+// logins limits POST /api/auth/login
+var logins = newLoginLimiter()
+
+// retryAfter returns how long the caller must wait before trying again, zero when none of keys is locked out
+func (l *loginLimiter) retryAfter(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var wait time.Duration
+	for _, key := range keys {
+		if f, ok := l.failures[key]; ok && f.lockedUntil.Sub(now) > wait {
+			wait = f.lockedUntil.Sub(now)
+		}
+	}
+	return wait
+}
+
+// fail records a failed login for keys
+func (l *loginLimiter) fail(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if len(l.failures) >= loginMaxEntries {
+		l.sweep(now)
+	}
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok {
+			f = &loginFailures{}
+			l.failures[key] = f
+		}
+		f.count++
+		f.last = now
+		if f.count >= loginFreeAttempts {
+			lockout := loginMaxLockout
+			if shift := f.count - loginFreeAttempts; shift < 16 {
+				lockout = min(loginLockout<<shift, loginMaxLockout)
+			}
+			f.lockedUntil = now.Add(lockout)
+		}
+	}
+}
+
+// succeed forgets the failures of keys after a successful login
+func (l *loginLimiter) succeed(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+// sweep drops the entries that are not locked out and have been idle for the longest lockout
+func (l *loginLimiter) sweep(now time.Time) {
+	for key, f := range l.failures {
+		if now.After(f.lockedUntil) && now.Sub(f.last) > loginMaxLockout {
+			delete(l.failures, key)
+		}
+	}
+}
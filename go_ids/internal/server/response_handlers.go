@@ -0,0 +1,209 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Errors returned by a BlockManager are classified with these sentinels
+// (wrapped via %w) so handlers can pick the HTTP status code
+var (
+	ErrInvalid  = errors.New("invalid request")
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+)
+
+// BlockManager manages blocks and the whitelist at runtime
+// It is implemented by response.Responder and injected by main (response imports server)
+type BlockManager interface {
+	Block(ip, reason string, duration time.Duration) error
+	Unblock(ip string) error
+	Whitelist() []db.WhitelistEntry
+	AddWhitelist(entry, comment, createdBy string, ttl time.Duration) (db.WhitelistEntry, error)
+	RemoveWhitelist(entry string) error
+}
+
+var blockManager BlockManager
+
+// SetBlockManager allows main to inject the responder
+func SetBlockManager(m BlockManager) {
+	blockManager = m
+}
+
+// BlockRequest is the payload for a manual block
+type BlockRequest struct {
+	IP     string `json:"ip" binding:"required"`
+	Reason string `json:"reason"`
+	// Duration in seconds; omitted uses response.block_duration, 0 blocks permanently
+	Duration *int `json:"duration" binding:"omitempty,gte=0"`
+}
+
+// WhitelistRequest is the payload for adding a whitelist entry
+// Entry may be an IP, a CIDR prefix or a hostname (resolved when added)
+type WhitelistRequest struct {
+	Entry   string `json:"entry" binding:"required"`
+	Comment string `json:"comment"`
+	// TTL in seconds; 0 or omitted keeps the entry until it is removed
+	TTL int `json:"ttl" binding:"gte=0"`
+}
+
+// errorStatus maps a BlockManager error to an HTTP status code
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// actor identifies who made a manual change: the authenticated user or API key, otherwise the client address
+func actor(c *gin.Context) string {
+	if name := identity(c).Name; name != "" {
+		return name
+	}
+	return c.ClientIP()
+}
+
+// audit appends a manual change to the audit trail
+func audit(c *gin.Context, action, target, detail string) {
+	entry := &db.AuditLog{
+		CreatedAt: time.Now(),
+		Actor:     actor(c),
+		Action:    action,
+		Target:    target,
+		Detail:    detail,
+	}
+	if err := repo.CreateAuditLog(entry); err != nil {
+		logrus.Errorf("failed to write audit log: %v", err)
+	}
+	logrus.WithFields(logrus.Fields{"actor": entry.Actor, "target": target}).Infof("audit: %s %s", action, detail)
+}
+
+func requireBlockManager(c *gin.Context) bool {
+	if blockManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "responder not initialized"})
+		return false
+	}
+	return true
+}
+
+// CreateBlockHandler blocks an address manually
+func CreateBlockHandler(c *gin.Context) {
+	if !requireBlockManager(c) {
+		return
+	}
+	var req BlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	seconds := 0
+	if req.Duration != nil {
+		seconds = *req.Duration
+	} else if cfg := loader.GetConfig(); cfg != nil {
+		seconds = cfg.Response.BlockDuration
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = "manual"
+	}
+
+	if err := blockManager.Block(req.IP, reason, time.Duration(seconds)*time.Second); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, "block", req.IP, "reason="+reason+" duration="+strconv.Itoa(seconds)+"s")
+	c.JSON(http.StatusCreated, gin.H{"ip": req.IP, "reason": reason, "duration": seconds})
+}
+
+// DeleteBlockHandler lifts a block
+func DeleteBlockHandler(c *gin.Context) {
+	if !requireBlockManager(c) {
+		return
+	}
+	ip := c.Param("ip")
+	if err := blockManager.Unblock(ip); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, "unblock", ip, "")
+	c.Status(http.StatusNoContent)
+}
+
+// GetWhitelistHandler lists the whitelist, including entries from config.yaml
+func GetWhitelistHandler(c *gin.Context) {
+	if !requireBlockManager(c) {
+		return
+	}
+	c.JSON(http.StatusOK, blockManager.Whitelist())
+}
+
+// CreateWhitelistHandler adds a whitelist entry
+func CreateWhitelistHandler(c *gin.Context) {
+	if !requireBlockManager(c) {
+		return
+	}
+	var req WhitelistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := blockManager.AddWhitelist(req.Entry, req.Comment, actor(c), time.Duration(req.TTL)*time.Second)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, "whitelist_add", entry.Entry, req.Comment)
+	c.JSON(http.StatusCreated, entry)
+}
+
+// DeleteWhitelistHandler removes a whitelist entry added through the API
+// The entry is taken from the wildcard path so CIDR prefixes ("10.0.0.0/8") work
+func DeleteWhitelistHandler(c *gin.Context) {
+	if !requireBlockManager(c) {
+		return
+	}
+	entry := strings.TrimPrefix(c.Param("entry"), "/")
+	if err := blockManager.RemoveWhitelist(entry); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, "whitelist_remove", entry, "")
+	c.Status(http.StatusNoContent)
+}
+
+// AuditLogRequest holds the query parameters of GET /api/audit
+type AuditLogRequest struct {
+	Limit int `form:"limit,default=100" binding:"min=1,max=1000"`
+}
+
+// GetAuditLogsHandler returns the audit trail, newest first
+func GetAuditLogsHandler(c *gin.Context) {
+	var req AuditLogRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logs, err := repo.GetAuditLogs(req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, logs)
+}
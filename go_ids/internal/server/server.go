@@ -1,9 +1,17 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
 	"sync"
 	"time"
 
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+	"go-ids/internal/metrics"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
@@ -15,10 +23,11 @@ var StartTime time.Time
 // though we can import standard flow package if no cycle.
 // FlowBrief contains summary info for dashboard validation
 type FlowBrief struct {
-	SrcPort  uint16 `json:"src_port"`
-	DstPort  uint16 `json:"dst_port"`
-	Protocol string `json:"protocol"` // "TCP" or "UDP"
-	Duration string `json:"duration"` // e.g. "12s"
+	SrcPort   uint16 `json:"src_port"`
+	DstPort   uint16 `json:"dst_port"`
+	Protocol  string `json:"protocol"`  // "TCP" or "UDP"
+	Duration  string `json:"duration"`  // e.g. "12s"
+	Interface string `json:"interface"` // Ingress interface
 }
 
 // FlowCounter Interface to avoid strict dependency coupling if needed,
@@ -35,6 +44,20 @@ func SetFlowCounter(fc FlowCounter) {
 	flowCounter = fc
 }
 
+var statsCollector *metrics.Collector
+
+var repo db.Repository
+
+// SetRepository allows main to inject the persistence layer used by the handlers
+func SetRepository(r db.Repository) {
+	repo = r
+}
+
+// SetStatsCollector allows main to inject the capture/pipeline stats collector
+func SetStatsCollector(c *metrics.Collector) {
+	statsCollector = c
+}
+
 // TrafficTracker manages real-time bandwidth statistics
 type TrafficTracker struct {
 	BytesIn  uint64  // Rx (Download)
@@ -61,8 +84,148 @@ func GetRates() (float64, float64) {
 	return stats.RateIn, stats.RateOut
 }
 
-// StartServer initializes and runs the Gin HTTP server
-func StartServer(port string) error {
+// corsConfig allows the configured origins to call the API
+// Credentials (the session cookie) are only allowed for explicitly listed origins
+func corsConfig(origins []string) cors.Config {
+	cfg := cors.Config{
+		AllowMethods:     []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}
+	for _, origin := range origins {
+		if origin == "*" {
+			cfg.AllowAllOrigins, cfg.AllowCredentials = true, false
+			return cfg
+		}
+	}
+	cfg.AllowOrigins = origins
+	return cfg
+}
+
+// newRouter builds the Gin engine with CORS, authentication and the API routes
+// Routes are grouped by the lowest role allowed to call them
+func newRouter(cfg loader.ServerConfig) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	// Custom logger middleware could be added here
+
+	// 未配置来源时不启用 CORS，只允许同源访问
+	if len(cfg.CORSOrigins) > 0 {
+		r.Use(cors.New(corsConfig(cfg.CORSOrigins)))
+	}
+
+	api := r.Group("/api")
+	api.POST("/auth/login", LoginHandler)
+	api.POST("/auth/logout", LogoutHandler)
+
+	authed := api.Group("", authenticate(cfg.TLS.ClientCertRole))
+	authed.GET("/auth/me", MeHandler)
+
+	viewer := authed.Group("", requireRole(loader.RoleViewer))
+	{
+		viewer.GET("/events", ServerSentEventsHandler)
+		viewer.GET("/alerts", GetAlertsHandler)
+		viewer.GET("/alerts/:id", GetAlertHandler)
+		viewer.GET("/blocks", GetBlocksHandler)
+		viewer.GET("/whitelist", GetWhitelistHandler)
+		viewer.GET("/incidents", GetIncidentsHandler)
+		viewer.GET("/incidents/:id", GetIncidentHandler)
+		viewer.GET("/status", SystemStatusHandler)
+		viewer.GET("/stats/threats", GetThreatStatsHandler)
+		viewer.GET("/stats/alerts", GetAlertStatsHandler)
+		viewer.GET("/stats/pipeline", GetPipelineStatsHandler)
+		viewer.GET("/engine/status", GetEngineStatusHandler)
+	}
+
+	analyst := authed.Group("", requireRole(loader.RoleAnalyst))
+	{
+		analyst.POST("/alerts/read", MarkAlertsReadHandler)
+		analyst.PATCH("/alerts/:id", UpdateAlertHandler)
+		analyst.GET("/feedback/dataset", ExportFeedbackHandler)
+		analyst.GET("/export/alerts", ExportAlertsHandler)
+		analyst.GET("/export/flows", ExportFlowsHandler)
+		analyst.POST("/blocks", CreateBlockHandler)
+		analyst.DELETE("/blocks/:ip", DeleteBlockHandler)
+		analyst.POST("/whitelist", CreateWhitelistHandler)
+		analyst.DELETE("/whitelist/*entry", DeleteWhitelistHandler)
+		analyst.PATCH("/incidents/:id", UpdateIncidentHandler)
+	}
+
+	admin := authed.Group("", requireRole(loader.RoleAdmin))
+	{
+		admin.GET("/audit", GetAuditLogsHandler)
+		admin.GET("/admin/db", GetDBStatsHandler)
+		// AI 引擎配置会改写 config.yaml
+		admin.POST("/engine/config", UpdateEngineConfigHandler)
+	}
+	return r
+}
+
+var (
+	srvMu      sync.Mutex
+	httpServer *http.Server
+	listener   net.Listener
+	certs      *certReloader
+)
+
+// closing is closed when the server shuts down so long-lived SSE streams return
+var closing = make(chan struct{})
+
+// StartServer initializes and runs the HTTP(S) server on the configured address
+// It blocks until the server fails or Shutdown is called, in which case it returns nil
+func StartServer(cfg loader.ServerConfig) error {
+	if err := Listen(cfg); err != nil {
+		return err
+	}
+	return Serve()
+}
+
+// Listen loads the TLS material and binds the configured address without serving requests yet,
+// so a bad certificate or a busy port fails the startup instead of a background goroutine
+func Listen(cfg loader.ServerConfig) error {
+	// Set Gin mode
+	gin.SetMode(gin.ReleaseMode)
+
+	srv := &http.Server{
+		Addr:              cfg.Address(),
+		Handler:           newRouter(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	var reloader *certReloader
+	if cfg.TLS.Enabled {
+		var err error
+		if reloader, err = newCertReloader(cfg.TLS); err != nil {
+			return err
+		}
+		srv.TLSConfig = reloader.tlsConfig()
+	}
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	srv.RegisterOnShutdown(func() { close(closing) })
+
+	srvMu.Lock()
+	httpServer, listener, certs = srv, ln, reloader
+	srvMu.Unlock()
+
+	if reloader != nil && cfg.TLS.ReloadInterval > 0 {
+		go reloader.watch(time.Duration(cfg.TLS.ReloadInterval)*time.Second, closing)
+	}
+	return nil
+}
+
+// Serve handles requests on the address bound by Listen
+// It blocks until the server fails or Shutdown is called, in which case it returns nil
+func Serve() error {
+	srvMu.Lock()
+	srv, ln, reloader := httpServer, listener, certs
+	srvMu.Unlock()
+	if srv == nil {
+		return errors.New("server is not listening")
+	}
 	StartTime = time.Now()
 
 	// Start Traffic Monitor Ticker (1s interval)
@@ -89,39 +252,41 @@ func StartServer(port string) error {
 		}
 	}()
 
-	// Set Gin mode
-	gin.SetMode(gin.ReleaseMode)
-
-	r := gin.New()
-	r.Use(gin.Recovery())
-	// Custom logger middleware could be added here
-
-	// CORS Configuration
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"}, // Allow all for dev, restrict in prod
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	// API Routes
-	api := r.Group("/api")
-	{
-		api.GET("/events", ServerSentEventsHandler)
-		api.GET("/alerts", GetAlertsHandler)
-		api.GET("/status", SystemStatusHandler)
-		api.GET("/stats/threats", GetThreatStatsHandler)
-
-		// AI 引擎管理路由
-		api.GET("/engine/status", GetEngineStatusHandler)
-		api.POST("/engine/config", UpdateEngineConfigHandler)
-	}
-
 	// Start SSE Manager
 	go Manager.Listen()
 
 	// Run Server
-	return r.Run(":" + port)
+	var err error
+	if reloader != nil {
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		err = srv.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until ctx is done
+// Event streams are closed so they do not hold the shutdown until the deadline
+func Shutdown(ctx context.Context) error {
+	srvMu.Lock()
+	srv := httpServer
+	srvMu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+// ReloadTLS reloads the certificate, key and client CA files; it does nothing when TLS is disabled
+func ReloadTLS() error {
+	srvMu.Lock()
+	reloader := certs
+	srvMu.Unlock()
+	if reloader == nil {
+		return nil
+	}
+	return reloader.Reload()
 }
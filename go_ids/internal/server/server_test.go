@@ -0,0 +1,325 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ids/internal/auth"
+	"go-ids/internal/db"
+	"go-ids/internal/loader"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testPassword 是测试用户共用的密码
+const testPassword = "s3cret"
+
+// setTestAuthenticator 开启认证，每个角色各有一个同名用户，测试结束后恢复为未开启
+func setTestAuthenticator(t *testing.T) *auth.Authenticator {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []loader.AuthUser
+	for _, role := range []string{loader.RoleViewer, loader.RoleAnalyst, loader.RoleAdmin} {
+		users = append(users, loader.AuthUser{Username: role, PasswordHash: string(hash), Role: role})
+	}
+	a, err := auth.New(loader.AuthConfig{Enabled: true, TokenTTL: 600, Users: users})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetAuthenticator(a)
+	logins = newLoginLimiter()
+	t.Cleanup(func() { SetAuthenticator(nil) })
+	return a
+}
+
+// setTestRepository 使用临时 SQLite 数据库，测试结束后恢复
+func setTestRepository(t *testing.T) *db.Store {
+	t.Helper()
+	store, err := db.InitDB(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	SetRepository(store)
+	t.Cleanup(func() {
+		SetRepository(nil)
+		store.Close()
+	})
+	return store
+}
+
+// serve 发送请求，token 不为空时作为 Bearer 令牌
+func serve(r http.Handler, method, path, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// login 以用户名登录并返回令牌
+func login(t *testing.T, r http.Handler, username string) string {
+	t.Helper()
+	w := serve(r, http.MethodPost, "/api/auth/login", "", `{"username":"`+username+`","password":"`+testPassword+`"}`)
+	var resp struct{ Token string }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusOK || err != nil || resp.Token == "" {
+		t.Fatalf("login %s: %d %s", username, w.Code, w.Body)
+	}
+	return resp.Token
+}
+
+// routeRoles 每个需要认证的路由允许的最低角色，新增路由时必须在这里登记
+var routeRoles = map[string]string{
+	"GET /api/auth/me":             "",
+	"GET /api/events":              loader.RoleViewer,
+	"GET /api/alerts":              loader.RoleViewer,
+	"GET /api/alerts/:id":          loader.RoleViewer,
+	"GET /api/blocks":              loader.RoleViewer,
+	"GET /api/whitelist":           loader.RoleViewer,
+	"GET /api/incidents":           loader.RoleViewer,
+	"GET /api/incidents/:id":       loader.RoleViewer,
+	"GET /api/status":              loader.RoleViewer,
+	"GET /api/stats/threats":       loader.RoleViewer,
+	"GET /api/stats/alerts":        loader.RoleViewer,
+	"GET /api/stats/pipeline":      loader.RoleViewer,
+	"GET /api/engine/status":       loader.RoleViewer,
+	"POST /api/alerts/read":        loader.RoleAnalyst,
+	"PATCH /api/alerts/:id":        loader.RoleAnalyst,
+	"GET /api/feedback/dataset":    loader.RoleAnalyst,
+	"GET /api/export/alerts":       loader.RoleAnalyst,
+	"GET /api/export/flows":        loader.RoleAnalyst,
+	"POST /api/blocks":             loader.RoleAnalyst,
+	"DELETE /api/blocks/:ip":       loader.RoleAnalyst,
+	"POST /api/whitelist":          loader.RoleAnalyst,
+	"DELETE /api/whitelist/*entry": loader.RoleAnalyst,
+	"PATCH /api/incidents/:id":     loader.RoleAnalyst,
+	"GET /api/audit":               loader.RoleAdmin,
+	"GET /api/admin/db":            loader.RoleAdmin,
+	"POST /api/engine/config":      loader.RoleAdmin,
+}
+
+// publicRoutes 不需要认证的路由
+var publicRoutes = map[string]bool{"POST /api/auth/login": true, "POST /api/auth/logout": true}
+
+// requestPath 把路由参数替换为示例值
+func requestPath(route string) string {
+	return strings.NewReplacer(":id", "1", ":ip", "192.0.2.1", "*entry", "192.0.2.1").Replace(route)
+}
+
+func TestRouteRoles(t *testing.T) {
+	setTestRepository(t)
+	setTestAuthenticator(t)
+	r := newRouter(loader.ServerConfig{})
+
+	for _, route := range r.Routes() {
+		key := route.Method + " " + route.Path
+		if _, ok := routeRoles[key]; !ok && !publicRoutes[key] {
+			t.Errorf("route %s has no expected role in routeRoles", key)
+		}
+	}
+
+	tokens := map[string]string{}
+	for _, role := range []string{loader.RoleViewer, loader.RoleAnalyst, loader.RoleAdmin} {
+		tokens[role] = login(t, r, role)
+	}
+	levels := map[string]int{"": 0, loader.RoleViewer: 1, loader.RoleAnalyst: 2, loader.RoleAdmin: 3}
+	for key, minRole := range routeRoles {
+		method, route, _ := strings.Cut(key, " ")
+		path := requestPath(route)
+		if w := serve(r, method, path, "", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without credentials: %d, want 401", key, w.Code)
+		} else if w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without WWW-Authenticate", key)
+		}
+		if w := serve(r, method, path, "not-a-token", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s with an invalid token: %d, want 401", key, w.Code)
+		}
+		// 低于所需角色的调用方在进入处理函数之前被拒绝
+		for role, token := range tokens {
+			if levels[role] >= levels[minRole] {
+				continue
+			}
+			if w := serve(r, method, path, token, "{}"); w.Code != http.StatusForbidden {
+				t.Errorf("%s as %s: %d, want 403", key, role, w.Code)
+			}
+		}
+	}
+
+	// 具有所需角色时请求到达处理函数
+	allowed := []struct {
+		method, path, role, body string
+		want                     int
+	}{
+		{http.MethodGet, "/api/alerts", loader.RoleViewer, "", http.StatusOK},
+		{http.MethodPatch, "/api/alerts/1", loader.RoleAnalyst, `{"acked":true}`, http.StatusNotFound},
+		{http.MethodGet, "/api/audit", loader.RoleAdmin, "", http.StatusOK},
+		{http.MethodPost, "/api/engine/config", loader.RoleAdmin, `{"threshold":2}`, http.StatusBadRequest},
+	}
+	for _, c := range allowed {
+		if w := serve(r, c.method, c.path, tokens[c.role], c.body); w.Code != c.want {
+			t.Errorf("%s %s as %s: %d %s, want %d", c.method, c.path, c.role, w.Code, w.Body, c.want)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	setTestRepository(t)
+	r := newRouter(loader.ServerConfig{})
+
+	// 未开启认证时所有请求都是 admin
+	if w := serve(r, http.MethodGet, "/api/audit", "", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /audit with authentication disabled: %d", w.Code)
+	}
+
+	setTestAuthenticator(t)
+	w := serve(r, http.MethodPost, "/api/auth/login", "", `{"username":"viewer","password":"`+testPassword+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: %d %s", w.Code, w.Body)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("unexpected session cookie: %+v", cookies)
+	}
+
+	// 控制台与 EventSource 通过 Cookie 认证
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var me struct{ User auth.Identity }
+	json.Unmarshal(w.Body.Bytes(), &me)
+	if w.Code != http.StatusOK || me.User.Name != "viewer" || me.User.Role != loader.RoleViewer {
+		t.Errorf("GET /auth/me with the session cookie: %d %s", w.Code, w.Body)
+	}
+
+	for name, header := range map[string]string{
+		"unsupported scheme": "Basic dmlld2VyOnMzY3JldA==",
+		"unknown API key":    "Bearer " + auth.APIKeyPrefix + "unknown",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: %d, want 401", name, w.Code)
+		}
+	}
+}
+
+func TestLoginLimit(t *testing.T) {
+	setTestRepository(t)
+	setTestAuthenticator(t)
+	r := newRouter(loader.ServerConfig{})
+
+	attempt := func(username, password, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login",
+			strings.NewReader(`{"username":"`+username+`","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < loginFreeAttempts; i++ {
+		if w := attempt("admin", "wrong", "192.0.2.1:1000"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: %d, want 401", i+1, w.Code)
+		}
+	}
+	// 锁定期间正确的密码也被拒绝
+	if w := attempt("admin", testPassword, "192.0.2.1:1001"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("same address: %d, Retry-After %q; want 429", w.Code, w.Header().Get("Retry-After"))
+	}
+	// 其他地址不受影响，攻击者不能借此锁定指定用户
+	if w := attempt("admin", testPassword, "198.51.100.1:1000"); w.Code != http.StatusOK {
+		t.Errorf("other address: %d, want 200", w.Code)
+	}
+	// X-Forwarded-For 不能绕过按地址的限制
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login",
+		strings.NewReader(`{"username":"viewer","password":"`+testPassword+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	req.RemoteAddr = "192.0.2.1:1002"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("spoofed X-Forwarded-For: %d, want 429", w.Code)
+	}
+	// 其他地址上的其他用户不受影响
+	if w := attempt("viewer", testPassword, "198.51.100.1:1001"); w.Code != http.StatusOK {
+		t.Errorf("unrelated login: %d, want 200", w.Code)
+	}
+
+	// 在同一地址登录自己的账号不会清除对其他用户的猜测次数
+	for i := 0; i < loginFreeAttempts-1; i++ {
+		attempt("admin", "wrong", "203.0.113.1:1000")
+	}
+	if w := attempt("viewer", testPassword, "203.0.113.1:1001"); w.Code != http.StatusOK {
+		t.Fatalf("own login: %d, want 200", w.Code)
+	}
+	attempt("admin", "wrong", "203.0.113.1:1002")
+	if w := attempt("admin", testPassword, "203.0.113.1:1003"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("guessing after an own login: %d, want 429", w.Code)
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	now := time.Now()
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < loginFreeAttempts-1; i++ {
+		l.fail("ip:a", "user:u")
+	}
+	if wait := l.retryAfter("ip:a"); wait != 0 {
+		t.Fatalf("locked out after %d failures: %v", loginFreeAttempts-1, wait)
+	}
+	l.fail("ip:a", "user:u")
+	if wait := l.retryAfter("ip:b", "user:u"); wait != loginLockout {
+		t.Errorf("first lockout %v, want %v", wait, loginLockout)
+	}
+	now = now.Add(loginLockout)
+	if wait := l.retryAfter("ip:a"); wait != 0 {
+		t.Errorf("still locked out after the lockout: %v", wait)
+	}
+	// 继续失败时锁定时间翻倍，不超过上限
+	l.fail("ip:a")
+	if wait := l.retryAfter("ip:a"); wait != 2*loginLockout {
+		t.Errorf("second lockout %v, want %v", wait, 2*loginLockout)
+	}
+	for i := 0; i < 40; i++ {
+		l.fail("ip:a")
+	}
+	if wait := l.retryAfter("ip:a"); wait != loginMaxLockout {
+		t.Errorf("lockout %v, want the maximum %v", wait, loginMaxLockout)
+	}
+	l.succeed("ip:a", "user:u")
+	if wait := l.retryAfter("ip:a", "user:u"); wait != 0 {
+		t.Errorf("locked out after a successful login: %v", wait)
+	}
+
+	// 超出上限时丢弃空闲的记录
+	for i := 0; i < loginMaxEntries; i++ {
+		l.fail("user:" + strconv.Itoa(i))
+	}
+	now = now.Add(2 * loginMaxLockout)
+	l.fail("ip:c")
+	if len(l.failures) != 1 {
+		t.Errorf("%d entries after sweeping, want 1", len(l.failures))
+	}
+}
@@ -3,27 +3,46 @@ package server
 import (
 	"sync"
 
-	"go-ids/internal/db"
-
 	"io"
 
 	"github.com/gin-gonic/gin"
 )
 
+// SSE event names
+const (
+	EventAlert    = "alert"
+	EventIncident = "incident"
+)
+
+// Event is a named message pushed to SSE clients
+type Event struct {
+	Name string
+	Data interface{}
+}
+
 // EventManager handles SSE clients
 type EventManager struct {
-	Message       chan db.Alert
-	NewClients    chan chan db.Alert
-	ClosedClients chan chan db.Alert
-	TotalClients  map[chan db.Alert]bool
+	Message       chan Event
+	NewClients    chan chan Event
+	ClosedClients chan chan Event
+	TotalClients  map[chan Event]bool
 	mutex         sync.Mutex
 }
 
 var Manager = &EventManager{
-	Message:       make(chan db.Alert),
-	NewClients:    make(chan chan db.Alert),
-	ClosedClients: make(chan chan db.Alert),
-	TotalClients:  make(map[chan db.Alert]bool),
+	Message:       make(chan Event),
+	NewClients:    make(chan chan Event),
+	ClosedClients: make(chan chan Event),
+	TotalClients:  make(map[chan Event]bool),
+}
+
+// Publish sends an event to all clients without blocking the caller
+func (stream *EventManager) Publish(name string, data interface{}) {
+	select {
+	case stream.Message <- Event{Name: name, Data: data}:
+	default:
+		// 防止阻塞
+	}
 }
 
 // Listen starts the event manager listener loop
@@ -57,18 +76,18 @@ func (stream *EventManager) Listen() {
 }
 
 // SSEHeaders sets required headers for SSE
+// Cross-origin access is left to the CORS middleware so server.cors_origins also applies to the stream
 func SSEHeaders(c *gin.Context) {
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
-	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 }
 
 // ServerSentEventsHandler handles the SSE endpoint
 func ServerSentEventsHandler(c *gin.Context) {
 	SSEHeaders(c)
 
-	clientChan := make(chan db.Alert)
+	clientChan := make(chan Event)
 	Manager.NewClients <- clientChan
 
 	defer func() {
@@ -81,10 +100,12 @@ func ServerSentEventsHandler(c *gin.Context) {
 	c.Stream(func(w io.Writer) bool {
 		select {
 		case msg := <-clientChan:
-			c.SSEvent("alert", msg)
+			c.SSEvent(msg.Name, msg.Data)
 			return true
 		case <-c.Request.Context().Done():
 			return false
+		case <-closing:
+			return false
 		}
 	})
 }
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-ids/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ThreatStatsRequest holds the query parameters of GET /api/stats/alerts
+// The window defaults to the last 24 hours and the bucket size to one that suits the window
+type ThreatStatsRequest struct {
+	Since         *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until         *time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Bucket        string     `form:"bucket"` // Go duration (15m, 1h) or whole days (1d, 7d)
+	TZ            string     `form:"tz"`     // IANA time zone of the bucket boundaries, e.g. Asia/Shanghai
+	Src           string     `form:"src"`
+	Dst           string     `form:"dst"`
+	Labels        []string   `form:"label"`
+	MinConfidence *float32   `form:"min_confidence" binding:"omitempty,gte=0,lte=1"`
+	Top           int        `form:"top,default=10" binding:"min=1,max=100"`
+	Bins          int        `form:"bins,default=10" binding:"min=1,max=100"`
+}
+
+// parseBucket parses a bucket size, accepting a "d" suffix for whole days
+func parseBucket(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid bucket %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid bucket %q", s)
+		}
+	}
+	if d < time.Minute {
+		return 0, fmt.Errorf("bucket must be at least 1m")
+	}
+	return d, nil
+}
+
+// GetAlertStatsHandler returns the alert time series with label, source, target, port and confidence breakdowns
+func GetAlertStatsHandler(c *gin.Context) {
+	var req ThreatStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bucket, err := parseBucket(req.Bucket)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc := time.Local
+	if req.TZ != "" {
+		if loc, err = time.LoadLocation(req.TZ); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown time zone %q", req.TZ)})
+			return
+		}
+	}
+	for _, addr := range []string{req.Src, req.Dst} {
+		if addr != "" && !validAddrFilter(addr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "src and dst must be an IP address or CIDR prefix"})
+			return
+		}
+	}
+
+	stats, err := repo.ThreatStats(db.StatsQuery{
+		Filter: db.AlertFilter{
+			Source:        req.Src,
+			Dest:          req.Dst,
+			Labels:        splitLabels(req.Labels),
+			MinConfidence: req.MinConfidence,
+			Since:         req.Since,
+			Until:         req.Until,
+		},
+		Bucket:   bucket,
+		Location: loc,
+		TopN:     req.Top,
+		Bins:     req.Bins,
+	})
+	if errors.Is(err, db.ErrInvalidQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
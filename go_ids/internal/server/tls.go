@@ -0,0 +1,142 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go-ids/internal/loader"
+
+	"github.com/sirupsen/logrus"
+)
+
+// certReloader serves the certificate and the client CA pool from disk and reloads them when the files change,
+// so renewed certificates are picked up without restarting the sensor
+type certReloader struct {
+	cfg loader.ServerTLSConfig
+
+	mu      sync.RWMutex
+	current *tls.Config
+	modTime time.Time // 已加载文件中最新的修改时间
+}
+
+// newCertReloader loads the certificate, failing when the files are missing or invalid
+func newCertReloader(cfg loader.ServerTLSConfig) (*certReloader, error) {
+	r := &certReloader{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files returns the files the TLS configuration is loaded from
+func (r *certReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+// latestModTime returns the newest modification time of the files
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range r.files() {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Reload reads the files again; on error the previous configuration stays in use
+func (r *certReloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("failed to stat TLS files: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA file %s contains no valid certificate", r.cfg.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if r.cfg.ClientAuth == loader.ClientAuthRequire {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	r.mu.Lock()
+	r.current, r.modTime = config, modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// reloadIfChanged reloads the files when any of them was modified after the last load
+func (r *certReloader) reloadIfChanged() (bool, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	changed := modTime.After(r.modTime)
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	return true, r.Reload()
+}
+
+// watch polls the files every interval until stop is closed
+func (r *certReloader) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := r.reloadIfChanged()
+			if err != nil {
+				// 证书更新过程中文件可能暂时不完整，继续使用旧证书，下次再试
+				logrus.Warnf("TLS certificate reload failed, keeping the previous certificate: %v", err)
+			} else if changed {
+				logrus.Info("TLS certificate reloaded")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tlsConfig returns the server configuration; every handshake uses the most recently loaded files
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.current, nil
+		},
+	}
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-ids/internal/auth"
+	"go-ids/internal/loader"
+)
+
+// testCert 是测试用的证书与私钥
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert 签发证书，parent 为空时生成自签名的 CA
+func newTestCert(t *testing.T, name string, serial int64, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// write 把证书与私钥写成 PEM 文件
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if keyFile != "" {
+		if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tlsCert 返回 crypto/tls 使用的证书
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// servedSerial 返回 reloader 当前提供的证书序列号
+func servedSerial(t *testing.T, r *certReloader) int64 {
+	t.Helper()
+	config, err := r.tlsConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	cfg := loader.ServerTLSConfig{
+		Enabled:  true,
+		CertFile: filepath.Join(dir, "server.crt"),
+		KeyFile:  filepath.Join(dir, "server.key"),
+	}
+	if _, err := newCertReloader(cfg); err == nil {
+		t.Fatal("expected an error for missing certificate files")
+	}
+
+	ca := newTestCert(t, "test-ca", 1, nil)
+	ca.write(t, cfg.CertFile, cfg.KeyFile)
+	r, err := newCertReloader(cfg)
+	if err != nil {
+		t.Fatalf("newCertReloader: %v", err)
+	}
+	if serial := servedSerial(t, r); serial != 1 {
+		t.Fatalf("serving serial %d, want 1", serial)
+	}
+	if changed, err := r.reloadIfChanged(); changed || err != nil {
+		t.Errorf("reloadIfChanged() = %v, %v without a change", changed, err)
+	}
+
+	// 续期后的证书在下次检查时生效
+	newTestCert(t, "ids.example.com", 2, ca).write(t, cfg.CertFile, cfg.KeyFile)
+	later := time.Now().Add(time.Minute)
+	for _, name := range r.files() {
+		os.Chtimes(name, later, later)
+	}
+	if changed, err := r.reloadIfChanged(); !changed || err != nil {
+		t.Fatalf("reloadIfChanged() = %v, %v after renewal", changed, err)
+	}
+	if serial := servedSerial(t, r); serial != 2 {
+		t.Errorf("serving serial %d after renewal, want 2", serial)
+	}
+
+	// 写入一半的证书加载失败，继续使用旧证书
+	if err := os.WriteFile(cfg.CertFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("expected an error for a truncated certificate")
+	}
+	if serial := servedSerial(t, r); serial != 2 {
+		t.Errorf("serving serial %d after a failed reload, want 2", serial)
+	}
+}
+
+func TestClientCertRole(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", 1, nil)
+	cfg := loader.ServerConfig{TLS: loader.ServerTLSConfig{
+		Enabled:        true,
+		CertFile:       filepath.Join(dir, "server.crt"),
+		KeyFile:        filepath.Join(dir, "server.key"),
+		ClientCAFile:   filepath.Join(dir, "ca.crt"),
+		ClientCertRole: loader.RoleViewer,
+	}}
+	newTestCert(t, "127.0.0.1", 2, ca).write(t, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	ca.write(t, cfg.TLS.ClientCAFile, "")
+	setTestAuthenticator(t)
+
+	reloader, err := newCertReloader(cfg.TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(newRouter(cfg))
+	ts.TLS = reloader.tlsConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+	sensor := client(newTestCert(t, "sensor-1", 3, ca).tlsCert())
+
+	resp, err := sensor.Get(ts.URL + "/api/auth/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var me struct{ User auth.Identity }
+	json.NewDecoder(resp.Body).Decode(&me)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || me.User.Name != "cert:sensor-1" || me.User.Role != loader.RoleViewer || me.User.Method != auth.MethodClientCert {
+		t.Fatalf("GET /auth/me with a client certificate: %d %+v", resp.StatusCode, me.User)
+	}
+	// 证书只授予配置的角色
+	resp, err = sensor.Post(ts.URL+"/api/engine/config", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("POST /engine/config with a viewer certificate: %d, want 403", resp.StatusCode)
+	}
+
+	// 没有证书或证书不是由配置的 CA 签发时仍需登录
+	stranger := newTestCert(t, "stranger", 4, newTestCert(t, "other-ca", 5, nil))
+	for name, c := range map[string]*http.Client{"no certificate": client(), "untrusted certificate": client(stranger.tlsCert())} {
+		resp, err := c.Get(ts.URL + "/api/auth/me")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: %d, want 401", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	// 每次运行使用新的关闭信号，Shutdown 只能关闭它一次
+	closing = make(chan struct{})
+	t.Cleanup(func() {
+		srvMu.Lock()
+		httpServer, listener, certs = nil, nil, nil
+		srvMu.Unlock()
+	})
+
+	cfg := loader.ServerConfig{Listen: "127.0.0.1", Port: port}
+	if err := Listen(cfg); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	// 端口已被占用时启动失败
+	srvMu.Lock()
+	saved := httpServer
+	srvMu.Unlock()
+	if err := Listen(cfg); err == nil {
+		t.Error("expected an error when the port is in use")
+	}
+	srvMu.Lock()
+	if httpServer != saved {
+		t.Error("failed Listen replaced the running server")
+	}
+	srvMu.Unlock()
+
+	served := make(chan error, 1)
+	go func() { served <- Serve() }()
+	url := "http://" + cfg.Address() + "/api/auth/me"
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// 关闭后 Serve 返回 nil，事件流随之结束，不再接受新连接
+	if err := Shutdown(t.Context()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve() = %v after Shutdown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Shutdown")
+	}
+	// RegisterOnShutdown 的回调在单独的协程中执行
+	select {
+	case <-closing:
+	case <-time.After(5 * time.Second):
+		t.Error("event streams were not told to close")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("server still accepts connections after Shutdown")
+	}
+}
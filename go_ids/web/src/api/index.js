@@ -1,16 +1,73 @@
 import axios from 'axios'
 
+// API 默认与控制台同源 (/api)，开发时由 Vite 代理到传感器
+// 控制台与传感器分开部署时，构建时用 VITE_API_BASE 指定 API 地址，如 https://ids.example.com/api
+export const API_BASE = import.meta.env.VITE_API_BASE || '/api'
+
+// 登录后会话令牌保存在 HttpOnly Cookie 中，跨域部署时也需要携带
 const instance = axios.create({
-    baseURL: 'http://localhost:8080/api',
+    baseURL: API_BASE,
     timeout: 5000,
+    withCredentials: true,
 })
 
 export default instance
 
+// apiURL 返回 API 路径的完整地址，供 EventSource 等不经过 axios 的请求使用
+export const apiURL = (path) => `${API_BASE}${path}`
+
+// openEvents 订阅实时事件流，EventSource 不能设置请求头，通过 Cookie 认证
+export const openEvents = () => {
+    return new EventSource(apiURL('/events'), { withCredentials: true })
+}
+
+// 会话过期或未登录时 (401) 调用 main.js 注册的处理函数跳转到登录页
+let unauthorizedHandler = null
+export const onUnauthorized = (handler) => {
+    unauthorizedHandler = handler
+}
+
+instance.interceptors.response.use(
+    res => res,
+    err => {
+        const url = err.config?.url || ''
+        if (err.response?.status === 401 && !url.startsWith('/auth/') && unauthorizedHandler) {
+            unauthorizedHandler()
+        }
+        return Promise.reject(err)
+    }
+)
+
+export const login = (username, password) => {
+    return instance.post('/auth/login', { username, password })
+}
+
+export const logout = () => {
+    return instance.post('/auth/logout')
+}
+
+// getMe 返回 { user, auth_enabled }，未登录时请求失败 (401)
+export const getMe = () => {
+    return instance.get('/auth/me')
+}
+
+// /alerts 返回分页对象 { alerts, total, next_cursor, facets }，这里只取告警列表
 export const getHistory = (limit = 50) => {
-    return instance.get(`/alerts?limit=${limit}`)
+    return instance.get(`/alerts?limit=${limit}`).then(res => ({ ...res, data: res.data.alerts }))
 }
 
 export const getStatus = () => {
     return instance.get('/status')
 }
+
+export const getThreatStats = (range) => {
+    return instance.get('/stats/threats', { params: { range } })
+}
+
+export const getEngineStatus = () => {
+    return instance.get('/engine/status')
+}
+
+export const updateEngineConfig = (payload) => {
+    return instance.post('/engine/config', payload)
+}
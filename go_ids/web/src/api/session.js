@@ -0,0 +1,32 @@
+import { reactive } from 'vue'
+import { getMe } from './index'
+
+// session 当前登录的用户，checked 表示已向后端确认过登录状态
+export const session = reactive({
+    user: null,
+    authEnabled: true,
+    checked: false,
+})
+
+// loadSession 向后端确认登录状态，未登录时 user 为 null
+export const loadSession = async () => {
+    try {
+        const res = await getMe()
+        session.user = res.data.user
+        session.authEnabled = res.data.auth_enabled
+    } catch (e) {
+        if (e.response?.status !== 401) {
+            throw e
+        }
+        session.user = null
+        session.authEnabled = true
+    }
+    session.checked = true
+    return session
+}
+
+// clearSession 退出登录或会话过期后重新确认登录状态
+export const clearSession = () => {
+    session.user = null
+    session.checked = false
+}
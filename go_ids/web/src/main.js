@@ -6,6 +6,8 @@ import 'element-plus/theme-chalk/dark/css-vars.css'
 import './style.css'
 import App from './App.vue'
 import router from './router'
+import { onUnauthorized } from './api'
+import { clearSession } from './api/session'
 import * as ElementPlusIconsVue from '@element-plus/icons-vue'
 
 const app = createApp(App)
@@ -18,4 +20,13 @@ app.use(ElementPlus, {
     locale: zhCn,
 })
 app.use(router)
+
+// 会话过期后任何请求返回 401 时回到登录页
+onUnauthorized(() => {
+    clearSession()
+    const current = router.currentRoute.value
+    if (!current.meta.public) {
+        router.push({ path: '/login', query: { redirect: current.fullPath } })
+    }
+})
 app.mount('#app')
@@ -5,6 +5,8 @@ import NetworkView from '../views/NetworkView.vue'
 import SettingsView from '../views/SettingsView.vue'
 import EngineView from '../views/EngineView.vue'
 import AssetView from '../views/AssetView.vue'
+import LoginView from '../views/LoginView.vue'
+import { session, loadSession } from '../api/session'
 
 const routes = [
     { path: '/', name: 'Dashboard', component: DashboardView },
@@ -12,6 +14,7 @@ const routes = [
     { path: '/asset', name: 'Asset', component: AssetView },
     { path: '/engine', name: 'Engine', component: EngineView },
     { path: '/settings', name: 'Settings', component: SettingsView },
+    { path: '/login', name: 'Login', component: LoginView, meta: { public: true } },
 ]
 
 const router = createRouter({
@@ -19,4 +22,24 @@ const router = createRouter({
     routes,
 })
 
+// 未登录时跳转到登录页，登录后回到原来的页面；未开启认证时后端把所有请求视为 admin
+router.beforeEach(async (to) => {
+    if (!session.checked) {
+        try {
+            await loadSession()
+        } catch (e) {
+            // 后端不可用时照常进入页面，由页面提示请求失败
+            console.error('Failed to load session', e)
+            return true
+        }
+    }
+    if (to.meta.public) {
+        return session.user ? '/' : true
+    }
+    if (!session.user) {
+        return { path: '/login', query: { redirect: to.fullPath } }
+    }
+    return true
+})
+
 export default router
@@ -1,12 +1,19 @@
-import { defineConfig } from 'vite'
+import { defineConfig, loadEnv } from 'vite'
 import vue from '@vitejs/plugin-vue'
 import tailwindcss from '@tailwindcss/vite'
 
 // https://vite.dev/config/
-export default defineConfig({
-  plugins: [vue(), tailwindcss()],
-  server: {
-    port: 3000,
-    host: true
+export default defineConfig(({ mode }) => {
+  const env = loadEnv(mode, process.cwd())
+  return {
+    plugins: [vue(), tailwindcss()],
+    server: {
+      port: 3000,
+      host: true,
+      // 开发时把 /api 转发到本机传感器，控制台与 API 同源，无需配置 CORS
+      proxy: {
+        '/api': env.VITE_PROXY_TARGET || 'http://localhost:8080'
+      }
+    }
   }
 })